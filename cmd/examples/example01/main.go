@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/ardanlabs/ai-training/foundation/vector"
 )
@@ -34,6 +35,12 @@ func (d data) String() string {
 // =============================================================================
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
 
 	// Apply the feature dataPoints to the hand crafted embeddings.
 	dataPoints := []vector.Data{
@@ -57,7 +64,10 @@ func main() {
 	// similarity comparison. This requires converting each data point
 	// into a vector.
 	for _, target := range dataPoints {
-		results := vector.Similarity(target, dataPoints...)
+		results, err := vector.Similarity(target, dataPoints...)
+		if err != nil {
+			return fmt.Errorf("similarity: %w", err)
+		}
 
 		for _, result := range results {
 			fmt.Printf("%s -> %s: %.2f%% similar\n",
@@ -71,12 +81,25 @@ func main() {
 	// -------------------------------------------------------------------------
 
 	// You can perform vector math by adding and subtracting vectors.
-	kingSubMan := vector.Sub(dataPoints[3].Vector(), dataPoints[1].Vector())
-	kingSubManPlusWoman := vector.Add(kingSubMan, dataPoints[2].Vector())
+	kingSubMan, err := vector.Sub(dataPoints[3].Vector(), dataPoints[1].Vector())
+	if err != nil {
+		return fmt.Errorf("sub: %w", err)
+	}
+
+	kingSubManPlusWoman, err := vector.Add(kingSubMan, dataPoints[2].Vector())
+	if err != nil {
+		return fmt.Errorf("add: %w", err)
+	}
+
 	queen := dataPoints[4].Vector()
 
 	// Now compare a (King - Man + Woman) to a Queen.
-	result := vector.CosineSimilarity(kingSubManPlusWoman, queen)
+	result, err := vector.CosineSimilarity(kingSubManPlusWoman, queen)
+	if err != nil {
+		return fmt.Errorf("cosine similarity: %w", err)
+	}
+
 	fmt.Printf("King - Man + Woman ~= Queen similarity: %.2f%%\n", result*100)
 
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/ardanlabs/ai-training/foundation/client"
@@ -14,9 +15,22 @@ import (
 var (
 	url   = "http://localhost:11434/v1/embeddings"
 	model = "bge-m3:latest"
+
+	// The context window represents the maximum number of tokens that can be sent
+	// and received by the model. The default for Ollama is 4K. The makefile
+	// sets it with OLLAMA_CONTEXT_LENGTH.
+	contextWindow = 1024 * 4
 )
 
 func init() {
+	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if v := os.Getenv("LLM_SERVER"); v != "" {
 		url = v
 	}
@@ -52,7 +66,10 @@ func run() error {
 	defer cancel()
 
 	// Construct the llm client for access the model server.
-	llm := client.NewLLM(url, model)
+	llm, err := client.NewLLM(url, model, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
 
 	// -------------------------------------------------------------------------
 
@@ -100,12 +117,24 @@ func run() error {
 	// Perform the same vector math as in example2 using the LLM vector embedding.
 
 	// You can perform vector math by adding and subtracting vectors.
-	kingSubMan := vector.Sub(dataPoints[3].Vector(), dataPoints[1].Vector())
-	kingSubManPlusWoman := vector.Add(kingSubMan, dataPoints[2].Vector())
+	kingSubMan, err := vector.Sub(dataPoints[3].Vector(), dataPoints[1].Vector())
+	if err != nil {
+		return fmt.Errorf("sub: %w", err)
+	}
+
+	kingSubManPlusWoman, err := vector.Add(kingSubMan, dataPoints[2].Vector())
+	if err != nil {
+		return fmt.Errorf("add: %w", err)
+	}
+
 	queen := dataPoints[4].Vector()
 
 	// Now compare a (King - Man + Woman) to a Queen.
-	result := vector.CosineSimilarity(kingSubManPlusWoman, queen)
+	result, err := vector.CosineSimilarity(kingSubManPlusWoman, queen)
+	if err != nil {
+		return fmt.Errorf("cosine similarity: %w", err)
+	}
+
 	fmt.Printf("King - Man + Woman ~= Queen similarity: %.2f%%\n", result*100)
 
 	return nil
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/ardanlabs/ai-training/foundation/client"
@@ -13,9 +14,22 @@ import (
 var (
 	url   = "http://localhost:11434/v1/chat/completions"
 	model = "qwen2.5vl:latest"
+
+	// The context window represents the maximum number of tokens that can be sent
+	// and received by the model. The default for Ollama is 4K. The makefile
+	// sets it with OLLAMA_CONTEXT_LENGTH.
+	contextWindow = 1024 * 4
 )
 
 func init() {
+	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if v := os.Getenv("LLM_SERVER"); v != "" {
 		url = v
 	}
@@ -83,7 +97,10 @@ func run() error {
 
 	// -------------------------------------------------------------------------
 
-	llm := client.NewLLM(url, model)
+	llm, err := client.NewLLM(url, model, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
 
 	ch, err := llm.ChatCompletionsSSE(ctx, finalPrompt)
 	if err != nil {
@@ -33,6 +33,7 @@ import (
 	"log"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -50,9 +51,22 @@ var (
 	dbName     = "example06"
 	colName    = "book"
 	dimensions = 1024
+
+	// The context window represents the maximum number of tokens that can be sent
+	// and received by the model. The default for Ollama is 4K. The makefile
+	// sets it with OLLAMA_CONTEXT_LENGTH.
+	contextWindow = 1024 * 4
 )
 
 func init() {
+	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if v := os.Getenv("LLM_SERVER"); v != "" {
 		url = v
 	}
@@ -114,7 +128,10 @@ func run() error {
 }
 
 func createBookEmbeddings(ctx context.Context) error {
-	llm := client.NewLLM(url, model)
+	llm, err := client.NewLLM(url, model, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
 
 	if _, err := os.Stat("zarf/data/book.embeddings"); err == nil {
 		return nil
@@ -131,37 +148,35 @@ func createBookEmbeddings(ctx context.Context) error {
 	}
 	defer output.Close()
 
-	fmt.Print("\n")
-	fmt.Print("\033[s")
-
 	r := regexp.MustCompile(`<CHUNK>[\w\W]*?<\/CHUNK>`)
 	chunks := r.FindAllString(string(data), -1)
 
-	// Read one chunk at a time (each line) and get the vector embedding.
-	for counter, chunk := range chunks {
-		fmt.Print("\033[u\033[K")
-		fmt.Printf("Vectorizing Data: %d of %d", counter, len(chunks))
+	for i, chunk := range chunks {
+		chunk = strings.Trim(chunk, "<CHUNK>")
+		chunks[i] = strings.Trim(chunk, "</CHUNK>")
+	}
 
-		chunk := strings.Trim(chunk, "<CHUNK>")
-		chunk = strings.Trim(chunk, "</CHUNK>")
+	// YOU WILL WANT TO KNOW HOW MANY TOKENS ARE CURRENTLY IN THE CHUNK
+	// SO YOU DON'T EXCEED THE NUMBER OF TOKENS THE MODEL WILL USE TO
+	// CREATE THE VECTOR EMBEDDING. THE MODEL WILL TRUNCATE YOUR CHUNK IF IT
+	// EXCEEDS THE NUMBER OF TOKENS IT CAN USE TO CREATE THE VECTOR
+	// EMBEDDING. THERE ARE MODELS THAT ONLY VECTORIZE AS LITTLE AS 512
+	// TOKENS. THERE IS A TIKTOKEN PACKAGE IN FOUNDATION TO HELP YOU WITH
+	// THIS.
 
-		// YOU WILL WANT TO KNOW HOW MANY TOKENS ARE CURRENTLY IN THE CHUNK
-		// SO YOU DON'T EXCEED THE NUMBER OF TOKENS THE MODEL WILL USE TO
-		// CREATE THE VECTOR EMBEDDING. THE MODEL WILL TRUNCATE YOUR CHUNK IF IT
-		// EXCEEDS THE NUMBER OF TOKENS IT CAN USE TO CREATE THE VECTOR
-		// EMBEDDING. THERE ARE MODELS THAT ONLY VECTORIZE AS LITTLE AS 512
-		// TOKENS. THERE IS A TIKTOKEN PACKAGE IN FOUNDATION TO HELP YOU WITH
-		// THIS.
+	fmt.Printf("\nVectorizing Data: %d chunks\n", len(chunks))
 
-		vector, err := llm.EmbedText(ctx, chunk)
-		if err != nil {
-			return fmt.Errorf("embedding: %w", err)
-		}
+	// Send the chunks in batches so we don't need a round trip per chunk.
+	vectors, err := llm.EmbedTexts(ctx, chunks, client.WithBatch(32, 0), client.WithConcurrency(4))
+	if err != nil {
+		return fmt.Errorf("embedding: %w", err)
+	}
 
+	for counter, chunk := range chunks {
 		doc := document{
 			ID:        counter,
 			Text:      chunk,
-			Embedding: vector,
+			Embedding: vectors[counter],
 		}
 
 		data, err := json.Marshal(doc)
@@ -180,8 +195,6 @@ func createBookEmbeddings(ctx context.Context) error {
 		}
 	}
 
-	fmt.Print("\n")
-
 	return nil
 }
 
@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ardanlabs/ai-training/foundation/client"
 	"github.com/ardanlabs/ai-training/foundation/mongodb"
+	"github.com/ardanlabs/ai-training/foundation/vector"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -23,9 +26,22 @@ var (
 
 	dbName  = "example06"
 	colName = "book"
+
+	// The context window represents the maximum number of tokens that can be sent
+	// and received by the model. The default for Ollama is 4K. The makefile
+	// sets it with OLLAMA_CONTEXT_LENGTH.
+	contextWindow = 1024 * 4
 )
 
 func init() {
+	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if v := os.Getenv("LLM_CHAT_SERVER"); v != "" {
 		urlChat = v
 	}
@@ -85,9 +101,20 @@ type searchResult struct {
 }
 
 func vectorSearch(ctx context.Context, question string) ([]searchResult, error) {
-	llm := client.NewLLM(urlEmbed, modelEmbed)
+	// The embeddings are cached on disk so asking the same question again
+	// doesn't need to call the model server.
+	cache, err := client.NewFileCache(filepath.Join(os.TempDir(), "example07"), 1000, 24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("cache: %w", err)
+	}
+
+	embed := client.NewCached(client.NewOpenAI(client.StdoutLogger, urlEmbed), cache)
+	llm, err := client.NewProviderLLM(embed, modelEmbed, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return nil, fmt.Errorf("new llm: %w", err)
+	}
 
-	vector, err := llm.EmbedText(ctx, question)
+	queryVector, err := llm.EmbedText(ctx, question)
 	if err != nil {
 		return nil, fmt.Errorf("do: %w", err)
 	}
@@ -103,13 +130,25 @@ func vectorSearch(ctx context.Context, question string) ([]searchResult, error)
 
 	// -------------------------------------------------------------------------
 
-	const limitResults = 2
+	// The chunks overlap, so the closest chunks are often near duplicates.
+	// Fetch more candidates than needed and use MMR to select chunks that
+	// are relevant to the question but different from each other.
+	const (
+		limitResults  = 2
+		numCandidates = 10
+		lambda        = 0.7
+	)
 
-	results, err := vectorDBSearch(ctx, col, vector, limitResults)
+	candidates, err := vectorDBSearch(ctx, col, queryVector, numCandidates)
 	if err != nil {
 		return nil, fmt.Errorf("vectorDBSearch: %w", err)
 	}
 
+	results, err := vector.MMR(queryVector, candidates, func(r searchResult) []float64 { return r.Embedding }, limitResults, lambda)
+	if err != nil {
+		return nil, fmt.Errorf("mmr: %w", err)
+	}
+
 	return results, nil
 }
 
@@ -192,7 +231,10 @@ func questionResponse(ctx context.Context, question string, results []searchResu
 
 	// -------------------------------------------------------------------------
 
-	llm := client.NewLLM(urlChat, modelChat)
+	llm, err := client.NewLLM(urlChat, modelChat, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
 
 	ch, err := llm.ChatCompletionsSSE(ctx, finalPrompt)
 	if err != nil {
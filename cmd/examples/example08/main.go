@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,9 +17,22 @@ import (
 var (
 	url   = "http://localhost:11434/v1/chat/completions"
 	model = "gpt-oss:latest"
+
+	// The context window represents the maximum number of tokens that can be sent
+	// and received by the model. The default for Ollama is 4K. The makefile
+	// sets it with OLLAMA_CONTEXT_LENGTH.
+	contextWindow = 1024 * 4
 )
 
 func init() {
+	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if v := os.Getenv("LLM_SERVER"); v != "" {
 		url = v
 	}
@@ -60,7 +74,10 @@ func run() error {
 
 	// -------------------------------------------------------------------------
 
-	llm := client.NewLLM(url, model)
+	llm, err := client.NewLLM(url, model, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
 
 	query, err := llm.ChatCompletions(ctx, fmt.Sprintf(query, question))
 	if err != nil {
@@ -75,7 +92,7 @@ func run() error {
 	// -------------------------------------------------------------------------
 
 	data := []map[string]any{}
-	if err := sqldb.QueryMap(ctx, db, query, &data); err != nil {
+	if err := sqldb.QueryMap(ctx, nil, db, query, &data); err != nil {
 		return fmt.Errorf("execQuery: %w", err)
 	}
 
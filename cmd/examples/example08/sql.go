@@ -66,7 +66,7 @@ func dbConnection() (*sqlx.DB, error) {
 }
 
 func dbExecute(ctx context.Context, db *sqlx.DB, query string) error {
-	if err := sqldb.StatusCheck(ctx, db); err != nil {
+	if err := sqldb.StatusCheck(ctx, nil, db); err != nil {
 		return fmt.Errorf("status check database: %w", err)
 	}
 
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/ardanlabs/ai-training/foundation/client"
@@ -16,9 +17,22 @@ var (
 	model = "gemma3:12b-it-qat"
 
 	imagePath = "zarf/samples/gallery/roseimg.png"
+
+	// The context window represents the maximum number of tokens that can be sent
+	// and received by the model. The default for Ollama is 4K. The makefile
+	// sets it with OLLAMA_CONTEXT_LENGTH.
+	contextWindow = 1024 * 4
 )
 
 func init() {
+	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if v := os.Getenv("LLM_SERVER"); v != "" {
 		url = v
 	}
@@ -67,7 +81,10 @@ func run() error {
 		Make sure the JSON is valid, doesn't have any extra spaces, and is
 		properly formatted.`
 
-	llm := client.NewLLM(url, model)
+	llm, err := client.NewLLM(url, model, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
 
 	results, err := llm.ChatCompletions(ctx, prompt, client.WithImage(mimeType, image))
 	if err != nil {
@@ -20,6 +20,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/ardanlabs/ai-training/foundation/client"
@@ -38,9 +39,22 @@ var (
 	dbName     = "example9"
 	colName    = "images-4"
 	dimensions = 1024
+
+	// The context window represents the maximum number of tokens that can be sent
+	// and received by the model. The default for Ollama is 4K. The makefile
+	// sets it with OLLAMA_CONTEXT_LENGTH.
+	contextWindow = 1024 * 4
 )
 
 func init() {
+	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if v := os.Getenv("LLM_CHAT_SERVER"); v != "" {
 		urlChat = v
 	}
@@ -132,7 +146,10 @@ func run() error {
 		Make sure the JSON is valid, doesn't have any extra spaces, and is
 		properly formatted.`
 
-	llm := client.NewLLM(urlChat, modelChat)
+	llm, err := client.NewLLM(urlChat, modelChat, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
 
 	results, err := llm.ChatCompletions(ctx, prompt, client.WithImage(mimeType, image))
 	if err != nil {
@@ -145,7 +162,10 @@ func run() error {
 
 	fmt.Println("\nGenerating embeddings for the image description:")
 
-	embedLLM := client.NewLLM(urlEmbed, modelEmbed)
+	embedLLM, err := client.NewLLM(urlEmbed, modelEmbed, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
 
 	vector, err := embedLLM.EmbedText(ctx, results)
 	if err != nil {
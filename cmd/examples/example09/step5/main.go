@@ -23,11 +23,13 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ardanlabs/ai-training/foundation/client"
 	"github.com/ardanlabs/ai-training/foundation/mongodb"
+	"github.com/ardanlabs/ai-training/foundation/vector"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -42,9 +44,26 @@ var (
 	colName     = "images-5"
 	dimensions  = 1024
 	galleryPath = "zarf/samples/gallery/"
+
+	// When the embedding model is multimodal, the description and the image
+	// are embedded together.
+	multimodalEmbed = false
+
+	// The context window represents the maximum number of tokens that can be sent
+	// and received by the model. The default for Ollama is 4K. The makefile
+	// sets it with OLLAMA_CONTEXT_LENGTH.
+	contextWindow = 1024 * 4
 )
 
 func init() {
+	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if v := os.Getenv("LLM_CHAT_SERVER"); v != "" {
 		urlChat = v
 	}
@@ -60,6 +79,10 @@ func init() {
 	if v := os.Getenv("LLM_EMBED_MODEL"); v != "" {
 		modelEmbed = v
 	}
+
+	if v := os.Getenv("LLM_EMBED_MULTIMODAL"); v == "true" {
+		multimodalEmbed = true
+	}
 }
 
 // =============================================================================
@@ -83,8 +106,15 @@ func run() error {
 
 	// -------------------------------------------------------------------------
 
-	llmChat := client.NewLLM(urlChat, modelChat)
-	embedLLM := client.NewLLM(urlEmbed, modelEmbed)
+	llmChat, err := client.NewLLM(urlChat, modelChat, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
+
+	embedLLM, err := client.NewLLM(urlEmbed, modelEmbed, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
 
 	// -------------------------------------------------------------------------
 
@@ -193,11 +223,24 @@ func saveImagesInDB(ctx context.Context, llm *client.LLM, embedLLM *client.LLM,
 			return fmt.Errorf("llmChat.ChatCompletions: %w", err)
 		}
 
-		fmt.Println("  - Generate embeddings for the image description")
+		var vector []float64
 
-		vector, err := embedLLM.EmbedText(ctx, results)
-		if err != nil {
-			return fmt.Errorf("llm.EmbedText: %w", err)
+		switch multimodalEmbed {
+		case true:
+			fmt.Println("  - Generate embeddings for the image and its description")
+
+			vector, err = embedLLM.EmbedWithImage(ctx, results, image, mimeType)
+			if err != nil {
+				return fmt.Errorf("llm.EmbedWithImage: %w", err)
+			}
+
+		default:
+			fmt.Println("  - Generate embeddings for the image description")
+
+			vector, err = embedLLM.EmbedText(ctx, results)
+			if err != nil {
+				return fmt.Errorf("llm.EmbedText: %w", err)
+			}
 		}
 
 		// ---------------------------------------------------------------------
@@ -391,15 +434,34 @@ func initDB(ctx context.Context, client *mongo.Client) (*mongo.Collection, error
 }
 
 func textVectorSearch(ctx context.Context, llm *client.LLM, col *mongo.Collection, question string) ([]searchResult, error) {
-	vector, err := llm.EmbedText(ctx, question)
+	queryVector, err := llm.EmbedText(ctx, question)
 	if err != nil {
 		return nil, fmt.Errorf("embedText: %w", err)
 	}
 
-	return vectorSearch(ctx, col, vector, "embedding")
+	// Similar images get near identical descriptions and embeddings. Fetch
+	// more candidates than needed and drop the near duplicates of a more
+	// relevant image before keeping the top results.
+	const (
+		limitResults  = 5
+		numCandidates = 20
+		threshold     = 0.95
+	)
+
+	candidates, err := vectorSearch(ctx, col, queryVector, "embedding", numCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := vector.SuppressDuplicates(candidates, func(r searchResult) []float64 { return r.Embedding }, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("suppress duplicates: %w", err)
+	}
+
+	return results[:min(limitResults, len(results))], nil
 }
 
-func vectorSearch(ctx context.Context, col *mongo.Collection, vector []float64, column string) ([]searchResult, error) {
+func vectorSearch(ctx context.Context, col *mongo.Collection, vector []float64, column string, limit int) ([]searchResult, error) {
 	pipeline := mongo.Pipeline{
 		{{
 			Key: "$vectorSearch",
@@ -408,7 +470,7 @@ func vectorSearch(ctx context.Context, col *mongo.Collection, vector []float64,
 				"exact":       true,
 				"path":        column,
 				"queryVector": vector,
-				"limit":       5,
+				"limit":       limit,
 			}},
 		},
 		{{
@@ -19,7 +19,7 @@ import (
 )
 
 // toolSuccessResponse returns a successful structured tool response.
-func toolSuccessResponse(toolID string, toolName string, keyValues ...any) client.D {
+func toolSuccessResponse(toolID string, toolName string, keyValues ...any) client.Message {
 	data := make(map[string]any)
 	for i := 0; i < len(keyValues); i = i + 2 {
 		data[keyValues[i].(string)] = keyValues[i+1]
@@ -29,14 +29,14 @@ func toolSuccessResponse(toolID string, toolName string, keyValues ...any) clien
 }
 
 // toolErrorResponse returns a failed structured tool response.
-func toolErrorResponse(toolID string, toolName string, err error) client.D {
+func toolErrorResponse(toolID string, toolName string, err error) client.Message {
 	data := map[string]any{"error": err.Error()}
 
 	return toolResponse(toolID, toolName, data, "FAILED")
 }
 
 // toolResponse creates a structured tool response.
-func toolResponse(toolID string, toolName string, data map[string]any, status string) client.D {
+func toolResponse(toolID string, toolName string, data map[string]any, status string) client.Message {
 	info := struct {
 		Status string         `json:"status"`
 		Data   map[string]any `json:"data"`
@@ -47,20 +47,10 @@ func toolResponse(toolID string, toolName string, data map[string]any, status st
 
 	content, err := json.Marshal(info)
 	if err != nil {
-		return client.D{
-			"role":         "tool",
-			"tool_call_id": toolID,
-			"tool_name":    toolName,
-			"content":      `{"status": "FAILED", "data": "error marshaling tool response"}`,
-		}
+		return client.ToolMessage(toolID, toolName, `{"status": "FAILED", "data": "error marshaling tool response"}`)
 	}
 
-	return client.D{
-		"role":         "tool",
-		"tool_call_id": toolID,
-		"tool_name":    toolName,
-		"content":      string(content),
-	}
+	return client.ToolMessage(toolID, toolName, string(content))
 }
 
 // =============================================================================
@@ -105,7 +95,7 @@ func (rf *ReadFile) toolDocument() client.D {
 
 // Call is the function that is called by the agent to read the contents of a
 // file when the model requests the tool with the specified parameters.
-func (rf *ReadFile) Call(ctx context.Context, toolCall client.ToolCall) (resp client.D) {
+func (rf *ReadFile) Call(ctx context.Context, toolCall client.ToolCall) (resp client.Message) {
 	defer func() {
 		if r := recover(); r != nil {
 			resp = toolErrorResponse(toolCall.ID, rf.name, fmt.Errorf("%s", r))
@@ -175,7 +165,7 @@ func (sf *SearchFiles) toolDocument() client.D {
 
 // Call is the function that is called by the agent to list files when the model
 // requests the tool with the specified parameters.
-func (sf *SearchFiles) Call(ctx context.Context, toolCall client.ToolCall) (resp client.D) {
+func (sf *SearchFiles) Call(ctx context.Context, toolCall client.ToolCall) (resp client.Message) {
 	defer func() {
 		if r := recover(); r != nil {
 			resp = toolErrorResponse(toolCall.ID, sf.name, fmt.Errorf("%s", r))
@@ -305,7 +295,7 @@ func (cf *CreateFile) toolDocument() client.D {
 
 // Call is the function that is called by the agent to create a file when the model
 // requests the tool with the specified parameters.
-func (cf *CreateFile) Call(ctx context.Context, toolCall client.ToolCall) (resp client.D) {
+func (cf *CreateFile) Call(ctx context.Context, toolCall client.ToolCall) (resp client.Message) {
 	defer func() {
 		if r := recover(); r != nil {
 			resp = toolErrorResponse(toolCall.ID, cf.name, fmt.Errorf("%s", r))
@@ -386,7 +376,7 @@ func (gce *GoCodeEditor) toolDocument() client.D {
 
 // Call is the function that is called by the agent to edit a file when the model
 // requests the tool with the specified parameters.
-func (gce *GoCodeEditor) Call(ctx context.Context, toolCall client.ToolCall) (resp client.D) {
+func (gce *GoCodeEditor) Call(ctx context.Context, toolCall client.ToolCall) (resp client.Message) {
 	defer func() {
 		if r := recover(); r != nil {
 			resp = toolErrorResponse(toolCall.ID, gce.name, fmt.Errorf("%s", r))
@@ -17,7 +17,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"slices"
 	"strconv"
@@ -93,14 +92,14 @@ func run() error {
 
 // Tool describes the features which all tools must implement.
 type Tool interface {
-	Call(ctx context.Context, toolCall client.ToolCall) client.D
+	Call(ctx context.Context, toolCall client.ToolCall) client.Message
 }
 
 // =============================================================================
 
 // Agent represents the chat agent that can use tools to perform tasks.
 type Agent struct {
-	sseClient      *client.SSEClient[client.ChatSSE]
+	llm            *client.LLM
 	getUserMessage func() (string, bool)
 	tke            *tiktoken.Tiktoken
 	tools          map[string]Tool
@@ -121,10 +120,15 @@ func NewAgent(getUserMessage func() (string, bool)) (*Agent, error) {
 	// -------------------------------------------------------------------------
 	// Construct the agent.
 
+	llm, err := newLLM()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create llm: %w", err)
+	}
+
 	tools := map[string]Tool{}
 
 	agent := Agent{
-		sseClient:      client.NewSSE[client.ChatSSE](client.StdoutLogger),
+		llm:            llm,
 		getUserMessage: getUserMessage,
 		tke:            tke,
 		tools:          tools,
@@ -141,6 +145,30 @@ func NewAgent(getUserMessage func() (string, bool)) (*Agent, error) {
 	return &agent, nil
 }
 
+// newLLM constructs the LLM for the model. When LLM_SERVER lists multiple
+// servers separated by commas, the requests are spread across them and
+// fail over to the next server when one is down.
+func newLLM() (*client.LLM, error) {
+	urls := strings.Split(url, ",")
+	if len(urls) == 1 {
+		return client.NewLLM(url, model, client.WithContextWindow(contextWindow))
+	}
+
+	backends := make([]client.Backend, len(urls))
+	for i, u := range urls {
+		u = strings.TrimSpace(u)
+
+		backends[i] = client.Backend{
+			Name:     u,
+			Provider: client.NewOpenAI(client.StdoutLogger, u, client.WithRetry(client.DefaultRetryPolicy)),
+		}
+	}
+
+	router := client.NewRouter(client.PolicyLeastInflight, backends)
+
+	return client.NewProviderLLM(router, model, client.WithContextWindow(contextWindow))
+}
+
 // The system prompt for the model so it behaves as expected.
 const systemPrompt = `You are a helpful coding assistant that has tools to assist
 you in coding.
@@ -161,14 +189,10 @@ Reasoning: high
 
 // Run starts the agent and runs the chat loop.
 func (a *Agent) Run(ctx context.Context) error {
-	var conversation []client.D // History of the conversation
-	var reasonContent []string  // Reasoning content per model call
-	var inToolCall bool         // Need to know we are inside a tool call request
+	var reasonContent []string // Reasoning content per model call
+	var inToolCall bool        // Need to know we are inside a tool call request
 
-	conversation = append(conversation, client.D{
-		"role":    "system",
-		"content": systemPrompt,
-	})
+	conversation := client.NewConversation(systemPrompt) // History of the conversation
 
 	fmt.Printf("\nChat with %s (use 'ctrl-c' to quit)\n", model)
 
@@ -186,10 +210,7 @@ func (a *Agent) Run(ctx context.Context) error {
 				break
 			}
 
-			conversation = append(conversation, client.D{
-				"role":    "user",
-				"content": userInput,
-			})
+			conversation.Add(client.UserMessage(userInput))
 		}
 
 		inToolCall = false
@@ -222,24 +243,15 @@ func (a *Agent) Run(ctx context.Context) error {
 		// Now we will make a call to the model, we could be responding to a
 		// tool call or providing a user request.
 
-		d := client.D{
-			"model":          model,
-			"messages":       conversation,
-			"max_tokens":     contextWindow,
-			"temperature":    0.0,
-			"top_p":          0.1,
-			"top_k":          1,
-			"stream":         true,
-			"tools":          a.toolDocuments,
-			"tool_selection": "auto",
-		}
-
 		fmt.Printf("\u001b[93m\n%s\u001b[0m: 0.000", model)
 
-		ch := make(chan client.ChatSSE, 100)
 		ctx, cancelDoCall := context.WithTimeout(ctx, time.Minute*5)
 
-		if err := a.sseClient.Do(ctx, http.MethodPost, url, d, ch); err != nil {
+		params := client.WithParams(0.0, 0.1, 1)
+		tools := client.WithTools(a.toolDocuments)
+
+		stream, err := a.llm.ChatStream(ctx, conversation, params, tools)
+		if err != nil {
 			fmt.Printf("\n\n\u001b[91mERROR:%s\u001b[0m\n\n", err)
 			inToolCall = false
 			cancelDoCall()
@@ -249,21 +261,20 @@ func (a *Agent) Run(ctx context.Context) error {
 		// ---------------------------------------------------------------------
 		// Now we will make a call to the model.
 
-		var chunks []string      // Store the response chunks since we are streaming.
-		reasonThinking := false  // GPT models provide a Reasoning field.
-		contentThinking := false // Other reasoning models use <think> tags.
-		reasonContent = nil      // Reset the reasoning content for this next call.
+		reasonThinking := false // Track if we are displaying reasoning.
+		reasonContent = nil     // Reset the reasoning content for this next call.
 
 		// ---------------------------------------------------------------------
-		// Process the response which comes in as chunks. So we need to process
-		// and save each chunk.
+		// Process the response which comes in as typed events. The reasoning
+		// is separated from the content for us, even when the model uses
+		// <think> tags, and tool calls are provided once they are complete.
 
 		waitingForResponse := true
+		var response client.Message
 
-		for resp := range ch {
-			if len(resp.Choices) == 0 {
-				continue
-			}
+		events := client.ChatEvents(ctx, stream)
+
+		for event := range events.Events() {
 
 			// Check if this is the first response. If it is, we will shutdown
 			// the G displaying the latency.
@@ -273,87 +284,68 @@ func (a *Agent) Run(ctx context.Context) error {
 				wg.Wait()
 			}
 
-			switch {
+			switch e := event.(type) {
 
-			// Did the model ask us to execute a tool call?
-			case len(resp.Choices[0].Delta.ToolCalls) > 0:
-				fmt.Print("\n\n")
-
-				toolCall := resp.Choices[0].Delta.ToolCalls[0]
-
-				conversation = a.addToConversation(reasonContent, conversation, client.D{
-					"role": "assistant",
-					"content": fmt.Sprintf("Tool call %s: %s(%v)",
-						toolCall.ID,
-						toolCall.Function.Name,
-						toolCall.Function.Arguments),
-				})
-
-				results := a.callTools(ctx, resp.Choices[0].Delta.ToolCalls)
-				if len(results) > 0 {
-					conversation = a.addToConversation(reasonContent, conversation, results...)
-					inToolCall = true
+			// Did we get reasoning content? Display it as a different color.
+			case client.ReasoningDelta:
+				if !reasonThinking {
+					reasonThinking = true
+					if len(reasonContent) == 0 {
+						fmt.Print("\n")
+					}
 				}
 
-			// Did we get content? With some models a <think> tag could exist to
-			// indicate reasoning. We need to filter that out and display it as
-			// a different color.
-			case resp.Choices[0].Delta.Content != "":
+				reasonContent = append(reasonContent, e.Text)
+				fmt.Printf("\u001b[91m%s\u001b[0m", e.Text)
+
+			// Did we get content?
+			case client.ContentDelta:
 				if reasonThinking {
 					reasonThinking = false
 					fmt.Print("\n\n")
 				}
 
-				switch resp.Choices[0].Delta.Content {
-				case "<think>":
-					contentThinking = true
-					continue
-				case "</think>":
-					contentThinking = false
-					continue
-				}
-
-				switch {
-				case !contentThinking:
-					fmt.Print(resp.Choices[0].Delta.Content)
-					chunks = append(chunks, resp.Choices[0].Delta.Content)
+				fmt.Print(e.Text)
 
-				case contentThinking:
-					reasonContent = append(reasonContent, resp.Choices[0].Delta.Content)
-					fmt.Printf("\u001b[91m%s\u001b[0m", resp.Choices[0].Delta.Content)
-				}
+			// Did the model ask us to execute a tool call?
+			case client.ToolCallComplete:
+				fmt.Print("\n\n")
 
-			// Did we get reasoning content? ChatGPT models provide reasoning in
-			// the Delta.Reasoning field. Display it as a different color.
-			case resp.Choices[0].Delta.Reasoning != "":
-				reasonThinking = true
+				a.addToConversation(reasonContent, conversation, client.ToolCallMessage(e.ToolCalls))
 
-				if len(reasonContent) == 0 {
-					fmt.Print("\n")
+				results := a.callTools(ctx, e.ToolCalls)
+				if len(results) > 0 {
+					a.addToConversation(reasonContent, conversation, results...)
+					inToolCall = true
 				}
 
-				reasonContent = append(reasonContent, resp.Choices[0].Delta.Reasoning)
-				fmt.Printf("\u001b[91m%s\u001b[0m", resp.Choices[0].Delta.Reasoning)
+			// The response is complete.
+			case client.Done:
+				response = e.Message
+
+			case client.ErrorEvent:
+				fmt.Printf("\n\n\u001b[91mERROR:%s\u001b[0m\n\n", e.Err)
 			}
 		}
 
 		cancelDoCall()
 
+		// The usage reported by the model tells us how many tokens were
+		// actually used by the requests made with this conversation.
+		usage := conversation.Usage()
+		fmt.Printf("\n\u001b[90mUsage Prompt[%d] Completion[%d] Reasoning[%d] Cached[%d]\u001b[0m\n", usage.PromptTokens, usage.CompletionTokens, usage.ReasoningTokens, usage.CachedTokens)
+
 		// ---------------------------------------------------------------------
-		// We processed all the chunks from the response so we need to add
+		// We processed all the events from the response so we need to add
 		// this to the conversation history.
 
-		if !inToolCall && len(chunks) > 0 {
+		if !inToolCall && response.Content != "" {
 			fmt.Print("\n")
 
-			content := strings.Join(chunks, " ")
-			content = strings.TrimLeft(content, "\n")
+			content := strings.TrimLeft(response.Content, "\n")
 
 			if content != "" {
-				conversation = a.addToConversation(reasonContent, conversation, client.D{
-					"role":    "assistant",
-					"content": content,
-				})
+				a.addToConversation(reasonContent, conversation, client.AssistantMessage(content))
 			}
 		}
 	}
@@ -365,15 +357,15 @@ func (a *Agent) Run(ctx context.Context) error {
 // calculate the different tokens used in the conversation and display it to the
 // user. It will also check the amount of input tokens currently in history
 // and remove the oldest messages if we are over.
-func (a *Agent) addToConversation(reasoning []string, conversation []client.D, newMessages ...client.D) []client.D {
-	conversation = append(conversation, newMessages...)
+func (a *Agent) addToConversation(reasoning []string, conversation *client.Conversation, newMessages ...client.Message) {
+	conversation.Add(newMessages...)
 
 	fmt.Print("\n")
 
 	for {
 		var currentWindow int
-		for _, msg := range conversation {
-			currentWindow += a.tke.TokenCount(msg["content"].(string))
+		for _, msg := range conversation.Messages {
+			currentWindow += a.tke.TokenCount(msg.Content)
 		}
 
 		r := strings.Join(reasoning, " ")
@@ -390,19 +382,17 @@ func (a *Agent) addToConversation(reasoning []string, conversation []client.D, n
 
 		if currentWindow > contextWindow {
 			fmt.Print("\u001b[90mRemoving conversation history\u001b[0m\n")
-			conversation = slices.Delete(conversation, 1, 2)
+			conversation.Messages = slices.Delete(conversation.Messages, 1, 2)
 			continue
 		}
 
 		break
 	}
-
-	return conversation
 }
 
 // callTools will lookup a requested tool by name and call it.
-func (a *Agent) callTools(ctx context.Context, toolCalls []client.ToolCall) []client.D {
-	var resps []client.D
+func (a *Agent) callTools(ctx context.Context, toolCalls []client.ToolCall) []client.Message {
+	var resps []client.Message
 
 	for _, toolCall := range toolCalls {
 		tool, exists := a.tools[toolCall.Function.Name]
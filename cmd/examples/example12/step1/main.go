@@ -47,14 +47,31 @@ var (
 	frameWidth       = 640
 	frameHeight      = 360
 
+	// The number of requests the vision model is asked to handle at the
+	// same time, which should match the parallelism Ollama is using.
+	numParallel = 1
+
 	videoFileName = "test_rag_video.mp4"
 	videoDir      = "zarf/samples/videos/"
 	framesDir     = "frames"
+
+	// The context window represents the maximum number of tokens that can be sent
+	// and received by the model. The default for Ollama is 4K. The makefile
+	// sets it with OLLAMA_CONTEXT_LENGTH.
+	contextWindow = 1024 * 4
 )
 
 var ErrFFMPEG = errors.New("ffmpeg error")
 
 func init() {
+	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if v := os.Getenv("LLM_VISION_SERVER"); v != "" {
 		urlVision = v
 	}
@@ -70,6 +87,12 @@ func init() {
 	if v := os.Getenv("LLM_TEXT_EMBED_MODEL"); v != "" {
 		modelTextEmbed = v
 	}
+
+	if v := os.Getenv("OLLAMA_NUM_PARALLEL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			numParallel = n
+		}
+	}
 }
 
 const promptKeyFrameDesc = `
@@ -84,20 +107,10 @@ const promptKeyFrameDesc = `
 	Do not modify, enhance, or change any of the text you see.
 	Do not add any new text that isn't part of the image.
 	Keep any spacing or formatting of the text as it appears in the image.
-	Place the text under the TEXT section in the final response.
-
-	Provide the response using the following format with the provided section headers (** JSON DOCUMENT and ** TEXT):
+	Place the text in the text field of the response.
 
-		** JSON DOCUMENT
-		{
-			"description": "<image description>",
-			"classification": "<image classification>"
-		}
-		
-		** TEXT
-
-	Encode any special characters that will be part of a JSON document.
-	Make sure all text to be placed inside a JSON document is properly encoded and that the JSON is valid.
+	Provide the response as a JSON document with the description, classification
+	and text fields.
 `
 
 // =============================================================================
@@ -110,6 +123,14 @@ type keyFrame struct {
 	embedding      []float64
 }
 
+// keyFrameDescription is the structured response we want from the vision
+// model for each key frame.
+type keyFrameDescription struct {
+	Description    string `json:"description"`
+	Classification string `json:"classification"`
+	Text           string `json:"text"`
+}
+
 // =============================================================================
 
 func main() {
@@ -123,8 +144,15 @@ func run() error {
 
 	// -------------------------------------------------------------------------
 
-	llmVision := client.NewLLM(urlVision, modelVision)
-	llmTextEmbed := client.NewLLM(urlTextEmbed, modelTextEmbed)
+	llmVision, err := client.NewLLM(urlVision, modelVision, client.WithMaxInflight(numParallel), client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
+
+	llmTextEmbed, err := client.NewLLM(urlTextEmbed, modelTextEmbed, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
 
 	fmt.Print("\n---\n")
 
@@ -397,27 +425,17 @@ func createKeyFrameFiles(videoChunkFile string) error {
 func createKeyFrameDescriptions(keyFrames []keyFrame, llmVision *client.LLM) error {
 	fmt.Printf("Creating key frame descriptions: %d\n", len(keyFrames))
 
-	semaphore := 1
-	v := os.Getenv("OLLAMA_NUM_PARALLEL")
-	if v != "" {
-		var err error
-		semaphore, err = strconv.Atoi(v)
-		if err != nil {
-			semaphore = 1
-		}
-	}
+	// The LLM limits the requests in flight, so the frames wait in its queue
+	// and the timeout covers every round of requests.
+	rounds := (len(keyFrames) + numParallel - 1) / numParallel
 
-	ch := make(chan bool, semaphore)
+	ctx, cancel := context.WithTimeout(context.Background(), frameDescTimeout*time.Duration(rounds))
+	defer cancel()
 
 	var g errgroup.Group
 
 	for i, keyFrame := range keyFrames {
 		g.Go(func() error {
-			ch <- true
-			defer func() {
-				<-ch
-			}()
-
 			fmt.Printf("\t- Creating key frame description: %s\n", filepath.Base(keyFrame.fileName))
 
 			image, mimeType, err := readImage(keyFrame.fileName)
@@ -425,33 +443,17 @@ func createKeyFrameDescriptions(keyFrames []keyFrame, llmVision *client.LLM) err
 				return fmt.Errorf("read image: %w", err)
 			}
 
-			ctx, cancel := context.WithTimeout(context.Background(), frameDescTimeout)
-			defer cancel()
-
-			p1 := client.WithImage(mimeType, image)
-			p2 := client.WithParams(0.0, 0.1, 1)
+			conv := client.NewConversation("")
+			conv.Add(client.UserMessage(promptKeyFrameDesc, client.ImagePart(mimeType, image)))
 
-			response, err := llmVision.ChatCompletions(ctx, promptKeyFrameDesc, p1, p2)
+			descr, err := client.ChatStructured[keyFrameDescription](ctx, llmVision, conv, client.WithParams(0.0, 0.1, 1))
 			if err != nil {
-				return fmt.Errorf("chat completions: %w", err)
-			}
-
-			jsonDoc, text, err := extractParts(response)
-			if err != nil {
-				return fmt.Errorf("extract parts: %w: %s", err, response)
-			}
-
-			var descr struct {
-				Description    string `json:"description"`
-				Classification string `json:"classification"`
-			}
-			if err := json.Unmarshal([]byte(jsonDoc), &descr); err != nil {
-				return fmt.Errorf("unmarshal: %w: %s", err, jsonDoc)
+				return fmt.Errorf("chat structured: %w", err)
 			}
 
 			keyFrames[i].description = descr.Description
 			keyFrames[i].classification = descr.Classification
-			keyFrames[i].text = text
+			keyFrames[i].text = descr.Text
 
 			return nil
 		})
@@ -469,34 +471,6 @@ func createKeyFrameDescriptions(keyFrames []keyFrame, llmVision *client.LLM) err
 	return nil
 }
 
-func extractParts(description string) (string, string, error) {
-	jsonMarker := "** JSON DOCUMENT"
-	codeMarker := "** TEXT"
-
-	jsonStart := strings.Index(description, jsonMarker)
-	if jsonStart == -1 {
-		return "", "", fmt.Errorf("JSON document marker not found")
-	}
-
-	codeStart := strings.Index(description, codeMarker)
-	if codeStart == -1 {
-		// If no source code marker, just return JSON part and empty code
-		jsonDoc := strings.TrimSpace(description[jsonStart+len(jsonMarker):])
-		return jsonDoc, "", nil
-	}
-
-	jsonDoc := strings.TrimSpace(description[jsonStart+len(jsonMarker) : codeStart])
-	sourceCode := strings.TrimSpace(description[codeStart+len(codeMarker):])
-
-	jsonDoc = strings.Trim(jsonDoc, "`")
-	jsonDoc = strings.TrimPrefix(jsonDoc, "json")
-
-	sourceCode = strings.Trim(sourceCode, "`")
-	sourceCode = strings.TrimPrefix(sourceCode, "go")
-
-	return jsonDoc, sourceCode, nil
-}
-
 func getFilesFromDirectory(directoryPath string) ([]string, error) {
 	var files []string
 
@@ -16,7 +16,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"slices"
 	"strconv"
@@ -97,7 +96,7 @@ func run() error {
 
 // Tool describes the features which all tools must implement.
 type Tool interface {
-	Call(ctx context.Context, toolCall client.ToolCall) client.D
+	Call(ctx context.Context, toolCall client.ToolCall) client.Message
 }
 
 // =============================================================================
@@ -106,7 +105,6 @@ type Tool interface {
 type Agent struct {
 	chatClient      *client.LLM
 	textEmbedClient *client.LLM
-	sseClient       *client.SSEClient[client.ChatSSE]
 	col             *mongo.Collection
 	getUserMessage  func() (string, bool)
 	tke             *tiktoken.Tiktoken
@@ -141,12 +139,21 @@ func NewAgent(getUserMessage func() (string, bool)) (*Agent, error) {
 	// -------------------------------------------------------------------------
 	// Construct the agent.
 
+	chatClient, err := client.NewLLM(urlChat, modelChat, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat llm: %w", err)
+	}
+
+	textEmbedClient, err := client.NewLLM(urlTextEmbed, modelTextEmbed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embed llm: %w", err)
+	}
+
 	tools := map[string]Tool{}
 
 	agent := Agent{
-		chatClient:      client.NewLLM(urlChat, modelChat),
-		textEmbedClient: client.NewLLM(urlTextEmbed, modelTextEmbed),
-		sseClient:       client.NewSSE[client.ChatSSE](client.StdoutLogger),
+		chatClient:      chatClient,
+		textEmbedClient: textEmbedClient,
 		col:             col,
 		getUserMessage:  getUserMessage,
 		tke:             tke,
@@ -177,14 +184,10 @@ Reasoning: high
 
 // Run starts the agent and runs the chat loop.
 func (a *Agent) Run(ctx context.Context) error {
-	var conversation []client.D // History of the conversation
-	var reasonContent []string  // Reasoning content per model call
-	var inToolCall bool         // Need to know we are inside a tool call request
+	var reasonContent []string // Reasoning content per model call
+	var inToolCall bool        // Need to know we are inside a tool call request
 
-	conversation = append(conversation, client.D{
-		"role":    "system",
-		"content": systemPrompt,
-	})
+	conversation := client.NewConversation(systemPrompt) // History of the conversation
 
 	fmt.Printf("\nChat with %s (use 'ctrl-c' to quit)\n", modelChat)
 
@@ -208,10 +211,7 @@ func (a *Agent) Run(ctx context.Context) error {
 				continue
 			}
 
-			conversation = append(conversation, client.D{
-				"role":    "user",
-				"content": userInput,
-			})
+			conversation.Add(client.UserMessage(userInput))
 		}
 
 		inToolCall = false
@@ -244,24 +244,15 @@ func (a *Agent) Run(ctx context.Context) error {
 		// Now we will make a call to the model, we could be responding to a
 		// tool call or providing a user request.
 
-		d := client.D{
-			"model":          modelChat,
-			"messages":       conversation,
-			"max_tokens":     contextWindow,
-			"temperature":    0.0,
-			"top_p":          0.1,
-			"top_k":          1,
-			"stream":         true,
-			"tools":          a.toolDocuments,
-			"tool_selection": "auto",
-		}
-
 		fmt.Printf("\u001b[93m\n%s\u001b[0m: 0.000", modelChat)
 
-		ch := make(chan client.ChatSSE, 100)
 		ctx, cancelDoCall := context.WithTimeout(ctx, time.Minute*5)
 
-		if err := a.sseClient.Do(ctx, http.MethodPost, urlChat, d, ch); err != nil {
+		params := client.WithParams(0.0, 0.1, 1)
+		tools := client.WithTools(a.toolDocuments)
+
+		stream, err := a.chatClient.ChatStream(ctx, conversation, params, tools)
+		if err != nil {
 			fmt.Printf("\n\n\u001b[91mERROR:%s\u001b[0m\n\n", err)
 			inToolCall = false
 			cancelDoCall()
@@ -271,21 +262,20 @@ func (a *Agent) Run(ctx context.Context) error {
 		// ---------------------------------------------------------------------
 		// Now we will make a call to the model.
 
-		var chunks []string      // Store the response chunks since we are streaming.
-		reasonThinking := false  // GPT models provide a Reasoning field.
-		contentThinking := false // Other reasoning models use <think> tags.
-		reasonContent = nil      // Reset the reasoning content for this next call.
+		reasonThinking := false // Track if we are displaying reasoning.
+		reasonContent = nil     // Reset the reasoning content for this next call.
 
 		// ---------------------------------------------------------------------
-		// Process the response which comes in as chunks. So we need to process
-		// and save each chunk.
+		// Process the response which comes in as typed events. The reasoning
+		// is separated from the content for us, even when the model uses
+		// <think> tags, and tool calls are provided once they are complete.
 
 		waitingForResponse := true
+		var response client.Message
 
-		for resp := range ch {
-			if len(resp.Choices) == 0 {
-				continue
-			}
+		events := client.ChatEvents(ctx, stream)
+
+		for event := range events.Events() {
 
 			// Check if this is the first response. If it is, we will shutdown
 			// the G displaying the latency.
@@ -295,87 +285,63 @@ func (a *Agent) Run(ctx context.Context) error {
 				wg.Wait()
 			}
 
-			switch {
-
-			// Did the model ask us to execute a tool call?
-			case len(resp.Choices[0].Delta.ToolCalls) > 0:
-				fmt.Print("\n\n")
+			switch e := event.(type) {
 
-				toolCall := resp.Choices[0].Delta.ToolCalls[0]
-
-				conversation = a.addToConversation(reasonContent, conversation, client.D{
-					"role": "assistant",
-					"content": fmt.Sprintf("Tool call %s: %s(%v)",
-						toolCall.ID,
-						toolCall.Function.Name,
-						toolCall.Function.Arguments),
-				})
-
-				results := a.callTools(ctx, resp.Choices[0].Delta.ToolCalls)
-				if len(results) > 0 {
-					conversation = a.addToConversation(reasonContent, conversation, results...)
-					inToolCall = true
+			// Did we get reasoning content? Display it as a different color.
+			case client.ReasoningDelta:
+				if !reasonThinking {
+					reasonThinking = true
+					if len(reasonContent) == 0 {
+						fmt.Print("\n")
+					}
 				}
 
-			// Did we get content? With some models a <think> tag could exist to
-			// indicate reasoning. We need to filter that out and display it as
-			// a different color.
-			case resp.Choices[0].Delta.Content != "":
+				reasonContent = append(reasonContent, e.Text)
+				fmt.Printf("\u001b[91m%s\u001b[0m", e.Text)
+
+			// Did we get content?
+			case client.ContentDelta:
 				if reasonThinking {
 					reasonThinking = false
 					fmt.Print("\n\n")
 				}
 
-				switch resp.Choices[0].Delta.Content {
-				case "<think>":
-					contentThinking = true
-					continue
-				case "</think>":
-					contentThinking = false
-					continue
-				}
-
-				switch {
-				case !contentThinking:
-					fmt.Print(resp.Choices[0].Delta.Content)
-					chunks = append(chunks, resp.Choices[0].Delta.Content)
+				fmt.Print(e.Text)
 
-				case contentThinking:
-					reasonContent = append(reasonContent, resp.Choices[0].Delta.Content)
-					fmt.Printf("\u001b[91m%s\u001b[0m", resp.Choices[0].Delta.Content)
-				}
+			// Did the model ask us to execute a tool call?
+			case client.ToolCallComplete:
+				fmt.Print("\n\n")
 
-			// Did we get reasoning content? ChatGPT models provide reasoning in
-			// the Delta.Reasoning field. Display it as a different color.
-			case resp.Choices[0].Delta.Reasoning != "":
-				reasonThinking = true
+				a.addToConversation(reasonContent, conversation, client.ToolCallMessage(e.ToolCalls))
 
-				if len(reasonContent) == 0 {
-					fmt.Print("\n")
+				results := a.callTools(ctx, e.ToolCalls)
+				if len(results) > 0 {
+					a.addToConversation(reasonContent, conversation, results...)
+					inToolCall = true
 				}
 
-				reasonContent = append(reasonContent, resp.Choices[0].Delta.Reasoning)
-				fmt.Printf("\u001b[91m%s\u001b[0m", resp.Choices[0].Delta.Reasoning)
+			// The response is complete.
+			case client.Done:
+				response = e.Message
+
+			case client.ErrorEvent:
+				fmt.Printf("\n\n\u001b[91mERROR:%s\u001b[0m\n\n", e.Err)
 			}
 		}
 
 		cancelDoCall()
 
 		// ---------------------------------------------------------------------
-		// We processed all the chunks from the response so we need to add
+		// We processed all the events from the response so we need to add
 		// this to the conversation history.
 
-		if !inToolCall && len(chunks) > 0 {
+		if !inToolCall && response.Content != "" {
 			fmt.Print("\n")
 
-			content := strings.Join(chunks, " ")
-			content = strings.TrimLeft(content, "\n")
+			content := strings.TrimLeft(response.Content, "\n")
 
 			if content != "" {
-				conversation = a.addToConversation(reasonContent, conversation, client.D{
-					"role":    "assistant",
-					"content": content,
-				})
+				a.addToConversation(reasonContent, conversation, client.AssistantMessage(content))
 			}
 		}
 	}
@@ -384,7 +350,7 @@ func (a *Agent) Run(ctx context.Context) error {
 }
 
 // injectContext will add context to the user input based on the search results.
-func (a *Agent) injectContext(ctx context.Context, conversation []client.D, userInput string) (string, error) {
+func (a *Agent) injectContext(ctx context.Context, conversation *client.Conversation, userInput string) (string, error) {
 	yes, err := a.isQuestionRelevant(ctx, conversation, userInput)
 	if err != nil {
 		return "", fmt.Errorf("failed to check if search is needed: %w", err)
@@ -418,7 +384,7 @@ func (a *Agent) injectContext(ctx context.Context, conversation []client.D, user
 
 // isQuestionRelevant will check if the user input is relevant to the Go API
 // service development class.
-func (a *Agent) isQuestionRelevant(ctx context.Context, conversation []client.D, userInput string) (bool, error) {
+func (a *Agent) isQuestionRelevant(ctx context.Context, conversation *client.Conversation, userInput string) (bool, error) {
 	const prompt = `
 	You are a relevance filter for Bill's Go API service development class. 
 	Determine if the following question with the current message
@@ -445,9 +411,9 @@ func (a *Agent) isQuestionRelevant(ctx context.Context, conversation []client.D,
 `
 
 	var history string
-	start := max(len(conversation)-2, 0)
-	for _, msg := range conversation[start:] {
-		history += msg["content"].(string) + "\n"
+	start := max(conversation.Len()-2, 0)
+	for _, msg := range conversation.Messages[start:] {
+		history += msg.Content + "\n"
 	}
 
 	text := fmt.Sprintf(prompt, history, userInput)
@@ -470,15 +436,15 @@ func (a *Agent) isQuestionRelevant(ctx context.Context, conversation []client.D,
 // calculate the different tokens used in the conversation and display it to the
 // user. It will also check the amount of input tokens currently in history
 // and remove the oldest messages if we are over.
-func (a *Agent) addToConversation(reasoning []string, conversation []client.D, newMessages ...client.D) []client.D {
-	conversation = append(conversation, newMessages...)
+func (a *Agent) addToConversation(reasoning []string, conversation *client.Conversation, newMessages ...client.Message) {
+	conversation.Add(newMessages...)
 
 	fmt.Print("\n")
 
 	for {
 		var currentWindow int
-		for _, msg := range conversation {
-			currentWindow += a.tke.TokenCount(msg["content"].(string))
+		for _, msg := range conversation.Messages {
+			currentWindow += a.tke.TokenCount(msg.Content)
 		}
 
 		r := strings.Join(reasoning, " ")
@@ -495,19 +461,17 @@ func (a *Agent) addToConversation(reasoning []string, conversation []client.D, n
 
 		if currentWindow > contextWindow {
 			fmt.Print("\u001b[90mRemoving conversation history\u001b[0m\n")
-			conversation = slices.Delete(conversation, 1, 2)
+			conversation.Messages = slices.Delete(conversation.Messages, 1, 2)
 			continue
 		}
 
 		break
 	}
-
-	return conversation
 }
 
 // callTools will lookup a requested tool by name and call it.
-func (a *Agent) callTools(ctx context.Context, toolCalls []client.ToolCall) []client.D {
-	var resps []client.D
+func (a *Agent) callTools(ctx context.Context, toolCalls []client.ToolCall) []client.Message {
+	var resps []client.Message
 
 	for _, toolCall := range toolCalls {
 		tool, exists := a.tools[toolCall.Function.Name]
@@ -6,6 +6,7 @@ import (
 
 	"github.com/ardanlabs/ai-training/foundation/client"
 	"github.com/ardanlabs/ai-training/foundation/mongodb"
+	"github.com/ardanlabs/ai-training/foundation/vector"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -58,15 +59,34 @@ func initDB(ctx context.Context, client *mongo.Client) (*mongo.Collection, error
 }
 
 func textVectorSearch(ctx context.Context, llm *client.LLM, col *mongo.Collection, question string) ([]searchResult, error) {
-	vector, err := llm.EmbedText(ctx, question)
+	queryVector, err := llm.EmbedText(ctx, question)
 	if err != nil {
 		return nil, fmt.Errorf("embedText: %w", err)
 	}
 
-	return vectorSearch(ctx, col, vector)
+	// Neighbouring chunks of a video transcript are often near duplicates.
+	// Fetch more candidates than needed and use MMR to select chunks that
+	// are relevant to the question but different from each other.
+	const (
+		limitResults  = 2
+		numCandidates = 10
+		lambda        = 0.7
+	)
+
+	candidates, err := vectorSearch(ctx, col, queryVector, numCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := vector.MMR(queryVector, candidates, func(r searchResult) []float64 { return r.Embedding }, limitResults, lambda)
+	if err != nil {
+		return nil, fmt.Errorf("mmr: %w", err)
+	}
+
+	return results, nil
 }
 
-func vectorSearch(ctx context.Context, col *mongo.Collection, vector []float64) ([]searchResult, error) {
+func vectorSearch(ctx context.Context, col *mongo.Collection, vector []float64, limit int) ([]searchResult, error) {
 	pipeline := mongo.Pipeline{
 		{{
 			Key: "$vectorSearch",
@@ -75,7 +95,7 @@ func vectorSearch(ctx context.Context, col *mongo.Collection, vector []float64)
 				"exact":       true,
 				"path":        "embedding",
 				"queryVector": vector,
-				"limit":       2,
+				"limit":       limit,
 			}},
 		},
 		{{
@@ -0,0 +1,101 @@
+// This example shows you how to transcribe audio using the OpenAI-compatible
+// audio transcriptions endpoint served by whisper.cpp.
+//
+// # Running the example:
+//
+//	$ make example14
+//
+// # This requires running the following commands:
+//
+//	$ make whisper-up // This starts the whisper.cpp server.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+)
+
+var (
+	url   = "http://localhost:8178/v1/audio/transcriptions"
+	model = "whisper-1"
+
+	audioFile = "zarf/samples/audio/jfk.wav"
+
+	// The context window represents the maximum number of tokens that can be sent
+	// and received by the model. The default for Ollama is 4K. The makefile
+	// sets it with OLLAMA_CONTEXT_LENGTH.
+	contextWindow = 1024 * 4
+)
+
+func init() {
+	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if v := os.Getenv("LLM_TRANSCRIBE_SERVER"); v != "" {
+		url = v
+	}
+
+	if v := os.Getenv("LLM_TRANSCRIBE_MODEL"); v != "" {
+		model = v
+	}
+}
+
+// =============================================================================
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	llm, err := client.NewLLM(url, model, client.WithContextWindow(contextWindow))
+	if err != nil {
+		return fmt.Errorf("new llm: %w", err)
+	}
+
+	// -------------------------------------------------------------------------
+
+	f, err := os.Open(audioFile)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	transcription, err := llm.Transcribe(ctx, f, client.WithAudioFileName(audioFile))
+	if err != nil {
+		return fmt.Errorf("transcribe: %w", err)
+	}
+
+	// -------------------------------------------------------------------------
+
+	fmt.Println("TRANSCRIPTION:")
+	fmt.Print("-----------------------------------------------\n\n")
+	fmt.Printf("Language: %s\n", transcription.Language)
+	fmt.Printf("Duration: %s\n\n", transcription.Duration)
+	fmt.Println(transcription.Text)
+
+	fmt.Print("\nSEGMENTS:\n")
+	fmt.Print("-----------------------------------------------\n\n")
+
+	for _, seg := range transcription.Segments {
+		fmt.Printf("[%s - %s] %s\n", seg.Start, seg.End, seg.Text)
+	}
+
+	return nil
+}
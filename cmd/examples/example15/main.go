@@ -0,0 +1,165 @@
+// This example shows you how to use the HNSW approximate vector index and
+// how to measure its recall and speed against the exact index. Change the
+// number of vectors and the HNSW options to see how they trade recall for
+// speed.
+//
+// # Running the example:
+//
+//	$ make example15
+//
+// # This doesn't require any services to be running.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ardanlabs/ai-training/foundation/vector"
+)
+
+var (
+	numVectors = 10_000
+	numQueries = 200
+	dimensions = 128
+	k          = 10
+)
+
+func init() {
+	if v := os.Getenv("VECTOR_COUNT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			numVectors = n
+		}
+	}
+}
+
+// =============================================================================
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	rng := rand.New(rand.NewPCG(1, 1))
+
+	randomVector := func() []float64 {
+		v := make([]float64, dimensions)
+		for i := range v {
+			v[i] = rng.NormFloat64()
+		}
+		return v
+	}
+
+	exact := vector.NewIndex(vector.Cosine, dimensions)
+	approx := vector.NewHNSW(vector.Cosine, dimensions,
+		vector.WithM(16),
+		vector.WithEfConstruction(200),
+		vector.WithEfSearch(50),
+	)
+
+	// -------------------------------------------------------------------------
+
+	fmt.Printf("Inserting %d vectors of %d dimensions\n\n", numVectors, dimensions)
+
+	indexes := []struct {
+		name string
+		idx  vector.Searcher
+	}{
+		{"exact", exact},
+		{"hnsw", approx},
+	}
+
+	for _, index := range indexes {
+		start := time.Now()
+
+		rng = rand.New(rand.NewPCG(1, 1))
+		for i := range numVectors {
+			id := strconv.Itoa(i)
+			if err := index.idx.Add(id, randomVector(), nil); err != nil {
+				return fmt.Errorf("add: %w", err)
+			}
+		}
+
+		fmt.Printf("%-5s build: %v\n", index.name, time.Since(start).Round(time.Millisecond))
+	}
+
+	queries := make([][]float64, numQueries)
+	for i := range queries {
+		queries[i] = randomVector()
+	}
+
+	// -------------------------------------------------------------------------
+
+	fmt.Print("\nSEARCH:\n")
+	fmt.Print("-----------------------------------------------\n\n")
+
+	exactTime, err := timeSearches(exact, queries)
+	if err != nil {
+		return fmt.Errorf("exact: %w", err)
+	}
+
+	fmt.Printf("exact          recall: 1.000  avg query: %v\n", exactTime)
+
+	for _, ef := range []int{10, 50, 100, 200} {
+		approx.SetEfSearch(ef)
+
+		recall, err := vector.Recall(exact, approx, queries, k)
+		if err != nil {
+			return fmt.Errorf("recall: %w", err)
+		}
+
+		approxTime, err := timeSearches(approx, queries)
+		if err != nil {
+			return fmt.Errorf("approx: %w", err)
+		}
+
+		fmt.Printf("hnsw ef=%-4d   recall: %.3f  avg query: %v\n", ef, recall, approxTime)
+	}
+
+	// -------------------------------------------------------------------------
+
+	fmt.Print("\nSAVE AND LOAD:\n")
+	fmt.Print("-----------------------------------------------\n\n")
+
+	path := filepath.Join(os.TempDir(), "example15.hnsw")
+	defer os.Remove(path)
+
+	if err := approx.SaveFile(path); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+
+	loaded, err := vector.LoadHNSWFile(path)
+	if err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+
+	recall, err := vector.Recall(approx, loaded, queries, k)
+	if err != nil {
+		return fmt.Errorf("recall: %w", err)
+	}
+
+	fmt.Printf("Loaded %d vectors from %s, matching the saved index: %.3f\n", loaded.Len(), path, recall)
+
+	return nil
+}
+
+// timeSearches runs the queries against the index and returns the average
+// time per query.
+func timeSearches(idx vector.Searcher, queries [][]float64) (time.Duration, error) {
+	start := time.Now()
+
+	for _, q := range queries {
+		if _, err := idx.Search(q, k); err != nil {
+			return 0, err
+		}
+	}
+
+	return time.Since(start) / time.Duration(len(queries)), nil
+}
@@ -0,0 +1,167 @@
+// This example shows you how to quantize vector embeddings to save memory
+// and how much recall is lost compared to searching the float vectors. The
+// book embeddings are encoded as binary codes that are searched by their
+// Hamming distance, and the closest candidates are rescored using either
+// the float vectors or int8 codes.
+//
+// # Running the example:
+//
+//	$ make example16
+//
+// # This requires running the following commands:
+//
+//	$ make example06 // This creates the zarf/data/book.embeddings file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ardanlabs/ai-training/foundation/vector"
+)
+
+var (
+	embeddingsFile = "zarf/data/book.embeddings"
+
+	numQueries = 100
+	k          = 10
+)
+
+type document struct {
+	ID        int
+	Text      string
+	Embedding []float64
+}
+
+// =============================================================================
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	docs, err := loadDocuments(embeddingsFile)
+	if err != nil {
+		return fmt.Errorf("load documents: %w", err)
+	}
+
+	dimensions := len(docs[0].Embedding)
+
+	fmt.Printf("Loaded %d embeddings of %d dimensions\n", len(docs), dimensions)
+
+	// -------------------------------------------------------------------------
+
+	samples := make([][]float64, len(docs))
+	for i, doc := range docs {
+		samples[i] = doc.Embedding
+	}
+
+	sq, err := vector.CalibrateScalar(samples, vector.WithQuantile(0.999))
+	if err != nil {
+		return fmt.Errorf("calibrate scalar: %w", err)
+	}
+
+	bq, err := vector.CalibrateBinary(samples)
+	if err != nil {
+		return fmt.Errorf("calibrate binary: %w", err)
+	}
+
+	// -------------------------------------------------------------------------
+
+	exact := vector.NewIndex(vector.Cosine, dimensions)
+
+	indexes := []struct {
+		name     string
+		bytes    int
+		searcher vector.Searcher
+	}{
+		{"binary x1  + float", dimensions/8 + dimensions*8, vector.NewBinaryIndex(vector.Cosine, bq, vector.WithRescoreFactor(1))},
+		{"binary x4  + float", dimensions/8 + dimensions*8, vector.NewBinaryIndex(vector.Cosine, bq)},
+		{"binary x4  + int8", dimensions/8 + dimensions, vector.NewBinaryIndex(vector.Cosine, bq, vector.WithScalarRescoring(sq))},
+		{"binary x10 + int8", dimensions/8 + dimensions, vector.NewBinaryIndex(vector.Cosine, bq, vector.WithScalarRescoring(sq), vector.WithRescoreFactor(10))},
+	}
+
+	for _, doc := range docs {
+		id := strconv.Itoa(doc.ID)
+
+		if err := exact.Add(id, doc.Embedding, nil); err != nil {
+			return fmt.Errorf("add: %w", err)
+		}
+
+		for _, idx := range indexes {
+			if err := idx.searcher.Add(id, doc.Embedding, nil); err != nil {
+				return fmt.Errorf("add: %w", err)
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+
+	// Use a random set of the embeddings as the queries.
+	rng := rand.New(rand.NewPCG(1, 1))
+
+	queries := make([][]float64, min(numQueries, len(docs)))
+	for i, j := range rng.Perm(len(docs))[:len(queries)] {
+		queries[i] = docs[j].Embedding
+	}
+
+	fmt.Print("\nRECALL:\n")
+	fmt.Print("-----------------------------------------------\n\n")
+
+	fmt.Printf("%-20s bytes/vector: %5d  recall@%d: 1.000\n", "float64", dimensions*8, k)
+
+	for _, idx := range indexes {
+		start := time.Now()
+
+		recall, err := vector.Recall(exact, idx.searcher, queries, k)
+		if err != nil {
+			return fmt.Errorf("recall: %w", err)
+		}
+
+		fmt.Printf("%-20s bytes/vector: %5d  recall@%d: %.3f  (%v)\n", idx.name, idx.bytes, k, recall, time.Since(start).Round(time.Millisecond))
+	}
+
+	return nil
+}
+
+func loadDocuments(fileName string) ([]document, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w (run make example06 first)", err)
+	}
+	defer f.Close()
+
+	var docs []document
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var doc document
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			return nil, fmt.Errorf("unmarshal: %w", err)
+		}
+
+		docs = append(docs, doc)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+
+	if len(docs) == 0 {
+		return nil, errors.New("no documents found")
+	}
+
+	return docs, nil
+}
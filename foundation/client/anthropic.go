@@ -0,0 +1,610 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const anthropicVersion = "2023-06-01"
+
+// Anthropic implements the Provider interface for the Anthropic Messages
+// API. Conversations, tools and streamed responses are converted between the
+// OpenAI-compatible shapes used by this package and the content blocks used
+// by the Messages API.
+type Anthropic struct {
+	cln  *Client
+	host string
+}
+
+// NewAnthropic constructs a provider for the Messages API on the specified
+// host, for example https://api.anthropic.com.
+func NewAnthropic(log Logger, host string, apiKey string, options ...func(cln *Client)) *Anthropic {
+	options = append([]func(cln *Client){
+		WithHeader("x-api-key", apiKey),
+		WithHeader("anthropic-version", anthropicVersion),
+	}, options...)
+
+	return &Anthropic{
+		cln:  New(log, options...),
+		host: strings.TrimSuffix(host, "/"),
+	}
+}
+
+// =============================================================================
+
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicBlock struct {
+	Type      string           `json:"type"`
+	Text      string           `json:"text,omitempty"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Signature string           `json:"signature,omitempty"`
+	Source    *anthropicSource `json:"source,omitempty"`
+	ID        string           `json:"id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Input     json.RawMessage  `json:"input,omitempty"`
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   string           `json:"content,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// toUsage converts the usage where the input tokens don't include the tokens
+// read from or written to the cache.
+func (u anthropicUsage) toUsage() Usage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+
+	return Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+		CachedTokens:     u.CacheReadInputTokens,
+	}
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Role       string           `json:"role"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+// Chat implements the Provider interface.
+func (a *Anthropic) Chat(ctx context.Context, req ChatRequest) (Message, error) {
+	d, err := a.messagesDocument(req, false)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var resp anthropicResponse
+	if err := a.cln.Do(ctx, http.MethodPost, a.host+"/v1/messages", d, &resp); err != nil {
+		return Message{}, fmt.Errorf("do: %w", err)
+	}
+
+	structured := structuredToolName(req)
+
+	msg := Message{
+		Role:  RoleAssistant,
+		Usage: resp.Usage.toUsage(),
+	}
+
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			msg.Content += block.Text
+
+		case "thinking":
+			msg.Reasoning += block.Thinking
+			msg.ReasoningSignature = block.Signature
+
+		case "tool_use":
+			if structured != "" && block.Name == structured {
+				msg.Content += string(block.Input)
+				continue
+			}
+
+			arguments := make(map[string]any)
+			if err := json.Unmarshal(block.Input, &arguments); err != nil {
+				return Message{}, fmt.Errorf("tool use %s: arguments: %w", block.Name, err)
+			}
+
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:    block.ID,
+				Index: len(msg.ToolCalls),
+				Type:  "function",
+				Function: Function{
+					Name:         block.Name,
+					Arguments:    arguments,
+					RawArguments: string(block.Input),
+				},
+			})
+		}
+	}
+
+	return msg, nil
+}
+
+// ChatStream implements the Provider interface. The Messages API events are
+// converted to OpenAI-compatible chunks, with tool input streamed as
+// argument fragments that can be merged by a ToolCallAccumulator.
+func (a *Anthropic) ChatStream(ctx context.Context, req ChatRequest) (*Stream[ChatSSE], error) {
+	d, err := a.messagesDocument(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := do(ctx, a.cln, http.MethodPost, a.host+"/v1/messages", d)
+	if err != nil {
+		return nil, fmt.Errorf("stream: %w", err)
+	}
+
+	s := NewStream(ctx, func(send func(ChatSSE) error) error {
+		defer resp.Body.Close()
+
+		var id, model string
+		var usage anthropicUsage
+		toolIndex := make(map[int]int)
+
+		// The input of the tool forced for a structured response is
+		// streamed as content.
+		structured := structuredToolName(req)
+		structuredIndex := -1
+
+		dec := NewEventDecoder(resp.Body)
+
+		for {
+			evt, err := dec.Decode()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return fmt.Errorf("read: %w", err)
+			}
+
+			var data struct {
+				Type    string `json:"type"`
+				Index   int    `json:"index"`
+				Message struct {
+					ID    string         `json:"id"`
+					Model string         `json:"model"`
+					Usage anthropicUsage `json:"usage"`
+				} `json:"message"`
+				ContentBlock anthropicBlock `json:"content_block"`
+				Delta        struct {
+					Type        string `json:"type"`
+					Text        string `json:"text"`
+					Thinking    string `json:"thinking"`
+					Signature   string `json:"signature"`
+					PartialJSON string `json:"partial_json"`
+					StopReason  string `json:"stop_reason"`
+				} `json:"delta"`
+				Usage anthropicUsage `json:"usage"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+
+			if err := json.Unmarshal([]byte(evt.Data), &data); err != nil {
+				return fmt.Errorf("decoding: %s: %w", evt.Data, err)
+			}
+
+			var delta ChatDeltaSSE
+			var finishReason string
+			var u *Usage
+
+			switch data.Type {
+			case "message_start":
+				id, model = data.Message.ID, data.Message.Model
+				usage = data.Message.Usage
+				delta.Role = RoleAssistant
+
+			case "content_block_start":
+				if data.ContentBlock.Type != "tool_use" {
+					continue
+				}
+
+				if structured != "" && data.ContentBlock.Name == structured {
+					structuredIndex = data.Index
+					continue
+				}
+
+				toolIndex[data.Index] = len(toolIndex)
+
+				delta.ToolCalls = []ToolCall{
+					{
+						ID:    data.ContentBlock.ID,
+						Index: toolIndex[data.Index],
+						Type:  "function",
+						Function: Function{
+							Name: data.ContentBlock.Name,
+						},
+					},
+				}
+
+			case "content_block_delta":
+				switch data.Delta.Type {
+				case "text_delta":
+					delta.Content = data.Delta.Text
+
+				case "thinking_delta":
+					delta.Reasoning = data.Delta.Thinking
+
+				case "signature_delta":
+					delta.ReasoningSignature = data.Delta.Signature
+
+				case "input_json_delta":
+					if data.Index == structuredIndex {
+						delta.Content = data.Delta.PartialJSON
+						break
+					}
+
+					delta.ToolCalls = []ToolCall{
+						{
+							Index: toolIndex[data.Index],
+							Function: Function{
+								RawArguments: data.Delta.PartialJSON,
+							},
+						},
+					}
+
+				default:
+					continue
+				}
+
+			case "message_delta":
+				if data.Delta.StopReason == "" {
+					continue
+				}
+				finishReason = finishReasonFromStopReason(data.Delta.StopReason)
+				if structuredIndex != -1 {
+					finishReason = "stop"
+				}
+
+				// The output tokens in the message delta are cumulative.
+				usage.OutputTokens = data.Usage.OutputTokens
+				v := usage.toUsage()
+				u = &v
+
+			case "message_stop":
+				return nil
+
+			case "error":
+				return newError(fmt.Sprintf("%s: %s", data.Error.Type, data.Error.Message))
+
+			default:
+				continue
+			}
+
+			v := ChatSSE{
+				ID:     id,
+				Object: "chat.completion.chunk",
+				Model:  model,
+				Choices: []ChatChoiceSSE{
+					{
+						Delta:        delta,
+						FinishReason: finishReason,
+					},
+				},
+				Usage: u,
+			}
+
+			if err := send(v); err != nil {
+				return err
+			}
+		}
+	})
+
+	return s, nil
+}
+
+// Embed implements the Provider interface. The Messages API doesn't provide
+// embeddings.
+func (a *Anthropic) Embed(ctx context.Context, req EmbedRequest) ([][]float64, error) {
+	return nil, fmt.Errorf("anthropic: embeddings: %w", ErrNotSupported)
+}
+
+// =============================================================================
+
+func (a *Anthropic) messagesDocument(req ChatRequest, stream bool) (D, error) {
+	if req.Logprobs {
+		return nil, fmt.Errorf("anthropic: logprobs: %w", ErrNotSupported)
+	}
+
+	var system []string
+	var messages []anthropicMessage
+
+	for i, msg := range req.Messages {
+		if msg.Role == RoleSystem {
+			system = append(system, msg.Content)
+			continue
+		}
+
+		role, blocks, err := toAnthropicBlocks(msg)
+		if err != nil {
+			return nil, fmt.Errorf("message[%d]: %w", i, err)
+		}
+
+		// The API rejects messages without content, like an assistant
+		// message that had nothing to say, so they are skipped.
+		if len(blocks) == 0 {
+			continue
+		}
+
+		// The API requires the roles to alternate, so consecutive messages
+		// with the same role, like multiple tool results, are merged.
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, blocks...)
+			continue
+		}
+
+		messages = append(messages, anthropicMessage{
+			Role:    role,
+			Content: blocks,
+		})
+	}
+
+	d := D{
+		"model":      req.Model,
+		"max_tokens": req.MaxTokens,
+		"messages":   messages,
+	}
+
+	if stream {
+		d["stream"] = true
+	}
+
+	if len(system) > 0 {
+		d["system"] = strings.Join(system, "\n\n")
+	}
+
+	if req.ThinkingBudget > 0 && req.ThinkingBudget >= req.MaxTokens {
+		return nil, fmt.Errorf("anthropic: thinking budget %d must be less than max tokens %d", req.ThinkingBudget, req.MaxTokens)
+	}
+
+	switch {
+	case req.ThinkingBudget > 0:
+		d["thinking"] = D{
+			"type":          "enabled",
+			"budget_tokens": req.ThinkingBudget,
+		}
+
+	default:
+		// The API doesn't accept temperature and top_p together, so top_p is
+		// only used when a temperature is not provided.
+		if v, exists := req.Params["temperature"]; exists {
+			d["temperature"] = v
+		} else if v, exists := req.Params["top_p"]; exists {
+			d["top_p"] = v
+		}
+
+		if v, exists := req.Params["top_k"]; exists {
+			d["top_k"] = v
+		}
+	}
+
+	// The penalties, seed and min_p have no equivalent and are dropped.
+	if v, exists := req.Params["stop"]; exists {
+		d["stop_sequences"] = v
+	}
+
+	var tools []D
+	if req.Tools != nil {
+		var err error
+		if tools, err = toAnthropicTools(req.Tools); err != nil {
+			return nil, err
+		}
+	}
+
+	// The API has no response format, so a schema is sent as a tool the
+	// model is forced to use, whose input is the structured response.
+	if req.Schema != nil {
+		if req.ThinkingBudget > 0 {
+			return nil, fmt.Errorf("anthropic: json schema with thinking: %w", ErrNotSupported)
+		}
+
+		name := structuredToolName(req)
+
+		tools = append(tools, D{
+			"name":         name,
+			"description":  "Respond with a JSON document that matches the input schema.",
+			"input_schema": req.Schema,
+		})
+
+		d["tool_choice"] = D{
+			"type": "tool",
+			"name": name,
+		}
+	}
+
+	if tools != nil {
+		d["tools"] = tools
+	}
+
+	return d, nil
+}
+
+// structuredToolName returns the name of the tool used for a structured
+// response, or an empty string when the request has no schema.
+func structuredToolName(req ChatRequest) string {
+	if req.Schema == nil {
+		return ""
+	}
+
+	if req.SchemaName != "" {
+		return req.SchemaName
+	}
+
+	return "structured_response"
+}
+
+// toAnthropicBlocks converts a message to the role and content blocks used
+// by the Messages API. Tool results are sent as user messages.
+func toAnthropicBlocks(msg Message) (string, []anthropicBlock, error) {
+	var blocks []anthropicBlock
+
+	switch msg.Role {
+	case RoleTool:
+		blocks = append(blocks, anthropicBlock{
+			Type:      "tool_result",
+			ToolUseID: msg.ToolCallID,
+			Content:   msg.Content,
+		})
+
+		return RoleUser, blocks, nil
+
+	case RoleAssistant:
+		if msg.Reasoning != "" && msg.ReasoningSignature != "" {
+			blocks = append(blocks, anthropicBlock{
+				Type:      "thinking",
+				Thinking:  msg.Reasoning,
+				Signature: msg.ReasoningSignature,
+			})
+		}
+	}
+
+	if msg.Content != "" {
+		blocks = append(blocks, anthropicBlock{
+			Type: "text",
+			Text: msg.Content,
+		})
+	}
+
+	for _, part := range msg.Parts {
+		switch part.Type {
+		case "text":
+			blocks = append(blocks, anthropicBlock{
+				Type: "text",
+				Text: part.Text,
+			})
+
+		case "image_url":
+			blocks = append(blocks, anthropicBlock{
+				Type:   "image",
+				Source: toAnthropicSource(part.ImageURL.URL),
+			})
+
+		case "file":
+			if part.File.FileData == "" {
+				return "", nil, fmt.Errorf("file id: %w", ErrNotSupported)
+			}
+
+			blocks = append(blocks, anthropicBlock{
+				Type:   "document",
+				Source: toAnthropicSource(part.File.FileData),
+			})
+
+		default:
+			return "", nil, fmt.Errorf("content part type %q: %w", part.Type, ErrNotSupported)
+		}
+	}
+
+	for _, tc := range msg.ToolCalls {
+		var input []byte
+
+		switch {
+		case tc.Function.Arguments != nil:
+			var err error
+			if input, err = json.Marshal(tc.Function.Arguments); err != nil {
+				return "", nil, fmt.Errorf("tool call %s: %w", tc.Function.Name, err)
+			}
+
+		case strings.TrimSpace(tc.Function.RawArguments) != "":
+			if !json.Valid([]byte(tc.Function.RawArguments)) {
+				return "", nil, fmt.Errorf("tool call %s: invalid arguments: %s", tc.Function.Name, tc.Function.RawArguments)
+			}
+			input = []byte(tc.Function.RawArguments)
+
+		default:
+			input = []byte("{}")
+		}
+
+		blocks = append(blocks, anthropicBlock{
+			Type:  "tool_use",
+			ID:    tc.ID,
+			Name:  tc.Function.Name,
+			Input: input,
+		})
+	}
+
+	return msg.Role, blocks, nil
+}
+
+// toAnthropicSource converts an image url, which can be a base64 encoded
+// data url, to an image source.
+func toAnthropicSource(url string) *anthropicSource {
+	header, data, found := strings.Cut(url, ";base64,")
+	if !found || !strings.HasPrefix(header, "data:") {
+		return &anthropicSource{
+			Type: "url",
+			URL:  url,
+		}
+	}
+
+	return &anthropicSource{
+		Type:      "base64",
+		MediaType: strings.TrimPrefix(header, "data:"),
+		Data:      data,
+	}
+}
+
+// toAnthropicTools converts the OpenAI-compatible tool documents to the tool
+// definitions used by the Messages API.
+func toAnthropicTools(tools []D) ([]D, error) {
+	defs := make([]D, len(tools))
+
+	for i, tool := range tools {
+		fn, ok := tool["function"].(D)
+		if !ok {
+			return nil, fmt.Errorf("tool[%d]: missing function document", i)
+		}
+
+		def := D{
+			"name":         fn["name"],
+			"input_schema": fn["parameters"],
+		}
+
+		if desc, exists := fn["description"]; exists {
+			def["description"] = desc
+		}
+
+		defs[i] = def
+	}
+
+	return defs, nil
+}
+
+// finishReasonFromStopReason converts a Messages API stop reason to the
+// equivalent OpenAI-compatible finish reason.
+func finishReasonFromStopReason(stopReason string) string {
+	switch stopReason {
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	default:
+		return "stop"
+	}
+}
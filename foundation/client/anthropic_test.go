@@ -0,0 +1,277 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// replayAnthropic serves the recorded Messages stream and provides the last
+// request document received.
+func replayAnthropic(t *testing.T, file string) (*Anthropic, *D) {
+	t.Helper()
+
+	stream, err := os.ReadFile("testdata/anthropic/" + file)
+	if err != nil {
+		t.Fatalf("reading stream: %s", err)
+	}
+
+	var req D
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") != anthropicVersion {
+			t.Errorf("got %s with key %q version %q", r.URL.Path, r.Header.Get("x-api-key"), r.Header.Get("anthropic-version"))
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %s", err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(stream)
+	}))
+	t.Cleanup(srv.Close)
+
+	return NewAnthropic(NoopLogger, srv.URL, "key"), &req
+}
+
+func TestAnthropicChatStream(t *testing.T) {
+	type call struct {
+		id        string
+		name      string
+		arguments map[string]any
+	}
+
+	tests := []struct {
+		name         string
+		file         string
+		req          ChatRequest
+		content      string
+		reasoning    string
+		signature    string
+		toolCalls    []call
+		finishReason string
+		usage        Usage
+		err          string
+	}{
+		{
+			name:         "text",
+			file:         "text.sse",
+			content:      "Hello world",
+			finishReason: "stop",
+			usage:        Usage{PromptTokens: 25, CompletionTokens: 12, TotalTokens: 37},
+		},
+		{
+			name:    "tool use",
+			file:    "tool_use.sse",
+			content: "Checking the weather.",
+			toolCalls: []call{
+				{id: "toolu_01", name: "get_weather", arguments: map[string]any{"city": "Miami", "unit": "celsius"}},
+				{id: "toolu_02", name: "get_time", arguments: map[string]any{"zone": "EST"}},
+			},
+			finishReason: "tool_calls",
+			usage:        Usage{PromptTokens: 310, CompletionTokens: 89, TotalTokens: 399},
+		},
+		{
+			name:         "thinking",
+			file:         "thinking.sse",
+			req:          ChatRequest{MaxTokens: 2048, ThinkingBudget: 1024},
+			content:      "12,231",
+			reasoning:    "27 * 453 is 12231.",
+			signature:    "EqQBCgIYAhIM1gbcDa9GJwZA2b3hGgxBdjrkzLoky3dl1pkiMOYds",
+			finishReason: "stop",
+			usage:        Usage{PromptTokens: 40, CompletionTokens: 60, TotalTokens: 100},
+		},
+		{
+			name:    "error event",
+			file:    "error.sse",
+			content: "Hel",
+			err:     "overloaded_error: Overloaded",
+		},
+		{
+			name:         "cache usage",
+			file:         "cache_usage.sse",
+			content:      "Cached",
+			finishReason: "length",
+			usage:        Usage{PromptTokens: 105, CompletionTokens: 30, TotalTokens: 135, CachedTokens: 90},
+		},
+		{
+			name:         "structured output",
+			file:         "structured.sse",
+			req:          ChatRequest{SchemaName: "answer", Schema: D{"type": "object"}},
+			content:      `{"name":"Go","year":2009}`,
+			finishReason: "stop",
+			usage:        Usage{PromptTokens: 120, CompletionTokens: 15, TotalTokens: 135},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := replayAnthropic(t, tt.file)
+
+			req := tt.req
+			req.Model = "claude-sonnet-4-5"
+			req.Messages = []Message{UserMessage("hello")}
+
+			stream, err := a.ChatStream(context.Background(), req)
+			if err != nil {
+				t.Fatalf("chat stream: %s", err)
+			}
+
+			var content, reasoning, signature, finishReason string
+			var usage Usage
+			var toolCalls []ToolCall
+
+			acc := NewToolCallAccumulator()
+
+			for v := range stream.Events() {
+				if v.ID == "" || v.Model != "claude-sonnet-4-5" {
+					t.Errorf("got chunk id %q model %q", v.ID, v.Model)
+				}
+
+				delta := v.Choices[0].Delta
+				content += delta.Content
+				reasoning += delta.Reasoning
+				signature += delta.ReasoningSignature
+
+				if v.Choices[0].FinishReason != "" {
+					finishReason = v.Choices[0].FinishReason
+				}
+
+				if v.Usage != nil {
+					usage = *v.Usage
+				}
+
+				calls, err := acc.Add(v)
+				if err != nil {
+					t.Fatalf("accumulating: %s", err)
+				}
+				toolCalls = append(toolCalls, calls...)
+			}
+
+			err = stream.Err()
+			switch {
+			case tt.err != "":
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got error %v, expected %q", err, tt.err)
+				}
+			case err != nil:
+				t.Fatalf("stream: %s", err)
+			}
+
+			if content != tt.content || reasoning != tt.reasoning || signature != tt.signature {
+				t.Errorf("got content %q reasoning %q signature %q, expected %q %q %q", content, reasoning, signature, tt.content, tt.reasoning, tt.signature)
+			}
+
+			if finishReason != tt.finishReason {
+				t.Errorf("got finish reason %q, expected %q", finishReason, tt.finishReason)
+			}
+
+			if usage != tt.usage {
+				t.Errorf("got usage %+v, expected %+v", usage, tt.usage)
+			}
+
+			if len(toolCalls) != len(tt.toolCalls) {
+				t.Fatalf("got %d tool calls, expected %d", len(toolCalls), len(tt.toolCalls))
+			}
+
+			for i, exp := range tt.toolCalls {
+				tc := toolCalls[i]
+				if tc.ID != exp.id || tc.Function.Name != exp.name || !reflect.DeepEqual(tc.Function.Arguments, exp.arguments) {
+					t.Errorf("tool call %d: got %+v, expected %+v", i, tc, exp)
+				}
+			}
+		})
+	}
+}
+
+func TestAnthropicMessagesDocument(t *testing.T) {
+	a := NewAnthropic(NoopLogger, "http://localhost", "key")
+
+	tool := D{"type": "function", "function": D{"name": "get_weather", "parameters": D{"type": "object"}}}
+
+	req := ChatRequest{
+		Model:      "claude-sonnet-4-5",
+		MaxTokens:  1024,
+		Tools:      []D{tool},
+		SchemaName: "answer",
+		Schema:     D{"type": "object"},
+		Messages: []Message{
+			{Role: RoleSystem, Content: "be brief"},
+			UserMessage("what's the weather?"),
+			{Role: RoleAssistant},
+			UserMessage("in Miami"),
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "toolu_01", Function: Function{Name: "get_weather", RawArguments: `{"city":"Miami"}`}}}},
+			{Role: RoleTool, ToolCallID: "toolu_01", Content: "sunny"},
+		},
+	}
+
+	d, err := a.messagesDocument(req, true)
+	if err != nil {
+		t.Fatalf("messages document: %s", err)
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("encoding: %s", err)
+	}
+
+	var doc struct {
+		System   string `json:"system"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Type  string          `json:"type"`
+				Input json.RawMessage `json:"input"`
+			} `json:"content"`
+		} `json:"messages"`
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+		ToolChoice D `json:"tool_choice"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	if doc.System != "be brief" {
+		t.Errorf("got system %q, expected %q", doc.System, "be brief")
+	}
+
+	// The empty assistant message is dropped and the user messages around
+	// it are merged, since the API rejects both.
+
+	var roles, types []string
+	for _, m := range doc.Messages {
+		roles = append(roles, m.Role)
+		for _, b := range m.Content {
+			types = append(types, b.Type)
+		}
+	}
+
+	if expected := []string{"user", "assistant", "user"}; !reflect.DeepEqual(roles, expected) {
+		t.Errorf("got roles %v, expected %v", roles, expected)
+	}
+
+	if expected := []string{"text", "text", "tool_use", "tool_result"}; !reflect.DeepEqual(types, expected) {
+		t.Errorf("got blocks %v, expected %v", types, expected)
+	}
+
+	if input := string(doc.Messages[1].Content[0].Input); input != `{"city":"Miami"}` {
+		t.Errorf("got tool input %s, expected the raw arguments", input)
+	}
+
+	if len(doc.Tools) != 2 || doc.Tools[0].Name != "get_weather" || doc.Tools[1].Name != "answer" {
+		t.Errorf("got tools %+v, expected get_weather and answer", doc.Tools)
+	}
+
+	if doc.ToolChoice["type"] != "tool" || doc.ToolChoice["name"] != "answer" {
+		t.Errorf("got tool choice %v, expected the answer tool", doc.ToolChoice)
+	}
+}
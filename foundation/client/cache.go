@@ -0,0 +1,710 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/ai-training/foundation/vector"
+)
+
+// Cache represents a store for cached responses. Implementations must be
+// safe for concurrent use.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// =============================================================================
+
+// MemoryCache is an in-memory cache that evicts the least recently used
+// entries once it holds the maximum number of entries.
+type MemoryCache struct {
+	maxEntries int
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache constructs an in-memory cache. A maxEntries or ttl of 0
+// means no limit.
+func NewMemoryCache(maxEntries int, ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements the Cache interface.
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[key]
+	if !exists {
+		return nil, false, nil
+	}
+
+	entry := elem.Value.(*memoryEntry)
+
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+
+	c.lru.MoveToFront(elem)
+
+	return entry.value, true, nil
+}
+
+// Set implements the Cache interface.
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+
+	if elem, exists := c.entries[key]; exists {
+		entry := elem.Value.(*memoryEntry)
+		entry.value = value
+		entry.expires = expires
+		c.lru.MoveToFront(elem)
+		return nil
+	}
+
+	c.entries[key] = c.lru.PushFront(&memoryEntry{
+		key:     key,
+		value:   value,
+		expires: expires,
+	})
+
+	if c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// FileCache is a cache that stores each entry as a file in a directory so
+// the entries survive between runs. Once the directory holds the maximum
+// number of entries, the least recently written entries are removed.
+type FileCache struct {
+	dir        string
+	maxEntries int
+	ttl        time.Duration
+	mu         sync.Mutex
+}
+
+// NewFileCache constructs a cache that stores the entries in the directory,
+// creating it if needed. A maxEntries or ttl of 0 means no limit.
+func NewFileCache(dir string, maxEntries int, ttl time.Duration) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+
+	c := FileCache{
+		dir:        dir,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+
+	return &c, nil
+}
+
+// Get implements the Cache interface.
+func (c *FileCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	path := c.path(key)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("stat: %w", err)
+	}
+
+	if c.ttl > 0 && time.Since(info.ModTime()) > c.ttl {
+		os.Remove(path)
+		return nil, false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("read: %w", err)
+	}
+
+	return data, true, nil
+}
+
+// Set implements the Cache interface. The entry is written to a temporary
+// file first so readers never see a partial entry.
+func (c *FileCache) Set(ctx context.Context, key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("close: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("rename: %w", err)
+	}
+
+	return c.evict()
+}
+
+// evict removes the oldest entries above the maximum number of entries.
+func (c *FileCache) evict() error {
+	if c.maxEntries <= 0 {
+		return nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(c.dir, "*.cache"))
+	if err != nil {
+		return fmt.Errorf("glob: %w", err)
+	}
+
+	if len(matches) <= c.maxEntries {
+		return nil
+	}
+
+	type file struct {
+		path    string
+		modTime time.Time
+	}
+
+	files := make([]file, 0, len(matches))
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, file{path: path, modTime: info.ModTime()})
+	}
+
+	slices.SortFunc(files, func(a, b file) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	for _, f := range files[:max(len(files)-c.maxEntries, 0)] {
+		os.Remove(f.path)
+	}
+
+	return nil
+}
+
+func (c *FileCache) path(key string) string {
+	return filepath.Join(c.dir, key+".cache")
+}
+
+// =============================================================================
+
+// SemanticCache reuses a response when the question asked is similar to a
+// question asked before. The question is the text of the last user message
+// and it's only compared with questions asked with the same model, prompt
+// history and options.
+type SemanticCache struct {
+	embed      func(ctx context.Context, text string) ([]float64, error)
+	threshold  float64
+	maxEntries int
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	entries []semanticEntry
+}
+
+type semanticEntry struct {
+	scope   string
+	vector  []float64
+	value   []byte
+	expires time.Time
+}
+
+// NewSemanticCache constructs a semantic cache that uses the embed function
+// to vectorize the questions, usually the EmbedText method of an LLM. A
+// response is reused when the cosine similarity of the questions is at
+// least the threshold. A maxEntries or ttl of 0 means no limit.
+func NewSemanticCache(embed func(ctx context.Context, text string) ([]float64, error), threshold float64, maxEntries int, ttl time.Duration) *SemanticCache {
+	return &SemanticCache{
+		embed:      embed,
+		threshold:  threshold,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+}
+
+// lookup returns the value stored for the most similar question in the
+// scope when it meets the threshold. The vector of the question is returned
+// so it can be stored without embedding the question again.
+func (sc *SemanticCache) lookup(ctx context.Context, scope string, question string) ([]byte, []float64, error) {
+	vec, err := sc.embed(ctx, question)
+	if err != nil {
+		return nil, nil, fmt.Errorf("embed: %w", err)
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	now := time.Now()
+
+	sc.entries = slices.DeleteFunc(sc.entries, func(e semanticEntry) bool {
+		return !e.expires.IsZero() && now.After(e.expires)
+	})
+
+	var best []byte
+	bestScore := sc.threshold
+
+	for _, e := range sc.entries {
+		if e.scope != scope {
+			continue
+		}
+
+		// Entries embedded by a model with other dimensions can't match.
+		score, err := vector.CosineSimilarity(vec, e.vector)
+		if err != nil {
+			continue
+		}
+
+		if score >= bestScore {
+			best, bestScore = e.value, score
+		}
+	}
+
+	return best, vec, nil
+}
+
+func (sc *SemanticCache) store(scope string, vec []float64, value []byte) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	var expires time.Time
+	if sc.ttl > 0 {
+		expires = time.Now().Add(sc.ttl)
+	}
+
+	sc.entries = append(sc.entries, semanticEntry{
+		scope:   scope,
+		vector:  vec,
+		value:   value,
+		expires: expires,
+	})
+
+	if sc.maxEntries > 0 && len(sc.entries) > sc.maxEntries {
+		sc.entries = slices.Delete(sc.entries, 0, len(sc.entries)-sc.maxEntries)
+	}
+}
+
+// =============================================================================
+
+// Cached implements the Provider interface by caching the responses of
+// another provider. Chat responses are cached by the exact request and
+// embeddings by the model and text. A semantic cache can also be used to
+// reuse chat responses for similar questions. Responses served from the
+// cache report no usage.
+type Cached struct {
+	provider Provider
+	cache    Cache
+	semantic *SemanticCache
+}
+
+// NewCached constructs a provider that caches the responses of the provider
+// in the cache.
+func NewCached(provider Provider, cache Cache, options ...func(c *Cached)) *Cached {
+	c := Cached{
+		provider: provider,
+		cache:    cache,
+	}
+
+	for _, option := range options {
+		option(&c)
+	}
+
+	return &c
+}
+
+// WithSemanticCache adds a semantic cache that is checked when there is no
+// exact match for a chat request.
+func WithSemanticCache(sc *SemanticCache) func(c *Cached) {
+	return func(c *Cached) {
+		c.semantic = sc
+	}
+}
+
+type cachedMessage struct {
+	Content            string         `json:"content"`
+	Reasoning          string         `json:"reasoning,omitempty"`
+	ReasoningSignature string         `json:"reasoning_signature,omitempty"`
+	ToolCalls          []ToolCall     `json:"tool_calls,omitempty"`
+	Logprobs           []TokenLogprob `json:"logprobs,omitempty"`
+}
+
+// Chat implements the Provider interface.
+func (c *Cached) Chat(ctx context.Context, req ChatRequest) (Message, error) {
+	lookup, err := c.lookup(ctx, req)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if lookup.hit {
+		return lookup.msg, nil
+	}
+
+	msg, err := c.provider.Chat(ctx, req)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if err := c.store(ctx, lookup, msg); err != nil {
+		return Message{}, err
+	}
+
+	return msg, nil
+}
+
+// ChatChoices implements the ChoicesProvider interface. Multiple choices are
+// requested to get different completions, so they bypass the cache instead
+// of returning the same cached completion for every choice.
+func (c *Cached) ChatChoices(ctx context.Context, req ChatRequest) ([]Message, error) {
+	return chatChoices(ctx, c.provider, req)
+}
+
+// ChatStream implements the Provider interface. A cached response is
+// replayed as a single chunk. Otherwise the response is cached once the
+// stream ends successfully.
+func (c *Cached) ChatStream(ctx context.Context, req ChatRequest) (*Stream[ChatSSE], error) {
+	lookup, err := c.lookup(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if lookup.hit {
+		s := NewStream(ctx, func(send func(ChatSSE) error) error {
+			return send(ChatSSE{
+				Object: "chat.completion.chunk",
+				Model:  req.Model,
+				Choices: []ChatChoiceSSE{
+					{
+						Delta: ChatDeltaSSE{
+							Role:               RoleAssistant,
+							Content:            lookup.msg.Content,
+							Reasoning:          lookup.msg.Reasoning,
+							ReasoningSignature: lookup.msg.ReasoningSignature,
+							ToolCalls:          lookup.msg.ToolCalls,
+						},
+						Logprobs:     cachedLogprobs(lookup.msg),
+						FinishReason: finishReason(lookup.msg),
+					},
+				},
+			})
+		})
+
+		return s, nil
+	}
+
+	stream, err := c.provider.ChatStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewStream(ctx, func(send func(ChatSSE) error) error {
+		// Closing the cached stream stops the provider's stream.
+		defer stream.Close()
+
+		msg := Message{
+			Role: RoleAssistant,
+		}
+
+		var toolCalls []ToolCall
+		acc := NewToolCallAccumulator()
+
+		for v := range stream.Events() {
+			if len(v.Choices) > 0 {
+				delta := v.Choices[0].Delta
+				msg.Content += delta.Content
+				msg.Reasoning += delta.Reasoning
+				msg.ReasoningSignature += delta.ReasoningSignature
+
+				if lp := v.Choices[0].Logprobs; lp != nil {
+					msg.Logprobs = append(msg.Logprobs, lp.Content...)
+				}
+
+				calls, err := acc.Add(v)
+				if err != nil {
+					return err
+				}
+				toolCalls = append(toolCalls, calls...)
+			}
+
+			if err := send(v); err != nil {
+				return err
+			}
+		}
+
+		if err := stream.Err(); err != nil {
+			return err
+		}
+
+		calls, err := acc.Flush()
+		if err != nil {
+			return err
+		}
+		msg.ToolCalls = append(toolCalls, calls...)
+
+		return c.store(ctx, lookup, msg)
+	})
+
+	return s, nil
+}
+
+// Embed implements the Provider interface. Only the texts that are not
+// cached are sent to the provider.
+func (c *Cached) Embed(ctx context.Context, req EmbedRequest) ([][]float64, error) {
+	if req.Parts != nil {
+		return c.provider.Embed(ctx, req)
+	}
+
+	vectors := make([][]float64, len(req.Texts))
+	keys := make([]string, len(req.Texts))
+
+	var missing []int
+
+	for i, text := range req.Texts {
+		keys[i] = cacheKey("embed", req.Model, text)
+
+		data, found, err := c.cache.Get(ctx, keys[i])
+		if err != nil {
+			return nil, fmt.Errorf("cache get: %w", err)
+		}
+
+		if found && json.Unmarshal(data, &vectors[i]) == nil {
+			continue
+		}
+
+		missing = append(missing, i)
+	}
+
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	missReq := req
+	missReq.Texts = make([]string, len(missing))
+	for j, i := range missing {
+		missReq.Texts[j] = req.Texts[i]
+	}
+
+	missVectors, err := c.provider.Embed(ctx, missReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missVectors) != len(missing) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(missing), len(missVectors))
+	}
+
+	for j, i := range missing {
+		vectors[i] = missVectors[j]
+
+		data, err := json.Marshal(missVectors[j])
+		if err != nil {
+			return nil, fmt.Errorf("encoding: %w", err)
+		}
+
+		if err := c.cache.Set(ctx, keys[i], data); err != nil {
+			return nil, fmt.Errorf("cache set: %w", err)
+		}
+	}
+
+	return vectors, nil
+}
+
+// =============================================================================
+
+type cacheLookup struct {
+	key      string
+	scope    string
+	question string
+	vector   []float64
+	hit      bool
+	msg      Message
+}
+
+// lookup checks the exact cache and then the semantic cache for the
+// response to the request.
+func (c *Cached) lookup(ctx context.Context, req ChatRequest) (cacheLookup, error) {
+	key, err := chatCacheKey(req)
+	if err != nil {
+		return cacheLookup{}, err
+	}
+
+	lookup := cacheLookup{
+		key: key,
+	}
+
+	data, found, err := c.cache.Get(ctx, key)
+	if err != nil {
+		return cacheLookup{}, fmt.Errorf("cache get: %w", err)
+	}
+
+	if !found && c.semantic != nil {
+		n := len(req.Messages)
+		if n > 0 && req.Messages[n-1].Role == RoleUser && req.Messages[n-1].Content != "" {
+			scopeReq := req
+			scopeReq.Messages = req.Messages[:n-1]
+
+			if lookup.scope, err = chatCacheKey(scopeReq); err != nil {
+				return cacheLookup{}, err
+			}
+
+			lookup.question = req.Messages[n-1].Content
+
+			if data, lookup.vector, err = c.semantic.lookup(ctx, lookup.scope, lookup.question); err != nil {
+				return cacheLookup{}, fmt.Errorf("semantic: %w", err)
+			}
+
+			found = data != nil
+		}
+	}
+
+	if !found {
+		return lookup, nil
+	}
+
+	var cm cachedMessage
+	if err := json.Unmarshal(data, &cm); err != nil {
+		return lookup, nil
+	}
+
+	lookup.hit = true
+	lookup.msg = Message{
+		Role:               RoleAssistant,
+		Content:            cm.Content,
+		Reasoning:          cm.Reasoning,
+		ReasoningSignature: cm.ReasoningSignature,
+		ToolCalls:          cm.ToolCalls,
+		Logprobs:           cm.Logprobs,
+	}
+
+	return lookup, nil
+}
+
+// store saves the response in the exact cache and the semantic cache.
+func (c *Cached) store(ctx context.Context, lookup cacheLookup, msg Message) error {
+	data, err := json.Marshal(cachedMessage{
+		Content:            msg.Content,
+		Reasoning:          msg.Reasoning,
+		ReasoningSignature: msg.ReasoningSignature,
+		ToolCalls:          msg.ToolCalls,
+		Logprobs:           msg.Logprobs,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding: %w", err)
+	}
+
+	if err := c.cache.Set(ctx, lookup.key, data); err != nil {
+		return fmt.Errorf("cache set: %w", err)
+	}
+
+	if c.semantic != nil && lookup.vector != nil {
+		c.semantic.store(lookup.scope, lookup.vector, data)
+	}
+
+	return nil
+}
+
+// chatCacheKey returns the key for the canonicalized request. Maps are
+// encoded with sorted keys so the key doesn't depend on the order the
+// options were provided in.
+func chatCacheKey(req ChatRequest) (string, error) {
+	doc := D{
+		"model":      req.Model,
+		"messages":   req.Messages,
+		"params":     req.Params,
+		"max_tokens": req.MaxTokens,
+		"tools":      req.Tools,
+		"schema":     req.Schema,
+		"strict":     req.SchemaStrict,
+		"num_ctx":    req.NumCtx,
+		"thinking":   req.ThinkingBudget,
+		"n":          req.N,
+		"logprobs":   req.Logprobs,
+		"top":        req.TopLogprobs,
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("cache key: %w", err)
+	}
+
+	return cacheKey("chat", string(data)), nil
+}
+
+func cacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func cachedLogprobs(msg Message) *Logprobs {
+	if msg.Logprobs == nil {
+		return nil
+	}
+
+	return &Logprobs{Content: msg.Logprobs}
+}
+
+func finishReason(msg Message) string {
+	if len(msg.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+
+	return "stop"
+}
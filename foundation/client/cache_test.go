@@ -0,0 +1,387 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// echoProvider answers with the content of the last message and embeds a
+// text as a vector of its length, recording the requests it receives.
+type echoProvider struct {
+	mu    sync.Mutex
+	chats int
+	texts []string
+}
+
+func (p *echoProvider) Chat(ctx context.Context, req ChatRequest) (Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.chats++
+
+	return Message{Role: RoleAssistant, Content: req.Messages[len(req.Messages)-1].Content}, nil
+}
+
+func (p *echoProvider) ChatStream(ctx context.Context, req ChatRequest) (*Stream[ChatSSE], error) {
+	msg, err := p.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStream(ctx, func(send func(ChatSSE) error) error {
+		half := len(msg.Content) / 2
+		for _, content := range []string{msg.Content[:half], msg.Content[half:]} {
+			if err := send(ChatSSE{Choices: []ChatChoiceSSE{{Delta: ChatDeltaSSE{Content: content}}}}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}), nil
+}
+
+func (p *echoProvider) Embed(ctx context.Context, req EmbedRequest) ([][]float64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.texts = append(p.texts, req.Texts...)
+
+	vectors := make([][]float64, len(req.Texts))
+	for i, text := range req.Texts {
+		vectors[i] = []float64{float64(len(text))}
+	}
+
+	return vectors, nil
+}
+
+func TestCacheExpiry(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+
+	fileCache, err := NewFileCache(t.TempDir(), 0, ttl)
+	if err != nil {
+		t.Fatalf("file cache: %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		cache Cache
+	}{
+		{name: "memory", cache: NewMemoryCache(0, ttl)},
+		{name: "file", cache: fileCache},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if err := tt.cache.Set(ctx, "key", []byte("value")); err != nil {
+				t.Fatalf("set: %s", err)
+			}
+
+			steps := []struct {
+				wait  time.Duration
+				key   string
+				found bool
+			}{
+				{key: "key", found: true},
+				{key: "other", found: false},
+				{wait: 2 * ttl, key: "key", found: false},
+			}
+
+			for i, s := range steps {
+				time.Sleep(s.wait)
+
+				value, found, err := tt.cache.Get(ctx, s.key)
+				if err != nil {
+					t.Fatalf("step %d: get: %s", i, err)
+				}
+
+				if found != s.found {
+					t.Errorf("step %d: got found %t, expected %t", i, found, s.found)
+				}
+
+				if found && string(value) != "value" {
+					t.Errorf("step %d: got %q, expected %q", i, value, "value")
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(2, 0)
+
+	c.Set(ctx, "a", []byte("a"))
+	c.Set(ctx, "b", []byte("b"))
+
+	// Reading a makes b the least recently used entry.
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("c"))
+
+	for key, expected := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, found, _ := c.Get(ctx, key); found != expected {
+			t.Errorf("%s: got found %t, expected %t", key, found, expected)
+		}
+	}
+}
+
+func TestFileCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	c, err := NewFileCache(dir, 2, 0)
+	if err != nil {
+		t.Fatalf("file cache: %s", err)
+	}
+
+	// The modification times are set explicitly since writes in quick
+	// succession can share the same time.
+
+	now := time.Now()
+	for i, key := range []string{"a", "b"} {
+		if err := c.Set(ctx, key, []byte(key)); err != nil {
+			t.Fatalf("set %s: %s", key, err)
+		}
+
+		modTime := now.Add(time.Duration(i-2) * time.Minute)
+		if err := os.Chtimes(c.path(key), modTime, modTime); err != nil {
+			t.Fatalf("chtimes %s: %s", key, err)
+		}
+	}
+
+	if err := c.Set(ctx, "c", []byte("c")); err != nil {
+		t.Fatalf("set c: %s", err)
+	}
+
+	for key, expected := range map[string]bool{"a": false, "b": true, "c": true} {
+		if _, found, _ := c.Get(ctx, key); found != expected {
+			t.Errorf("%s: got found %t, expected %t", key, found, expected)
+		}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, ".tmp-*"))
+	if len(matches) != 0 {
+		t.Errorf("got temporary files %v left behind", matches)
+	}
+}
+
+func TestCachedChat(t *testing.T) {
+	request := func(content string, temperature float64) ChatRequest {
+		return ChatRequest{
+			Model:    "model",
+			Messages: []Message{{Role: RoleUser, Content: content}},
+			Params:   D{"temperature": temperature},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		requests []ChatRequest
+		chats    int
+	}{
+		{name: "hit", requests: []ChatRequest{request("hi", 0), request("hi", 0)}, chats: 1},
+		{name: "other message", requests: []ChatRequest{request("hi", 0), request("bye", 0)}, chats: 2},
+		{name: "other params", requests: []ChatRequest{request("hi", 0), request("hi", 0.5)}, chats: 2},
+		{name: "choices bypass", requests: []ChatRequest{request("hi", 0), {Model: "model", Messages: []Message{{Role: RoleUser, Content: "hi"}}, Params: D{"temperature": 0.0}, N: 2}}, chats: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := echoProvider{}
+			c := NewCached(&provider, NewMemoryCache(0, 0))
+
+			for i, req := range tt.requests {
+				if req.N > 1 {
+					if _, err := c.ChatChoices(context.Background(), req); err != nil {
+						t.Fatalf("request %d: choices: %s", i, err)
+					}
+					continue
+				}
+
+				msg, err := c.Chat(context.Background(), req)
+				if err != nil {
+					t.Fatalf("request %d: chat: %s", i, err)
+				}
+
+				if expected := req.Messages[0].Content; msg.Content != expected {
+					t.Errorf("request %d: got %q, expected %q", i, msg.Content, expected)
+				}
+			}
+
+			if provider.chats != tt.chats {
+				t.Errorf("got %d chats sent to the provider, expected %d", provider.chats, tt.chats)
+			}
+		})
+	}
+}
+
+func TestCachedChatExpiry(t *testing.T) {
+	provider := echoProvider{}
+	c := NewCached(&provider, NewMemoryCache(0, 20*time.Millisecond))
+
+	req := ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}}
+
+	steps := []struct {
+		wait  time.Duration
+		chats int
+	}{
+		{chats: 1},
+		{chats: 1},
+		{wait: 40 * time.Millisecond, chats: 2},
+	}
+
+	for i, s := range steps {
+		time.Sleep(s.wait)
+
+		if _, err := c.Chat(context.Background(), req); err != nil {
+			t.Fatalf("step %d: chat: %s", i, err)
+		}
+
+		if provider.chats != s.chats {
+			t.Errorf("step %d: got %d chats sent to the provider, expected %d", i, provider.chats, s.chats)
+		}
+	}
+}
+
+func TestCachedChatStream(t *testing.T) {
+	provider := echoProvider{}
+	c := NewCached(&provider, NewMemoryCache(0, 0))
+
+	req := ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hello world"}}}
+
+	// The first stream comes from the provider and is cached once it ends.
+	// The second is replayed from the cache as a single chunk.
+
+	for i, chunks := range []int{2, 1} {
+		stream, err := c.ChatStream(context.Background(), req)
+		if err != nil {
+			t.Fatalf("stream %d: %s", i, err)
+		}
+
+		var content string
+		var n int
+		for v := range stream.Events() {
+			content += v.Choices[0].Delta.Content
+			n++
+		}
+
+		if err := stream.Err(); err != nil {
+			t.Fatalf("stream %d: %s", i, err)
+		}
+
+		if content != "hello world" || n != chunks {
+			t.Errorf("stream %d: got %q in %d chunks, expected %q in %d", i, content, n, "hello world", chunks)
+		}
+	}
+
+	if provider.chats != 1 {
+		t.Errorf("got %d chats sent to the provider, expected 1", provider.chats)
+	}
+}
+
+func TestCachedEmbed(t *testing.T) {
+	provider := echoProvider{}
+	c := NewCached(&provider, NewMemoryCache(0, 0))
+
+	tests := []struct {
+		texts []string
+		sent  []string
+	}{
+		{texts: []string{"a", "bb"}, sent: []string{"a", "bb"}},
+		{texts: []string{"bb", "ccc", "a"}, sent: []string{"ccc"}},
+		{texts: []string{"ccc", "a"}, sent: nil},
+	}
+
+	for i, tt := range tests {
+		provider.texts = nil
+
+		vectors, err := c.Embed(context.Background(), EmbedRequest{Model: "model", Texts: tt.texts})
+		if err != nil {
+			t.Fatalf("embed %d: %s", i, err)
+		}
+
+		if !slices.Equal(provider.texts, tt.sent) {
+			t.Errorf("embed %d: got %q sent to the provider, expected %q", i, provider.texts, tt.sent)
+		}
+
+		for j, text := range tt.texts {
+			if len(vectors[j]) != 1 || vectors[j][0] != float64(len(text)) {
+				t.Errorf("embed %d: got %v for %q, expected [%d]", i, vectors[j], text, len(text))
+			}
+		}
+	}
+}
+
+func TestSemanticCache(t *testing.T) {
+	vectors := map[string][]float64{
+		"what is the capital of france":  {1, 0, 0},
+		"what's the capital of france?":  {0.95, 0.05, 0},
+		"how tall is the eiffel tower":   {0, 1, 0},
+		"what is the capital of germany": {0.6, 0, 0.8},
+	}
+
+	embed := func(ctx context.Context, text string) ([]float64, error) {
+		return vectors[text], nil
+	}
+
+	question := func(history string, content string) ChatRequest {
+		req := ChatRequest{Model: "model"}
+		if history != "" {
+			req.Messages = append(req.Messages, Message{Role: RoleSystem, Content: history})
+		}
+		req.Messages = append(req.Messages, Message{Role: RoleUser, Content: content})
+		return req
+	}
+
+	tests := []struct {
+		name  string
+		req   ChatRequest
+		hit   bool
+		ttl   time.Duration
+		sleep time.Duration
+	}{
+		{name: "similar question", req: question("", "what's the capital of france?"), hit: true},
+		{name: "unrelated question", req: question("", "how tall is the eiffel tower"), hit: false},
+		{name: "below threshold", req: question("", "what is the capital of germany"), hit: false},
+		{name: "other history", req: question("be brief", "what's the capital of france?"), hit: false},
+		{name: "expired", req: question("", "what's the capital of france?"), ttl: 20 * time.Millisecond, sleep: 40 * time.Millisecond, hit: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := echoProvider{}
+			c := NewCached(&provider, NewMemoryCache(0, 0), WithSemanticCache(NewSemanticCache(embed, 0.9, 0, tt.ttl)))
+
+			if _, err := c.Chat(context.Background(), question("", "what is the capital of france")); err != nil {
+				t.Fatalf("chat: %s", err)
+			}
+
+			time.Sleep(tt.sleep)
+
+			msg, err := c.Chat(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("chat: %s", err)
+			}
+
+			if hit := provider.chats == 1; hit != tt.hit {
+				t.Errorf("got hit %t, expected %t", hit, tt.hit)
+			}
+
+			// A hit reuses the response to the first question.
+			expected := tt.req.Messages[len(tt.req.Messages)-1].Content
+			if tt.hit {
+				expected = "what is the capital of france"
+			}
+
+			if msg.Content != expected {
+				t.Errorf("got %q, expected %q", msg.Content, expected)
+			}
+		})
+	}
+}
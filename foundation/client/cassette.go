@@ -0,0 +1,463 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoInteraction is returned by a replaying recorder when the cassette
+// doesn't have an unused interaction matching the request.
+var ErrNoInteraction = errors.New("no recorded interaction matches the request")
+
+// RecorderMode determines if a recorder calls the real service and records
+// the interactions or replays the interactions from a cassette.
+type RecorderMode int
+
+// Set of modes a recorder can run in.
+const (
+	ModeReplay RecorderMode = iota
+	ModeRecord
+)
+
+// Cassette represents the interactions recorded to a file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction represents a single request and the response received for it.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest represents a request that was sent.
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// RecordedResponse represents a response that was received. Streamed
+// responses are recorded as the chunks read from the body along with the
+// delay before each chunk, so the stream can be replayed as it happened.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	Chunks     []Chunk     `json:"chunks,omitempty"`
+}
+
+// Chunk represents a part of a streamed response body.
+type Chunk struct {
+	Delay time.Duration `json:"delay"`
+	Data  string        `json:"data"`
+}
+
+// Matcher reports if a recorded request matches the request being made. The
+// body of the request is provided since it has already been read.
+type Matcher func(req *http.Request, body []byte, recorded RecordedRequest) bool
+
+// DefaultMatcher matches requests with the same method, url and body. JSON
+// bodies are compared by value so the order of the fields doesn't matter.
+func DefaultMatcher(req *http.Request, body []byte, recorded RecordedRequest) bool {
+	if req.Method != recorded.Method || req.URL.String() != recorded.URL {
+		return false
+	}
+
+	return equalBodies(body, []byte(recorded.Body))
+}
+
+// MatchPathAndBody matches requests like DefaultMatcher but ignores the
+// scheme and host, so a cassette recorded against one server can be
+// replayed for another.
+func MatchPathAndBody(req *http.Request, body []byte, recorded RecordedRequest) bool {
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+
+	if req.Method != recorded.Method || req.URL.RequestURI() != u.RequestURI() {
+		return false
+	}
+
+	return equalBodies(body, []byte(recorded.Body))
+}
+
+// redactedHeaders are never written to a cassette since they carry
+// credentials.
+var redactedHeaders = []string{"Authorization", "X-Api-Key", "Api-Key", "Cookie", "Set-Cookie"}
+
+// =============================================================================
+
+// Recorder is an http.RoundTripper that records the interactions with a
+// service to a cassette file, or replays them from the file without calling
+// the service. Use it with the client's WithClient option.
+//
+//	rec, err := client.NewRecorder("testdata/chat.json", client.ModeReplay)
+//	p := client.NewOpenAI(log, url, client.WithClient(rec.Client()))
+//	llm, err := client.NewProviderLLM(p, model, client.WithContextWindow(8192))
+type Recorder struct {
+	path      string
+	mode      RecorderMode
+	transport http.RoundTripper
+	match     Matcher
+	timing    bool
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder constructs a recorder for the cassette at the specified path.
+// In replay mode the cassette must exist. In record mode the interactions
+// are written to the cassette when Save is called.
+func NewRecorder(path string, mode RecorderMode, options ...func(rec *Recorder)) (*Recorder, error) {
+	rec := Recorder{
+		path:      path,
+		mode:      mode,
+		transport: defaultClient.Transport,
+		match:     DefaultMatcher,
+	}
+
+	for _, option := range options {
+		option(&rec)
+	}
+
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read cassette: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &rec.cassette); err != nil {
+			return nil, fmt.Errorf("decoding cassette: %w", err)
+		}
+
+		rec.used = make([]bool, len(rec.cassette.Interactions))
+	}
+
+	return &rec, nil
+}
+
+// WithRecorderTransport sets the transport used to call the service when
+// recording.
+func WithRecorderTransport(transport http.RoundTripper) func(rec *Recorder) {
+	return func(rec *Recorder) {
+		rec.transport = transport
+	}
+}
+
+// WithRecorderMatcher sets the rule used to find the recorded interaction
+// for a request when replaying.
+func WithRecorderMatcher(match Matcher) func(rec *Recorder) {
+	return func(rec *Recorder) {
+		rec.match = match
+	}
+}
+
+// WithRecorderTiming replays streamed responses with the delays that were
+// recorded between the chunks. By default chunks are replayed immediately.
+func WithRecorderTiming(timing bool) func(rec *Recorder) {
+	return func(rec *Recorder) {
+		rec.timing = timing
+	}
+}
+
+// Client returns an http client that uses the recorder as its transport.
+func (rec *Recorder) Client() *http.Client {
+	return &http.Client{
+		Transport: rec,
+	}
+}
+
+// Interactions returns the interactions currently held by the recorder.
+func (rec *Recorder) Interactions() []Interaction {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return append([]Interaction{}, rec.cassette.Interactions...)
+}
+
+// Save writes the recorded interactions to the cassette file. Interactions
+// for streamed responses are only included once their body has been read
+// to the end or closed.
+func (rec *Recorder) Save() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	data, err := json.MarshalIndent(rec.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cassette: %w", err)
+	}
+
+	if err := os.WriteFile(rec.path, data, 0644); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		req.Body.Close()
+	}
+
+	switch rec.mode {
+	case ModeRecord:
+		return rec.record(req, body)
+	default:
+		return rec.replay(req, body)
+	}
+}
+
+// =============================================================================
+
+func (rec *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+
+	resp, err := rec.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: redact(req.Header),
+			Body:    string(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    redact(resp.Header),
+		},
+	}
+
+	if !isStream(resp.Header) {
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read response body: %w", err)
+		}
+
+		interaction.Response.Body = string(data)
+		rec.add(interaction)
+
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		return resp, nil
+	}
+
+	// Streamed responses are recorded as they are read by the caller so the
+	// timing of the chunks is preserved.
+	resp.Body = &recordingBody{
+		rc:          resp.Body,
+		rec:         rec,
+		interaction: interaction,
+		last:        time.Now(),
+	}
+
+	return resp, nil
+}
+
+func (rec *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	rec.mu.Lock()
+
+	var interaction Interaction
+	found := false
+
+	for i, v := range rec.cassette.Interactions {
+		if rec.used[i] || !rec.match(req, body, v.Request) {
+			continue
+		}
+
+		rec.used[i] = true
+		interaction = v
+		found = true
+		break
+	}
+
+	rec.mu.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, ErrNoInteraction)
+	}
+
+	resp := http.Response{
+		Status:     fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+		StatusCode: interaction.Response.StatusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     interaction.Response.Headers.Clone(),
+		Request:    req,
+	}
+
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+
+	switch {
+	case interaction.Response.Chunks != nil:
+		resp.ContentLength = -1
+		resp.Body = &replayBody{
+			req:    req,
+			chunks: interaction.Response.Chunks,
+			timing: rec.timing,
+		}
+
+	default:
+		resp.ContentLength = int64(len(interaction.Response.Body))
+		resp.Body = io.NopCloser(strings.NewReader(interaction.Response.Body))
+	}
+
+	return &resp, nil
+}
+
+func (rec *Recorder) add(interaction Interaction) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.cassette.Interactions = append(rec.cassette.Interactions, interaction)
+}
+
+// =============================================================================
+
+// recordingBody records the chunks read from a streamed response and adds
+// the interaction to the recorder once the body is consumed or closed.
+type recordingBody struct {
+	rc          io.ReadCloser
+	rec         *Recorder
+	interaction Interaction
+	last        time.Time
+	once        sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+
+	if n > 0 {
+		now := time.Now()
+
+		b.interaction.Response.Chunks = append(b.interaction.Response.Chunks, Chunk{
+			Delay: now.Sub(b.last),
+			Data:  string(p[:n]),
+		})
+
+		b.last = now
+	}
+
+	if err == io.EOF {
+		b.finish()
+	}
+
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.finish()
+	return b.rc.Close()
+}
+
+func (b *recordingBody) finish() {
+	b.once.Do(func() {
+		if b.interaction.Response.Chunks == nil {
+			b.interaction.Response.Chunks = []Chunk{}
+		}
+		b.rec.add(b.interaction)
+	})
+}
+
+// replayBody provides the recorded chunks of a streamed response, waiting
+// for the recorded delays when timing is enabled.
+type replayBody struct {
+	req    *http.Request
+	chunks []Chunk
+	timing bool
+	data   []byte
+	next   int
+}
+
+func (b *replayBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		if b.next == len(b.chunks) {
+			return 0, io.EOF
+		}
+
+		chunk := b.chunks[b.next]
+		b.next++
+
+		if b.timing && chunk.Delay > 0 {
+			timer := time.NewTimer(chunk.Delay)
+			select {
+			case <-timer.C:
+			case <-b.req.Context().Done():
+				timer.Stop()
+				return 0, b.req.Context().Err()
+			}
+		}
+
+		b.data = []byte(chunk.Data)
+	}
+
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+
+	return n, nil
+}
+
+func (b *replayBody) Close() error {
+	return nil
+}
+
+// =============================================================================
+
+// isStream reports if the response is streamed based on its content type.
+func isStream(header http.Header) bool {
+	ct := header.Get("Content-Type")
+
+	return strings.HasPrefix(ct, "text/event-stream") || strings.HasPrefix(ct, "application/x-ndjson")
+}
+
+// redact returns a copy of the headers without the headers carrying
+// credentials.
+func redact(header http.Header) http.Header {
+	h := header.Clone()
+	for _, key := range redactedHeaders {
+		h.Del(key)
+	}
+
+	return h
+}
+
+// equalBodies compares two bodies, by value when both are JSON documents.
+func equalBodies(a []byte, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
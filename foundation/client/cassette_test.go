@@ -0,0 +1,201 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+	"github.com/ardanlabs/ai-training/foundation/llmtest"
+)
+
+func TestRecorderRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	srv := llmtest.New(llmtest.WithChunkSize(2))
+
+	// Record a normal and a streamed response from the server.
+
+	rec, err := client.NewRecorder(path, client.ModeRecord)
+	if err != nil {
+		t.Fatalf("constructing recorder: %s", err)
+	}
+
+	llm := recorderLLM(t, srv.ChatURL(), rec)
+
+	recorded := chat(t, llm, "hello there")
+	recordedStream := chatStream(t, llm, "tell me a story about a cat")
+
+	if err := rec.Save(); err != nil {
+		t.Fatalf("saving: %s", err)
+	}
+
+	interactions := rec.Interactions()
+	if len(interactions) != 2 {
+		t.Fatalf("got %d interactions, expected 2", len(interactions))
+	}
+
+	if interactions[0].Response.Chunks != nil || interactions[0].Response.Body == "" {
+		t.Errorf("got %+v for the normal response, expected a body", interactions[0].Response)
+	}
+
+	if len(interactions[1].Response.Chunks) == 0 {
+		t.Errorf("got %+v for the streamed response, expected chunks", interactions[1].Response)
+	}
+
+	for _, in := range interactions {
+		if in.Request.Headers.Get("Authorization") != "" {
+			t.Errorf("got authorization header %q, expected it redacted", in.Request.Headers.Get("Authorization"))
+		}
+	}
+
+	// Replay the responses with the server shut down.
+
+	srv.Close()
+
+	rec, err = client.NewRecorder(path, client.ModeReplay)
+	if err != nil {
+		t.Fatalf("constructing replaying recorder: %s", err)
+	}
+
+	llm = recorderLLM(t, srv.ChatURL(), rec)
+
+	if got := chat(t, llm, "hello there"); got != recorded {
+		t.Errorf("got %q, expected the recorded %q", got, recorded)
+	}
+
+	if got := chatStream(t, llm, "tell me a story about a cat"); got != recordedStream {
+		t.Errorf("got stream %q, expected the recorded %q", got, recordedStream)
+	}
+
+	// Every interaction is replayed once.
+
+	_, err = llm.Chat(context.Background(), conversation("hello there"))
+	if !errors.Is(err, client.ErrNoInteraction) {
+		t.Errorf("replaying twice: got error %v, expected %v", err, client.ErrNoInteraction)
+	}
+}
+
+func TestRecorderFixture(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher client.Matcher
+		url     string
+		content string
+		err     error
+	}{
+		{name: "path and body", matcher: client.MatchPathAndBody, url: "http://localhost:9999/v1/chat/completions", content: "hello there"},
+		{name: "default matcher and other host", matcher: client.DefaultMatcher, url: "http://localhost:9999/v1/chat/completions", content: "hello there", err: client.ErrNoInteraction},
+		{name: "other body", matcher: client.MatchPathAndBody, url: "http://localhost:9999/v1/chat/completions", content: "goodbye", err: client.ErrNoInteraction},
+		{name: "other path", matcher: client.MatchPathAndBody, url: "http://localhost:9999/v2/chat/completions", content: "hello there", err: client.ErrNoInteraction},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := client.NewRecorder("testdata/chat.json", client.ModeReplay, client.WithRecorderMatcher(tt.matcher))
+			if err != nil {
+				t.Fatalf("constructing recorder: %s", err)
+			}
+
+			llm := recorderLLM(t, tt.url, rec)
+
+			msg, err := llm.Chat(context.Background(), conversation(tt.content))
+
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Errorf("got error %v, expected %v", err, tt.err)
+				}
+
+			case err != nil:
+				t.Errorf("chat: %s", err)
+
+			case msg.Content != tt.content:
+				t.Errorf("got %q, expected %q", msg.Content, tt.content)
+			}
+		})
+	}
+}
+
+func TestRecorderMissingCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.json")
+
+	if _, err := client.NewRecorder(path, client.ModeReplay); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("replaying: got error %v, expected %v", err, fs.ErrNotExist)
+	}
+
+	// Recording creates the cassette when it's saved.
+
+	rec, err := client.NewRecorder(path, client.ModeRecord)
+	if err != nil {
+		t.Fatalf("recording: %s", err)
+	}
+
+	if err := rec.Save(); err != nil {
+		t.Fatalf("saving: %s", err)
+	}
+
+	if _, err := client.NewRecorder(path, client.ModeReplay); err != nil {
+		t.Errorf("replaying the saved cassette: %s", err)
+	}
+}
+
+// =============================================================================
+
+// recorderLLM constructs an LLM whose requests go through the recorder.
+func recorderLLM(t *testing.T, url string, rec *client.Recorder) *client.LLM {
+	t.Helper()
+
+	llm, err := client.NewLLM(url, "test-model", client.WithHTTPClient(rec.Client()), client.WithAPIKey("secret"))
+	if err != nil {
+		t.Fatalf("constructing llm: %s", err)
+	}
+
+	return llm
+}
+
+// conversation constructs a conversation with a single user message.
+func conversation(content string) *client.Conversation {
+	conv := client.NewConversation("")
+	conv.Add(client.UserMessage(content))
+
+	return conv
+}
+
+// chat returns the content of the response to the message.
+func chat(t *testing.T, llm *client.LLM, content string) string {
+	t.Helper()
+
+	msg, err := llm.Chat(context.Background(), conversation(content))
+	if err != nil {
+		t.Fatalf("chat: %s", err)
+	}
+
+	return msg.Content
+}
+
+// chatStream returns the content streamed in response to the message.
+func chatStream(t *testing.T, llm *client.LLM, content string) string {
+	t.Helper()
+
+	stream, err := llm.ChatStream(context.Background(), conversation(content))
+	if err != nil {
+		t.Fatalf("chat stream: %s", err)
+	}
+
+	var b strings.Builder
+	for v := range stream.Events() {
+		if len(v.Choices) > 0 {
+			b.WriteString(v.Choices[0].Delta.Content)
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		t.Fatalf("chat stream: %s", err)
+	}
+
+	return b.String()
+}
@@ -2,7 +2,6 @@
 package client
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -10,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"time"
@@ -17,7 +17,47 @@ import (
 
 const version = "v1.0.0"
 
-var ErrUnauthorized = errors.New("api understands the request but refuses to authorize it")
+// Set of errors that can be checked with errors.Is against the errors
+// returned by the client.
+var (
+	ErrUnauthorized     = errors.New("api understands the request but refuses to authorize it")
+	ErrRateLimited      = errors.New("api is rate limiting requests")
+	ErrServerOverloaded = errors.New("api is overloaded and can't handle the request")
+)
+
+// APIError represents a response from the api with a status code other
+// than 200 or 204.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Body       string
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Body
+	}
+
+	return fmt.Sprintf("error: status: %d response: %s", e.StatusCode, msg)
+}
+
+// Is allows the sentinel errors to be matched with errors.Is based on the
+// status code.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerOverloaded:
+		return e.StatusCode == http.StatusServiceUnavailable
+	}
+
+	return false
+}
 
 var defaultClient = http.Client{
 	Transport: &http.Transport{
@@ -52,14 +92,18 @@ var StdoutLogger = func(ctx context.Context, msg string, v ...any) {
 // =============================================================================
 
 type Client struct {
-	log  Logger
-	http *http.Client
+	log     Logger
+	http    *http.Client
+	retry   RetryPolicy
+	headers http.Header
 }
 
 func New(log Logger, options ...func(cln *Client)) *Client {
 	cln := Client{
-		log:  log,
-		http: &defaultClient,
+		log:     log,
+		http:    &defaultClient,
+		retry:   NoRetryPolicy,
+		headers: make(http.Header),
 	}
 
 	for _, option := range options {
@@ -75,11 +119,51 @@ func WithClient(http *http.Client) func(cln *Client) {
 	}
 }
 
+// WithHeader sets a header that will be sent with every request, replacing
+// any default value for the header.
+func WithHeader(key string, value string) func(cln *Client) {
+	return func(cln *Client) {
+		cln.headers.Set(key, value)
+	}
+}
+
+// WithBearerToken authenticates every request with the token using the
+// Authorization header.
+func WithBearerToken(token string) func(cln *Client) {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithSlog logs to the structured logger, replacing the Logger the client
+// was constructed with.
+func WithSlog(log *slog.Logger) func(cln *Client) {
+	return func(cln *Client) {
+		cln.log = SlogLogger(log)
+	}
+}
+
 func (cln *Client) Do(ctx context.Context, method string, endpoint string, body D, v any) error {
 	resp, err := do(ctx, cln, method, endpoint, body)
 	if err != nil {
 		return err
 	}
+
+	return decodeResponse(resp, v)
+}
+
+// DoBody sends the body as is with the specified content type, like a
+// multipart form, and decodes the response the same way as Do.
+func (cln *Client) DoBody(ctx context.Context, method string, endpoint string, contentType string, body []byte, v any) error {
+	resp, err := doBody(ctx, cln, method, endpoint, contentType, body)
+	if err != nil {
+		return err
+	}
+
+	return decodeResponse(resp, v)
+}
+
+// decodeResponse decodes the response into v, which can be a string to
+// receive the response as is.
+func decodeResponse(resp *http.Response, v any) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNoContent {
@@ -118,96 +202,274 @@ func NewSSE[T any](log Logger, options ...func(cln *Client)) *SSEClient[T] {
 	}
 }
 
+// Do streams the decoded values over the provided channel and closes the
+// channel when the stream ends. Errors that happen after the stream starts
+// are only logged, use Stream to receive them.
 func (cln *SSEClient[T]) Do(ctx context.Context, method string, endpoint string, body D, ch chan T) error {
 	resp, err := do(ctx, cln.Client, method, endpoint, body)
 	if err != nil {
 		return err
 	}
 
-	go func(ctx context.Context) {
-		defer func() {
-			resp.Body.Close()
-			close(ch)
-		}()
+	send := func(v T) error {
+		select {
+		case ch <- v:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	go func() {
+		defer close(ch)
+
+		if err := cln.decode(ctx, resp, send); err != nil {
+			cln.log(ctx, "sseclient: do:", "ERROR", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stream starts the request and returns a stream that provides the decoded
+// values. Once the values have been received, Err reports why the stream
+// ended.
+func (cln *SSEClient[T]) Stream(ctx context.Context, method string, endpoint string, body D) (*Stream[T], error) {
+	resp, err := do(ctx, cln.Client, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewStream(ctx, func(send func(T) error) error {
+		return cln.decode(ctx, resp, send)
+	})
+
+	return s, nil
+}
 
-		scanner := bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
-			line := scanner.Text()
+// decode reads the events from the response and sends the decoded values
+// until the stream ends. Any error that ends the stream early is returned.
+func (cln *SSEClient[T]) decode(ctx context.Context, resp *http.Response, send func(T) error) error {
+	defer resp.Body.Close()
+
+	dec := NewEventDecoder(resp.Body)
 
-			if line == "" || line == "data: [DONE]" {
-				continue
+	for {
+		evt, err := dec.Decode()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
 			}
 
-			var v T
-			if err := json.Unmarshal([]byte(line[6:]), &v); err != nil {
-				cln.log(ctx, "sseclient: rawRequest:", "Unmarshal", err, "line", line[6:])
-				return
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
 
-			select {
-			case ch <- v:
+			return fmt.Errorf("sseclient: read: %w", err)
+		}
+
+		if evt.Data == "[DONE]" {
+			return nil
+		}
+
+		if err := streamError(evt); err != nil {
+			return err
+		}
+
+		var v T
+		if err := json.Unmarshal([]byte(evt.Data), &v); err != nil {
+			return fmt.Errorf("sseclient: data: %s, decoding error: %w", evt.Data, err)
+		}
+
+		if err := send(v); err != nil {
+			return err
+		}
+	}
+}
 
+// streamError checks if the event is an error frame sent by the server. The
+// error can be an event of type error or a JSON document with an error key
+// holding a message or an error object.
+func streamError(evt Event) error {
+	var doc struct {
+		Error json.RawMessage `json:"error"`
+	}
+
+	if err := json.Unmarshal([]byte(evt.Data), &doc); err != nil || len(doc.Error) == 0 || string(doc.Error) == "null" {
+		if evt.Event == "error" {
+			return newError(evt.Data)
+		}
+		return nil
+	}
+
+	var msg string
+	if err := json.Unmarshal(doc.Error, &msg); err == nil {
+		if msg == "" {
+			return nil
+		}
+		return newError(msg)
+	}
+
+	var e Error
+	if err := json.Unmarshal([]byte(evt.Data), &e); err != nil {
+		return newError(string(doc.Error))
+	}
+
+	return &e
+}
+
+// =============================================================================
+
+// Stream provides access to the values decoded from an event stream. A
+// stream holds on to its connection, and the limiter slot of an LLM, until
+// all the values are received. Call Close or cancel the context when a
+// stream is abandoned early.
+type Stream[T any] struct {
+	ch     chan T
+	err    error
+	cancel context.CancelFunc
+}
+
+// NewStream constructs a stream whose values are produced by the specified
+// function running in its own goroutine. The function provides values with
+// the send function, which fails once the context is canceled or the stream
+// is closed. The error returned by the function is reported by Err.
+func NewStream[T any](ctx context.Context, f func(send func(T) error) error) *Stream[T] {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := Stream[T]{
+		ch:     make(chan T, 100),
+		cancel: cancel,
+	}
+
+	go func() {
+		defer close(s.ch)
+		defer cancel()
+
+		s.err = f(func(v T) error {
+			select {
+			case s.ch <- v:
+				return nil
 			case <-ctx.Done():
-				cln.log(ctx, "sseclient: rawRequest:", "Context", ctx.Err().Error())
-				return
+				return ctx.Err()
 			}
-		}
-	}(ctx)
+		})
+	}()
 
+	return &s
+}
+
+// Events returns the channel that provides the decoded values. The channel
+// is closed when the stream ends.
+func (s *Stream[T]) Events() <-chan T {
+	return s.ch
+}
+
+// Err returns the error that ended the stream, or nil if the stream ended
+// normally. It must only be called after the events channel is closed.
+func (s *Stream[T]) Err() error {
+	return s.err
+}
+
+// Close stops the stream, canceling the request that produces its values.
+// The events channel is closed once the stream has stopped. It's safe to
+// call Close after the stream has ended.
+func (s *Stream[T]) Close() error {
+	s.cancel()
 	return nil
 }
 
+// cancelWith makes Close also cancel the context of the request the stream
+// depends on, so an abandoned stream stops reading from the connection.
+func (s *Stream[T]) cancelWith(cancel context.CancelFunc) {
+	streamCancel := s.cancel
+	s.cancel = func() {
+		streamCancel()
+		cancel()
+	}
+}
+
 // =============================================================================
 
 func do(ctx context.Context, cln *Client, method string, endpoint string, body any) (*http.Response, error) {
-	var statusCode int
-
-	var b bytes.Buffer
+	var b []byte
 	if body != nil {
-		if err := json.NewEncoder(&b).Encode(body); err != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
 			return nil, fmt.Errorf("encoding: error: %w", err)
 		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, endpoint, &b)
+	return doBody(ctx, cln, method, endpoint, "application/json", b)
+}
+
+func doBody(ctx context.Context, cln *Client, method string, endpoint string, contentType string, b []byte) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := send(ctx, cln, method, endpoint, contentType, b)
+		if err == nil {
+			return resp, nil
+		}
+
+		if ctx.Err() != nil || !cln.retry.shouldRetry(attempt, method, err) {
+			return nil, err
+		}
+
+		delay := cln.retry.backoff(attempt, err)
+
+		cln.log(ctx, "client: do: retrying", "attempt", attempt, "delay", delay, "ERROR", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func send(ctx context.Context, cln *Client, method string, endpoint string, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("create request error: %w", err)
 	}
 
 	req.Header.Set("Cache-Control", "no-cache")
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", fmt.Sprintf("Ardan Labs AI Training Sample Go Client: %s", version))
 
+	for key, values := range cln.headers {
+		req.Header[key] = values
+	}
+
 	resp, err := cln.http.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("do: error: %w", err)
 	}
 
-	// Assign for logging the status code at the end of the function call.
-	statusCode = resp.StatusCode
-
-	switch statusCode {
+	switch resp.StatusCode {
 	case http.StatusOK, http.StatusNoContent:
 		return resp, nil
+	}
 
-	default:
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("readall: error: %w", err)
-		}
+	defer resp.Body.Close()
 
-		switch statusCode {
-		case http.StatusForbidden:
-			return nil, ErrUnauthorized
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("readall: error: %w", err)
+	}
 
-		default:
-			var err Error
-			if err := json.Unmarshal(data, &err); err != nil {
-				return nil, fmt.Errorf("decoding: response: %s, error: %w ", string(data), err)
-			}
+	apiErr := APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(data),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
 
-			return nil, fmt.Errorf("error: status: %d response: %s", statusCode, err.Err.Message)
-		}
+	var e Error
+	if err := json.Unmarshal(data, &e); err == nil {
+		apiErr.Message = e.Err.Message
 	}
+
+	return nil, &apiErr
 }
@@ -0,0 +1,290 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Set of roles a message in a conversation can have.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// =============================================================================
+
+// Set of detail levels for an image. A low detail image uses fewer tokens
+// while a high detail image lets the model see more of the image.
+const (
+	DetailAuto = "auto"
+	DetailLow  = "low"
+	DetailHigh = "high"
+)
+
+// ImageURL represents the image provided in a content part. The URL can be
+// a regular url or a base64 encoded data url.
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// InputAudio represents the base64 encoded audio provided in a content part.
+// The format is the audio format, like wav or mp3.
+type InputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
+// File represents a file provided in a content part, either as a base64
+// encoded data url with its file name or as the id of an uploaded file.
+type File struct {
+	FileName string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
+}
+
+// ContentPart represents one part of a multimodal message.
+type ContentPart struct {
+	Type       string      `json:"type"`
+	Text       string      `json:"text,omitempty"`
+	ImageURL   *ImageURL   `json:"image_url,omitempty"`
+	InputAudio *InputAudio `json:"input_audio,omitempty"`
+	File       *File       `json:"file,omitempty"`
+}
+
+// TextPart constructs a text content part.
+func TextPart(text string) ContentPart {
+	return ContentPart{
+		Type: "text",
+		Text: text,
+	}
+}
+
+// ImagePart constructs an image content part using a base64 encoded data url.
+// The detail level can be set with WithDetail.
+func ImagePart(mimeType string, image []byte, options ...func(part *ContentPart)) ContentPart {
+	return ImageURLPart(dataURL(mimeType, image), options...)
+}
+
+// ImageURLPart constructs an image content part for an image the model
+// server can download from the url.
+func ImageURLPart(url string, options ...func(part *ContentPart)) ContentPart {
+	part := ContentPart{
+		Type: "image_url",
+		ImageURL: &ImageURL{
+			URL: url,
+		},
+	}
+
+	for _, option := range options {
+		option(&part)
+	}
+
+	return part
+}
+
+// WithDetail sets the detail level of an image content part.
+func WithDetail(detail string) func(part *ContentPart) {
+	return func(part *ContentPart) {
+		if part.ImageURL != nil {
+			part.ImageURL.Detail = detail
+		}
+	}
+}
+
+// AudioPart constructs an audio content part. The format is the audio
+// format, like wav or mp3.
+func AudioPart(format string, audio []byte) ContentPart {
+	return ContentPart{
+		Type: "input_audio",
+		InputAudio: &InputAudio{
+			Data:   base64.StdEncoding.EncodeToString(audio),
+			Format: format,
+		},
+	}
+}
+
+// FilePart constructs a file content part, like a PDF document, using a
+// base64 encoded data url.
+func FilePart(fileName string, mimeType string, data []byte) ContentPart {
+	return ContentPart{
+		Type: "file",
+		File: &File{
+			FileName: fileName,
+			FileData: dataURL(mimeType, data),
+		},
+	}
+}
+
+// FileIDPart constructs a file content part referencing a file that has
+// been uploaded to the model server.
+func FileIDPart(fileID string) ContentPart {
+	return ContentPart{
+		Type: "file",
+		File: &File{
+			FileID: fileID,
+		},
+	}
+}
+
+func dataURL(mimeType string, data []byte) string {
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
+}
+
+// =============================================================================
+
+// Message represents a single message in a conversation. When Parts is not
+// empty, the message is sent as a multimodal message with the Content placed
+// as the first text part. Usage and Logprobs are only set on the messages
+// returned by the model and are never sent back.
+type Message struct {
+	Role               string
+	Content            string
+	Reasoning          string
+	ReasoningSignature string
+	Parts              []ContentPart
+	ToolCalls          []ToolCall
+	ToolCallID         string
+	ToolName           string
+	Usage              Usage
+	Logprobs           []TokenLogprob
+}
+
+// SystemMessage constructs a message with the system role.
+func SystemMessage(content string) Message {
+	return Message{
+		Role:    RoleSystem,
+		Content: content,
+	}
+}
+
+// UserMessage constructs a message with the user role. Any parts provided
+// will follow the text content.
+func UserMessage(content string, parts ...ContentPart) Message {
+	return Message{
+		Role:    RoleUser,
+		Content: content,
+		Parts:   parts,
+	}
+}
+
+// AssistantMessage constructs a message with the assistant role.
+func AssistantMessage(content string) Message {
+	return Message{
+		Role:    RoleAssistant,
+		Content: content,
+	}
+}
+
+// ToolCallMessage constructs an assistant message requesting the specified
+// tool calls.
+func ToolCallMessage(toolCalls []ToolCall) Message {
+	return Message{
+		Role:      RoleAssistant,
+		ToolCalls: toolCalls,
+	}
+}
+
+// ToolMessage constructs a message with the tool role containing the result
+// of the specified tool call.
+func ToolMessage(toolCallID string, toolName string, content string) Message {
+	return Message{
+		Role:       RoleTool,
+		Content:    content,
+		ToolCallID: toolCallID,
+		ToolName:   toolName,
+	}
+}
+
+// MarshalJSON implements the json.Marshaler interface so a message is encoded
+// the way the chat completions API expects it.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message struct {
+		Role       string     `json:"role"`
+		Content    any        `json:"content"`
+		ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+		ToolCallID string     `json:"tool_call_id,omitempty"`
+		ToolName   string     `json:"tool_name,omitempty"`
+	}
+
+	msg := message{
+		Role:       m.Role,
+		Content:    m.Content,
+		ToolCalls:  m.ToolCalls,
+		ToolCallID: m.ToolCallID,
+		ToolName:   m.ToolName,
+	}
+
+	if len(m.Parts) > 0 {
+		parts := make([]ContentPart, 0, len(m.Parts)+1)
+		if m.Content != "" {
+			parts = append(parts, TextPart(m.Content))
+		}
+		msg.Content = append(parts, m.Parts...)
+	}
+
+	return json.Marshal(msg)
+}
+
+// =============================================================================
+
+// Conversation maintains the history of messages exchanged with a model and
+// the tokens used by the requests made with it.
+type Conversation struct {
+	Messages []Message
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+// NewConversation constructs a conversation. If a system prompt is provided
+// it will be the first message in the conversation.
+func NewConversation(systemPrompt string) *Conversation {
+	var conv Conversation
+
+	if systemPrompt != "" {
+		conv.Messages = append(conv.Messages, SystemMessage(systemPrompt))
+	}
+
+	return &conv
+}
+
+// Add appends the specified messages to the conversation.
+func (conv *Conversation) Add(messages ...Message) {
+	conv.Messages = append(conv.Messages, messages...)
+}
+
+// Len returns the number of messages in the conversation.
+func (conv *Conversation) Len() int {
+	return len(conv.Messages)
+}
+
+// Last returns the last message in the conversation.
+func (conv *Conversation) Last() (Message, bool) {
+	if len(conv.Messages) == 0 {
+		return Message{}, false
+	}
+
+	return conv.Messages[len(conv.Messages)-1], true
+}
+
+// Usage returns the tokens used by the requests made with the conversation.
+func (conv *Conversation) Usage() Usage {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	return conv.usage
+}
+
+// addUsage records the tokens used by a request. Streams record their usage
+// from their own goroutine.
+func (conv *Conversation) addUsage(u Usage) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	conv.usage = conv.usage.Add(u)
+}
@@ -0,0 +1,85 @@
+package client_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+)
+
+func TestMessageMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      client.Message
+		expected string
+	}{
+		{
+			name:     "text",
+			msg:      client.UserMessage("hello"),
+			expected: `{"role":"user","content":"hello"}`,
+		},
+		{
+			name:     "empty content",
+			msg:      client.Message{Role: client.RoleAssistant},
+			expected: `{"role":"assistant","content":""}`,
+		},
+		{
+			name:     "model fields not sent",
+			msg:      client.Message{Role: client.RoleAssistant, Content: "hi", Reasoning: "thinking", Usage: client.Usage{TotalTokens: 3}, Logprobs: []client.TokenLogprob{{Token: "hi"}}},
+			expected: `{"role":"assistant","content":"hi"}`,
+		},
+		{
+			name:     "tool call",
+			msg:      client.ToolCallMessage([]client.ToolCall{{ID: "call_1", Type: "function", Function: client.Function{Name: "get_weather", Arguments: map[string]any{"city": "Miami"}}}}),
+			expected: `{"role":"assistant","content":"","tool_calls":[{"id":"call_1","index":0,"type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Miami\"}"}}]}`,
+		},
+		{
+			name:     "tool result",
+			msg:      client.ToolMessage("call_1", "get_weather", "sunny"),
+			expected: `{"role":"tool","content":"sunny","tool_call_id":"call_1","tool_name":"get_weather"}`,
+		},
+		{
+			name:     "image",
+			msg:      client.UserMessage("describe", client.ImagePart("image/png", []byte("png"))),
+			expected: `{"role":"user","content":[{"type":"text","text":"describe"},{"type":"image_url","image_url":{"url":"data:image/png;base64,cG5n"}}]}`,
+		},
+		{
+			name:     "parts without text",
+			msg:      client.UserMessage("", client.ImageURLPart("https://example.com/cat.jpg")),
+			expected: `{"role":"user","content":[{"type":"image_url","image_url":{"url":"https://example.com/cat.jpg"}}]}`,
+		},
+		{
+			name:     "image detail",
+			msg:      client.UserMessage("describe", client.ImageURLPart("https://example.com/cat.jpg", client.WithDetail("low"))),
+			expected: `{"role":"user","content":[{"type":"text","text":"describe"},{"type":"image_url","image_url":{"url":"https://example.com/cat.jpg","detail":"low"}}]}`,
+		},
+		{
+			name:     "audio",
+			msg:      client.UserMessage("transcribe", client.AudioPart("wav", []byte("wav"))),
+			expected: `{"role":"user","content":[{"type":"text","text":"transcribe"},{"type":"input_audio","input_audio":{"data":"d2F2","format":"wav"}}]}`,
+		},
+		{
+			name:     "file",
+			msg:      client.UserMessage("summarize", client.FilePart("report.pdf", "application/pdf", []byte("pdf"))),
+			expected: `{"role":"user","content":[{"type":"text","text":"summarize"},{"type":"file","file":{"filename":"report.pdf","file_data":"data:application/pdf;base64,cGRm"}}]}`,
+		},
+		{
+			name:     "file id",
+			msg:      client.UserMessage("", client.FileIDPart("file-123")),
+			expected: `{"role":"user","content":[{"type":"file","file":{"file_id":"file-123"}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("encoding: %s", err)
+			}
+
+			if string(data) != tt.expected {
+				t.Errorf("got %s, expected %s", data, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package client
+
+import (
+	"context"
+	"strings"
+)
+
+// ChatEvent represents an event produced by ChatEvents. Use a type switch
+// to handle the different kinds of events.
+type ChatEvent interface {
+	chatEvent()
+}
+
+// ReasoningDelta provides text the model produced while reasoning, either
+// from the reasoning field of a chunk or from inside <think> tags.
+type ReasoningDelta struct {
+	Text string
+}
+
+// ContentDelta provides text from the response of the model.
+type ContentDelta struct {
+	Text string
+}
+
+// ToolCallComplete provides the tool calls the model asked for once all
+// their deltas have been received.
+type ToolCallComplete struct {
+	ToolCalls []ToolCall
+}
+
+// Done is the last event of a successful response. The message contains the
+// complete content, reasoning and tool calls of the response.
+type Done struct {
+	FinishReason string
+	Message      Message
+}
+
+// ErrorEvent is the last event of a response that failed.
+type ErrorEvent struct {
+	Err error
+}
+
+func (ReasoningDelta) chatEvent()   {}
+func (ContentDelta) chatEvent()     {}
+func (ToolCallComplete) chatEvent() {}
+func (Done) chatEvent()             {}
+func (ErrorEvent) chatEvent()       {}
+
+// ChatEvents transforms a stream of response chunks into a stream of typed
+// events. Reasoning provided in the reasoning field or inside <think> tags
+// is separated from the content, even when a tag is split over chunks, and
+// tool call deltas are accumulated into complete tool calls.
+func ChatEvents(ctx context.Context, stream *Stream[ChatSSE]) *Stream[ChatEvent] {
+	return NewStream(ctx, func(send func(ChatEvent) error) error {
+		// Closing the event stream stops the stream of chunks.
+		defer stream.Close()
+
+		var content, reasoning strings.Builder
+		var think thinkSplitter
+		var finishReason string
+
+		msg := Message{
+			Role: RoleAssistant,
+		}
+
+		acc := NewToolCallAccumulator()
+
+		emit := func(segments []thinkSegment) error {
+			for _, seg := range segments {
+				var evt ChatEvent = ContentDelta{Text: seg.text}
+				if seg.reasoning {
+					evt = ReasoningDelta{Text: seg.text}
+					reasoning.WriteString(seg.text)
+				} else {
+					content.WriteString(seg.text)
+				}
+
+				if err := send(evt); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		toolCalls := func(calls []ToolCall) error {
+			if len(calls) == 0 {
+				return nil
+			}
+
+			msg.ToolCalls = append(msg.ToolCalls, calls...)
+
+			return send(ToolCallComplete{ToolCalls: calls})
+		}
+
+		fail := func(err error) error {
+			send(ErrorEvent{Err: err})
+			return err
+		}
+
+		for chunk := range stream.Events() {
+			if chunk.Usage != nil {
+				msg.Usage = msg.Usage.Add(*chunk.Usage)
+			}
+
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+
+			delta := chunk.Choices[0].Delta
+
+			if delta.Reasoning != "" {
+				if err := emit([]thinkSegment{{reasoning: true, text: delta.Reasoning}}); err != nil {
+					return err
+				}
+			}
+
+			if delta.ReasoningSignature != "" {
+				msg.ReasoningSignature += delta.ReasoningSignature
+			}
+
+			if lp := chunk.Choices[0].Logprobs; lp != nil {
+				msg.Logprobs = append(msg.Logprobs, lp.Content...)
+			}
+
+			if delta.Content != "" {
+				if err := emit(think.split(delta.Content)); err != nil {
+					return err
+				}
+			}
+
+			if chunk.Choices[0].FinishReason != "" {
+				finishReason = chunk.Choices[0].FinishReason
+			}
+
+			calls, err := acc.Add(chunk)
+			if err != nil {
+				return fail(err)
+			}
+
+			if err := toolCalls(calls); err != nil {
+				return err
+			}
+		}
+
+		if err := stream.Err(); err != nil {
+			return fail(err)
+		}
+
+		if err := emit(think.flush()); err != nil {
+			return err
+		}
+
+		calls, err := acc.Flush()
+		if err != nil {
+			return fail(err)
+		}
+
+		if err := toolCalls(calls); err != nil {
+			return err
+		}
+
+		msg.Content = content.String()
+		msg.Reasoning = reasoning.String()
+
+		return send(Done{
+			FinishReason: finishReason,
+			Message:      msg,
+		})
+	})
+}
+
+// ChatEvents sends the conversation to the model and returns the response
+// as a stream of typed events. See the ChatEvents function.
+func (llm *LLM) ChatEvents(ctx context.Context, conv *Conversation, options ...withParam) (*Stream[ChatEvent], error) {
+	stream, err := llm.ChatStream(ctx, conv, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return ChatEvents(ctx, stream), nil
+}
+
+// =============================================================================
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+type thinkSegment struct {
+	reasoning bool
+	text      string
+}
+
+// thinkSplitter separates the text inside <think> tags from the content.
+// Text that could be the start of a tag is held back until the next chunk
+// shows if it's a tag or not.
+type thinkSplitter struct {
+	inThink bool
+	pending string
+}
+
+func (ts *thinkSplitter) split(text string) []thinkSegment {
+	var segments []thinkSegment
+
+	buf := ts.pending + text
+	ts.pending = ""
+
+	for {
+		tag := thinkOpen
+		if ts.inThink {
+			tag = thinkClose
+		}
+
+		idx := strings.Index(buf, tag)
+		if idx == -1 {
+			break
+		}
+
+		if idx > 0 {
+			segments = append(segments, thinkSegment{reasoning: ts.inThink, text: buf[:idx]})
+		}
+
+		ts.inThink = !ts.inThink
+		buf = buf[idx+len(tag):]
+	}
+
+	tag := thinkOpen
+	if ts.inThink {
+		tag = thinkClose
+	}
+
+	keep := partialSuffix(buf, tag)
+	ts.pending = buf[len(buf)-keep:]
+	buf = buf[:len(buf)-keep]
+
+	if buf != "" {
+		segments = append(segments, thinkSegment{reasoning: ts.inThink, text: buf})
+	}
+
+	return segments
+}
+
+// flush returns any text held back once the stream has ended.
+func (ts *thinkSplitter) flush() []thinkSegment {
+	if ts.pending == "" {
+		return nil
+	}
+
+	seg := thinkSegment{reasoning: ts.inThink, text: ts.pending}
+	ts.pending = ""
+
+	return []thinkSegment{seg}
+}
+
+// partialSuffix returns the length of the longest suffix of s that is a
+// proper prefix of tag.
+func partialSuffix(s string, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestThinkSplitter(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunks    []string
+		reasoning string
+		content   string
+	}{
+		{name: "no tags", chunks: []string{"hello ", "world"}, content: "hello world"},
+		{name: "whole tags", chunks: []string{"<think>hmm</think>", "answer"}, reasoning: "hmm", content: "answer"},
+		{name: "open tag split", chunks: []string{"<th", "ink>hmm</think>answer"}, reasoning: "hmm", content: "answer"},
+		{name: "close tag split", chunks: []string{"<think>hmm</", "thi", "nk>answer"}, reasoning: "hmm", content: "answer"},
+		{name: "tag a byte at a time", chunks: strings.Split("<think>a</think>b", ""), reasoning: "a", content: "b"},
+		{name: "text before the tag", chunks: []string{"pre<think>hmm</think>post"}, reasoning: "hmm", content: "prepost"},
+		{name: "false start", chunks: []string{"a <thi", "s is not a tag"}, content: "a <this is not a tag"},
+		{name: "less than sign", chunks: []string{"1 <", " 2"}, content: "1 < 2"},
+		{name: "partial tag at the end", chunks: []string{"answer <thi"}, content: "answer <thi"},
+		{name: "unclosed think", chunks: []string{"<think>still thinking</thi"}, reasoning: "still thinking</thi"},
+		{name: "several blocks", chunks: []string{"<think>a</think>b<think>", "c</think>d"}, reasoning: "ac", content: "bd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ts thinkSplitter
+			var reasoning, content strings.Builder
+
+			add := func(segments []thinkSegment) {
+				for _, seg := range segments {
+					if seg.text == "" {
+						t.Errorf("got an empty segment")
+					}
+
+					if seg.reasoning {
+						reasoning.WriteString(seg.text)
+						continue
+					}
+					content.WriteString(seg.text)
+				}
+			}
+
+			for _, chunk := range tt.chunks {
+				add(ts.split(chunk))
+			}
+			add(ts.flush())
+
+			if reasoning.String() != tt.reasoning {
+				t.Errorf("got reasoning %q, expected %q", reasoning.String(), tt.reasoning)
+			}
+
+			if content.String() != tt.content {
+				t.Errorf("got content %q, expected %q", content.String(), tt.content)
+			}
+		})
+	}
+}
+
+func TestChatEvents(t *testing.T) {
+	deltas := []ChatDeltaSSE{
+		{Role: RoleAssistant},
+		{Reasoning: "from the field "},
+		{Content: "<thi"},
+		{Content: "nk>from tags</th"},
+		{Content: "ink>the answer"},
+	}
+
+	stream := NewStream(context.Background(), func(send func(ChatSSE) error) error {
+		for i, d := range deltas {
+			chunk := ChatSSE{Choices: []ChatChoiceSSE{{Delta: d}}}
+			if i == len(deltas)-1 {
+				chunk.Choices[0].FinishReason = "stop"
+			}
+
+			if err := send(chunk); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	var reasoning, content []string
+	var done Done
+
+	events := ChatEvents(context.Background(), stream)
+	for evt := range events.Events() {
+		switch evt := evt.(type) {
+		case ReasoningDelta:
+			reasoning = append(reasoning, evt.Text)
+		case ContentDelta:
+			content = append(content, evt.Text)
+		case Done:
+			done = evt
+		}
+	}
+
+	if err := events.Err(); err != nil {
+		t.Fatalf("events: %s", err)
+	}
+
+	if got := strings.Join(reasoning, ""); got != "from the field from tags" {
+		t.Errorf("got reasoning deltas %q", reasoning)
+	}
+
+	if got := strings.Join(content, ""); got != "the answer" {
+		t.Errorf("got content deltas %q", content)
+	}
+
+	if done.FinishReason != "stop" || done.Message.Reasoning != "from the field from tags" || done.Message.Content != "the answer" {
+		t.Errorf("got done %+v, expected the complete message", done)
+	}
+}
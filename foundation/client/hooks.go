@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RequestInfo describes a request made by an LLM. The latency is set once
+// the request has completed and includes the time spent waiting for the
+// limiter, which is provided by QueueWait. The time to first token is only
+// set for streamed responses.
+type RequestInfo struct {
+	Operation        string
+	Model            string
+	Start            time.Time
+	Latency          time.Duration
+	QueueWait        time.Duration
+	TimeToFirstToken time.Duration
+	Usage            Usage
+	Err              error
+}
+
+// Hooks are called during the lifecycle of the requests made by an LLM so
+// metrics and traces can be recorded. Any hook can be nil. The hooks are
+// called synchronously, so they should not block.
+type Hooks struct {
+	OnRequest    func(ctx context.Context, info RequestInfo)
+	OnFirstToken func(ctx context.Context, info RequestInfo)
+	OnResponse   func(ctx context.Context, info RequestInfo)
+	OnError      func(ctx context.Context, info RequestInfo)
+}
+
+// WithLogger sets the structured logger used by the LLM and the client it
+// constructs.
+func WithLogger(log *slog.Logger) func(llm *LLM) {
+	return func(llm *LLM) {
+		llm.log = SlogLogger(log)
+	}
+}
+
+// WithHooks sets the hooks called during the lifecycle of the requests.
+func WithHooks(hooks Hooks) func(llm *LLM) {
+	return func(llm *LLM) {
+		llm.hooks = hooks
+	}
+}
+
+// SlogLogger adapts a structured logger to the Logger used by the clients.
+// Messages logged with an ERROR value are logged at the error level.
+func SlogLogger(log *slog.Logger) Logger {
+	return func(ctx context.Context, msg string, v ...any) {
+		level := slog.LevelInfo
+		for i := 0; i < len(v); i += 2 {
+			if v[i] == "ERROR" {
+				level = slog.LevelError
+			}
+		}
+
+		log.Log(ctx, level, msg, v...)
+	}
+}
+
+// =============================================================================
+
+// trace tracks a single request for the hooks.
+type trace struct {
+	hooks Hooks
+	info  RequestInfo
+	first bool
+}
+
+func (llm *LLM) startTrace(ctx context.Context, operation string) *trace {
+	t := trace{
+		hooks: llm.hooks,
+		info: RequestInfo{
+			Operation: operation,
+			Model:     llm.model,
+			Start:     time.Now(),
+		},
+	}
+
+	if t.hooks.OnRequest != nil {
+		t.hooks.OnRequest(ctx, t.info)
+	}
+
+	return &t
+}
+
+// firstToken records the time to the first token of a streamed response.
+func (t *trace) firstToken(ctx context.Context) {
+	if t.first {
+		return
+	}
+
+	t.first = true
+	t.info.TimeToFirstToken = time.Since(t.info.Start)
+
+	if t.hooks.OnFirstToken != nil {
+		t.hooks.OnFirstToken(ctx, t.info)
+	}
+}
+
+// finish records the outcome of the request.
+func (t *trace) finish(ctx context.Context, usage Usage, err error) {
+	t.info.Latency = time.Since(t.info.Start)
+	t.info.Usage = usage
+	t.info.Err = err
+
+	switch {
+	case err != nil:
+		if t.hooks.OnError != nil {
+			t.hooks.OnError(ctx, t.info)
+		}
+
+	default:
+		if t.hooks.OnResponse != nil {
+			t.hooks.OnResponse(ctx, t.info)
+		}
+	}
+}
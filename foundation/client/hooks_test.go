@@ -0,0 +1,269 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+	"github.com/ardanlabs/ai-training/foundation/llmtest"
+)
+
+// hookCall records a call to one of the hooks.
+type hookCall struct {
+	hook string
+	info client.RequestInfo
+}
+
+// hookRecorder returns hooks that record every call.
+func hookRecorder() (client.Hooks, func() []hookCall) {
+	var mu sync.Mutex
+	var calls []hookCall
+
+	record := func(hook string) func(ctx context.Context, info client.RequestInfo) {
+		return func(ctx context.Context, info client.RequestInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, hookCall{hook: hook, info: info})
+		}
+	}
+
+	hooks := client.Hooks{
+		OnRequest:    record("request"),
+		OnFirstToken: record("first_token"),
+		OnResponse:   record("response"),
+		OnError:      record("error"),
+	}
+
+	recorded := func() []hookCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(calls)
+	}
+
+	return hooks, recorded
+}
+
+func TestHooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		stream    bool
+		resp      llmtest.Response
+		operation string
+		expected  []string
+		usage     client.Usage
+	}{
+		{
+			name:      "chat",
+			resp:      llmtest.Response{Content: "one two three"},
+			operation: "chat",
+			expected:  []string{"request", "response"},
+			usage:     client.Usage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5},
+		},
+		{
+			name:      "chat error",
+			resp:      llmtest.Response{StatusCode: http.StatusBadRequest, Error: "bad request"},
+			operation: "chat",
+			expected:  []string{"request", "error"},
+		},
+		{
+			name:      "stream",
+			stream:    true,
+			resp:      llmtest.Response{Content: "one two three", Latency: 10 * time.Millisecond},
+			operation: "chat_stream",
+			expected:  []string{"request", "first_token", "response"},
+			usage:     client.Usage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5},
+		},
+		{
+			name:      "stream error",
+			stream:    true,
+			resp:      llmtest.Response{StatusCode: http.StatusBadRequest, Error: "bad request"},
+			operation: "chat_stream",
+			expected:  []string{"request", "error"},
+		},
+		{
+			name:      "error mid stream",
+			stream:    true,
+			resp:      llmtest.Response{Content: "one two three", StreamError: "overloaded"},
+			operation: "chat_stream",
+			expected:  []string{"request", "first_token", "error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.New()
+			defer srv.Close()
+
+			srv.Script(tt.resp)
+
+			hooks, recorded := hookRecorder()
+
+			llm, err := client.NewLLM(srv.ChatURL(), "test-model", client.WithHooks(hooks))
+			if err != nil {
+				t.Fatalf("constructing llm: %s", err)
+			}
+
+			conv := client.NewConversation("")
+			conv.Add(client.UserMessage("hello world"))
+
+			ctx := context.Background()
+
+			var chatErr error
+			switch tt.stream {
+			case true:
+				stream, err := llm.ChatStream(ctx, conv)
+				if err != nil {
+					chatErr = err
+					break
+				}
+				for range stream.Events() {
+				}
+				chatErr = stream.Err()
+
+			default:
+				_, chatErr = llm.Chat(ctx, conv)
+			}
+
+			calls := recorded()
+
+			called := make([]string, len(calls))
+			for i, c := range calls {
+				called[i] = c.hook
+			}
+
+			if !slices.Equal(called, tt.expected) {
+				t.Fatalf("got hooks %v, expected %v", called, tt.expected)
+			}
+
+			for _, c := range calls {
+				if c.info.Operation != tt.operation || c.info.Model != "test-model" || c.info.Start.IsZero() {
+					t.Errorf("%s: got %+v, expected a %s request for test-model", c.hook, c.info, tt.operation)
+				}
+			}
+
+			for _, c := range calls[1:] {
+				if !c.info.Start.Equal(calls[0].info.Start) {
+					t.Errorf("%s: got start %s, expected the start of the request %s", c.hook, c.info.Start, calls[0].info.Start)
+				}
+			}
+
+			last := calls[len(calls)-1].info
+
+			if last.Err != chatErr {
+				t.Errorf("got error %v, expected the error returned %v", last.Err, chatErr)
+			}
+
+			if last.Usage != tt.usage {
+				t.Errorf("got usage %+v, expected %+v", last.Usage, tt.usage)
+			}
+
+			if last.Latency <= 0 || last.Latency < last.TimeToFirstToken {
+				t.Errorf("got latency %s, expected it to include the time to first token %s", last.Latency, last.TimeToFirstToken)
+			}
+
+			for _, c := range calls {
+				if c.hook == "first_token" && (c.info.TimeToFirstToken <= 0 || c.info.TimeToFirstToken != last.TimeToFirstToken) {
+					t.Errorf("got time to first token %s, expected it set and reported on completion", c.info.TimeToFirstToken)
+				}
+			}
+
+			if !tt.stream && last.TimeToFirstToken != 0 {
+				t.Errorf("got time to first token %s, expected it unset without streaming", last.TimeToFirstToken)
+			}
+
+			if tt.name == "stream" && last.TimeToFirstToken < tt.resp.Latency {
+				t.Errorf("got time to first token %s, expected at least the latency %s", last.TimeToFirstToken, tt.resp.Latency)
+			}
+		})
+	}
+}
+
+func TestHooksQueueWait(t *testing.T) {
+	srv := llmtest.New(llmtest.WithChunkDelay(time.Second))
+	defer srv.Close()
+
+	hooks, recorded := hookRecorder()
+
+	llm, err := client.NewLLM(srv.ChatURL(), "test-model", client.WithHooks(hooks), client.WithMaxInflight(1))
+	if err != nil {
+		t.Fatalf("constructing llm: %s", err)
+	}
+
+	ctx := context.Background()
+	conv := conversation("hello world")
+
+	// The open stream holds the only slot, so the chat waits until it is
+	// closed.
+	stream, err := llm.ChatStream(ctx, conv)
+	if err != nil {
+		t.Fatalf("chat stream: %s", err)
+	}
+
+	const hold = 50 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := llm.Chat(ctx, conv)
+		done <- err
+	}()
+
+	time.Sleep(hold)
+	stream.Close()
+
+	if err := <-done; err != nil {
+		t.Fatalf("chat: %s", err)
+	}
+
+	var info client.RequestInfo
+	for _, c := range recorded() {
+		if c.hook == "response" && c.info.Operation == "chat" {
+			info = c.info
+		}
+	}
+
+	if info.QueueWait < hold {
+		t.Errorf("got queue wait %s, expected at least %s", info.QueueWait, hold)
+	}
+
+	if info.Latency < info.QueueWait {
+		t.Errorf("got latency %s, expected it to include the queue wait %s", info.Latency, info.QueueWait)
+	}
+}
+
+func TestClientSlog(t *testing.T) {
+	srv := llmtest.New()
+	defer srv.Close()
+
+	srv.Script(llmtest.Response{Content: "hello", StreamError: "overloaded"})
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, nil))
+
+	sse := client.NewSSE[client.ChatSSE](client.NoopLogger, client.WithSlog(log))
+
+	body := client.D{
+		"model":    "test-model",
+		"messages": []client.D{{"role": "user", "content": "hello"}},
+		"stream":   true,
+	}
+
+	ch := make(chan client.ChatSSE)
+	if err := sse.Do(context.Background(), http.MethodPost, srv.ChatURL(), body, ch); err != nil {
+		t.Fatalf("do: %s", err)
+	}
+
+	for range ch {
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "sseclient: do:") || !strings.Contains(out, "overloaded") {
+		t.Errorf("got log %q, expected the stream error logged at the error level", out)
+	}
+}
@@ -0,0 +1,249 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Priority determines the order requests waiting for a limiter are served
+// in. Requests with a higher priority are served first and requests with
+// the same priority are served in the order they arrived.
+type Priority int
+
+// Set of priorities. By default chat requests are interactive and
+// embedding requests are batch requests.
+const (
+	PriorityBatch Priority = iota
+	PriorityInteractive
+)
+
+const numPriorities = 2
+
+// LimiterStats represents the state of a limiter.
+type LimiterStats struct {
+	MaxInflight int
+	Inflight    int
+	Queued      map[Priority]int
+	Served      int
+	Waited      int
+	WaitTime    time.Duration
+}
+
+// QueueDepth returns the number of requests waiting across all priorities.
+func (s LimiterStats) QueueDepth() int {
+	var n int
+	for _, q := range s.Queued {
+		n += q
+	}
+
+	return n
+}
+
+// =============================================================================
+
+// Limiter limits the number of requests in flight to an endpoint. Requests
+// over the limit wait in a queue until a request completes or their context
+// is canceled. Share a limiter between the LLMs that access the same
+// endpoint with WithLimiter.
+type Limiter struct {
+	max int
+
+	mu       sync.Mutex
+	inflight int
+	queues   [numPriorities][]*waiter
+	served   int
+	waited   int
+	waitTime time.Duration
+}
+
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// NewLimiter constructs a limiter that allows the specified number of
+// requests in flight.
+func NewLimiter(maxInflight int) *Limiter {
+	return &Limiter{
+		max: max(maxInflight, 1),
+	}
+}
+
+// Acquire waits until the request can be sent. Every successful call must
+// be followed by a call to Release once the request completes. The time
+// spent waiting is returned.
+func (l *Limiter) Acquire(ctx context.Context, priority Priority) (time.Duration, error) {
+	priority = min(max(priority, PriorityBatch), PriorityInteractive)
+
+	l.mu.Lock()
+
+	if l.inflight < l.max && l.queued() == 0 {
+		l.inflight++
+		l.served++
+		l.mu.Unlock()
+		return 0, nil
+	}
+
+	w := waiter{
+		ready: make(chan struct{}),
+	}
+	l.queues[priority] = append(l.queues[priority], &w)
+
+	l.mu.Unlock()
+
+	start := time.Now()
+
+	select {
+	case <-w.ready:
+		wait := time.Since(start)
+
+		l.mu.Lock()
+		l.waitTime += wait
+		l.mu.Unlock()
+
+		return wait, nil
+
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		// The request could have been granted while the context was being
+		// canceled, in which case the slot is passed on.
+		if w.granted {
+			l.inflight--
+			l.dispatch()
+			return 0, ctx.Err()
+		}
+
+		q := l.queues[priority]
+		if i := slices.Index(q, &w); i != -1 {
+			l.queues[priority] = slices.Delete(q, i, i+1)
+		}
+
+		return 0, ctx.Err()
+	}
+}
+
+// Release marks a request acquired with Acquire as completed, allowing the
+// next request in the queue to be sent.
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight--
+	l.dispatch()
+}
+
+// Stats returns the current state of the limiter.
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	queued := make(map[Priority]int, numPriorities)
+	for p, q := range l.queues {
+		queued[Priority(p)] = len(q)
+	}
+
+	return LimiterStats{
+		MaxInflight: l.max,
+		Inflight:    l.inflight,
+		Queued:      queued,
+		Served:      l.served,
+		Waited:      l.waited,
+		WaitTime:    l.waitTime,
+	}
+}
+
+// dispatch grants the free slots to the waiting requests with the highest
+// priority.
+func (l *Limiter) dispatch() {
+	for p := numPriorities - 1; p >= 0 && l.inflight < l.max; p-- {
+		for len(l.queues[p]) > 0 && l.inflight < l.max {
+			w := l.queues[p][0]
+			l.queues[p] = l.queues[p][1:]
+
+			w.granted = true
+			l.inflight++
+			l.served++
+			l.waited++
+			close(w.ready)
+		}
+	}
+}
+
+// =============================================================================
+
+// WithLimiter sets the limiter used to limit the requests in flight. Share a
+// limiter between LLMs to limit the requests they make to the same endpoint.
+func WithLimiter(l *Limiter) func(llm *LLM) {
+	return func(llm *LLM) {
+		llm.limiter = l
+	}
+}
+
+// WithMaxInflight limits the number of requests the LLM has in flight.
+func WithMaxInflight(n int) func(llm *LLM) {
+	return func(llm *LLM) {
+		llm.limiter = NewLimiter(n)
+	}
+}
+
+// WithPriority sets the priority of the request when it has to wait for the
+// limiter of the LLM.
+func WithPriority(priority Priority) withParam {
+	return withParam{
+		typ: "priority",
+		d: D{
+			"priority": priority,
+		},
+	}
+}
+
+// Limiter returns the limiter used by the LLM, which is nil when the number
+// of requests in flight is not limited.
+func (llm *LLM) Limiter() *Limiter {
+	return llm.limiter
+}
+
+// acquire waits for the limiter of the LLM, recording the time spent waiting
+// on the trace.
+func (llm *LLM) acquire(ctx context.Context, t *trace, priority Priority) error {
+	if llm.limiter == nil {
+		return nil
+	}
+
+	wait, err := llm.limiter.Acquire(ctx, priority)
+	t.info.QueueWait = wait
+
+	return err
+}
+
+func (llm *LLM) release() {
+	if llm.limiter != nil {
+		llm.limiter.Release()
+	}
+}
+
+// priority returns the priority set by the options or the default.
+func priority(options []withParam, def Priority) Priority {
+	for _, opt := range options {
+		if opt.typ == "priority" {
+			return opt.d["priority"].(Priority)
+		}
+	}
+
+	return def
+}
+
+// =============================================================================
+
+func (l *Limiter) queued() int {
+	var n int
+	for _, q := range l.queues {
+		n += len(q)
+	}
+
+	return n
+}
@@ -0,0 +1,249 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until the limiter has the specified number of requests
+// waiting.
+func waitQueued(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Stats().QueueDepth() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got queue depth %d, expected %d", l.Stats().QueueDepth(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiterOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		priorities []Priority
+		expected   []int
+	}{
+		{name: "fifo batch", priorities: []Priority{PriorityBatch, PriorityBatch, PriorityBatch}, expected: []int{0, 1, 2}},
+		{name: "fifo interactive", priorities: []Priority{PriorityInteractive, PriorityInteractive, PriorityInteractive}, expected: []int{0, 1, 2}},
+		{name: "interactive first", priorities: []Priority{PriorityBatch, PriorityInteractive, PriorityBatch, PriorityInteractive}, expected: []int{1, 3, 0, 2}},
+		{name: "out of range", priorities: []Priority{-1, 5, PriorityBatch}, expected: []int{1, 0, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(1)
+			ctx := context.Background()
+
+			if _, err := l.Acquire(ctx, PriorityInteractive); err != nil {
+				t.Fatalf("acquire: %s", err)
+			}
+
+			var mu sync.Mutex
+			var order []int
+			var wg sync.WaitGroup
+
+			// Every request is queued before the next one so the order they
+			// arrived in is known.
+			for i, p := range tt.priorities {
+				wg.Go(func() {
+					if _, err := l.Acquire(ctx, p); err != nil {
+						t.Errorf("acquire %d: %s", i, err)
+						return
+					}
+
+					mu.Lock()
+					order = append(order, i)
+					mu.Unlock()
+
+					l.Release()
+				})
+				waitQueued(t, l, i+1)
+			}
+
+			l.Release()
+			wg.Wait()
+
+			if !slices.Equal(order, tt.expected) {
+				t.Errorf("got order %v, expected %v", order, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLimiterStats(t *testing.T) {
+	l := NewLimiter(2)
+	ctx := context.Background()
+
+	for range 2 {
+		if wait, err := l.Acquire(ctx, PriorityBatch); err != nil || wait != 0 {
+			t.Fatalf("acquire: got %s %v, expected no wait", wait, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, p := range []Priority{PriorityBatch, PriorityInteractive, PriorityInteractive} {
+		wg.Go(func() {
+			if _, err := l.Acquire(ctx, p); err != nil {
+				t.Errorf("acquire: %s", err)
+				return
+			}
+			l.Release()
+		})
+		waitQueued(t, l, i+1)
+	}
+
+	s := l.Stats()
+
+	expected := map[Priority]int{PriorityBatch: 1, PriorityInteractive: 2}
+	if s.MaxInflight != 2 || s.Inflight != 2 || s.QueueDepth() != 3 || s.Queued[PriorityBatch] != 1 || s.Queued[PriorityInteractive] != 2 {
+		t.Errorf("got %+v, expected 2 of 2 in flight and queued %v", s, expected)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	l.Release()
+	l.Release()
+	wg.Wait()
+
+	s = l.Stats()
+
+	if s.Inflight != 0 || s.QueueDepth() != 0 || s.Served != 5 || s.Waited != 3 {
+		t.Errorf("got %+v, expected 5 served, 3 of them after waiting", s)
+	}
+
+	if s.WaitTime < 3*10*time.Millisecond {
+		t.Errorf("got wait time %s, expected at least 30ms", s.WaitTime)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l := NewLimiter(1)
+
+	if _, err := l.Acquire(context.Background(), PriorityBatch); err != nil {
+		t.Fatalf("acquire: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(ctx, PriorityBatch)
+		errs <- err
+	}()
+	waitQueued(t, l, 1)
+
+	cancel()
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, expected %v", err, context.Canceled)
+	}
+
+	// The canceled request leaves the queue without taking the slot.
+	if s := l.Stats(); s.QueueDepth() != 0 || s.Inflight != 1 {
+		t.Errorf("got %+v, expected an empty queue and 1 in flight", s)
+	}
+
+	l.Release()
+
+	if wait, err := l.Acquire(context.Background(), PriorityBatch); err != nil || wait != 0 {
+		t.Errorf("acquire after release: got %s %v, expected no wait", wait, err)
+	}
+}
+
+func TestLimiterGrantCancelRace(t *testing.T) {
+	l := NewLimiter(1)
+
+	// Every waiter is canceled while the slot it waits for is released, so it
+	// is either granted the slot or canceled. Either way the slot must not
+	// leak.
+	for range 500 {
+		if _, err := l.Acquire(context.Background(), PriorityBatch); err != nil {
+			t.Fatalf("acquire: %s", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := l.Acquire(ctx, PriorityInteractive)
+			errs <- err
+		}()
+		waitQueued(t, l, 1)
+
+		go cancel()
+		l.Release()
+
+		if err := <-errs; err == nil {
+			l.Release()
+		}
+
+		if s := l.Stats(); s.Inflight != 0 || s.QueueDepth() != 0 {
+			t.Fatalf("got %+v, expected nothing in flight or queued", s)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if wait, err := l.Acquire(ctx, PriorityBatch); err != nil || wait != 0 {
+		t.Errorf("acquire after the races: got %s %v, expected no wait", wait, err)
+	}
+}
+
+func TestLimiterGrantedWhileCanceled(t *testing.T) {
+	l := NewLimiter(1)
+
+	if _, err := l.Acquire(context.Background(), PriorityBatch); err != nil {
+		t.Fatalf("acquire: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(ctx, PriorityBatch)
+		errs <- err
+	}()
+	waitQueued(t, l, 1)
+
+	// A second waiter must receive the slot the canceled one gives up.
+	next := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(context.Background(), PriorityBatch)
+		next <- err
+	}()
+	waitQueued(t, l, 2)
+
+	// Holding the lock, the context is canceled and the slot of the first
+	// request granted before the first waiter can remove itself from the
+	// queue. The ready channel is left open so the waiter sees the cancel.
+	l.mu.Lock()
+	cancel()
+	w := l.queues[PriorityBatch][0]
+	l.queues[PriorityBatch] = l.queues[PriorityBatch][1:]
+	w.granted = true
+	l.mu.Unlock()
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, expected %v", err, context.Canceled)
+	}
+
+	select {
+	case err := <-next:
+		if err != nil {
+			t.Fatalf("acquire: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the slot of the canceled request leaked")
+	}
+
+	if s := l.Stats(); s.Inflight != 1 || s.QueueDepth() != 0 {
+		t.Errorf("got %+v, expected the slot passed on to the second waiter", s)
+	}
+}
@@ -2,57 +2,241 @@ package client
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
-	"log"
 	"maps"
 	"net/http"
-	"os"
-	"strconv"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
 )
 
 type LLM struct {
-	cln           *Client
-	clnSSE        *SSEClient[ChatSSE]
-	url           string
+	provider      Provider
 	model         string
 	contextWindow int
+	maxTokens     int
+	params        D
+	http          *http.Client
+	headers       http.Header
+	log           Logger
+	hooks         Hooks
+	limiter       *Limiter
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+// NewLLM constructs an LLM that accesses the model through the specified
+// OpenAI-compatible endpoint. An error is returned if the options provide
+// an invalid configuration.
+func NewLLM(url string, model string, options ...func(llm *LLM)) (*LLM, error) {
+	if url == "" {
+		return nil, errors.New("llm: url is required")
+	}
+
+	llm := newLLM(model, options)
+
+	if err := llm.validate(); err != nil {
+		return nil, err
+	}
+
+	llm.provider = NewOpenAI(llm.log, url, llm.clientOptions()...)
+
+	return llm, nil
+}
+
+// NewProviderLLM constructs an LLM that uses the specified provider to
+// access the model. The options that configure the HTTP requests, like
+// WithHTTPClient and WithAPIKey, don't apply since the provider is already
+// constructed.
+func NewProviderLLM(provider Provider, model string, options ...func(llm *LLM)) (*LLM, error) {
+	if provider == nil {
+		return nil, errors.New("llm: provider is required")
+	}
+
+	llm := newLLM(model, options)
+
+	if err := llm.validate(); err != nil {
+		return nil, err
+	}
+
+	llm.provider = provider
+
+	return llm, nil
+}
+
+// newLLM constructs an LLM with the defaults and applies the options. The
+// configuration needs to be validated before the LLM is used.
+func newLLM(model string, options []func(llm *LLM)) *LLM {
+	llm := LLM{
+		model: model,
+		params: D{
+			"temperature": 1.0,
+			"top_p":       0.5,
+			"top_k":       20,
+		},
+		headers: make(http.Header),
+		log:     StdoutLogger,
+	}
+
+	for _, option := range options {
+		option(&llm)
+	}
+
+	return &llm
+}
+
+// validate checks the configuration set by the options and sets the
+// defaults that depend on it.
+func (llm *LLM) validate() error {
+	if llm.model == "" {
+		return errors.New("llm: model is required")
+	}
+
+	switch {
+	case llm.contextWindow < 0:
+		return fmt.Errorf("llm: invalid context window %d", llm.contextWindow)
+	case llm.contextWindow == 0:
+		llm.contextWindow = 1024 * 4
+	}
+
+	switch {
+	case llm.maxTokens < 0:
+		return fmt.Errorf("llm: invalid max tokens %d", llm.maxTokens)
+	case llm.maxTokens == 0:
+		llm.maxTokens = llm.contextWindow
+	}
+
+	return nil
+}
+
+// clientOptions returns the options for the HTTP client of a provider set
+// by the options of the LLM.
+func (llm *LLM) clientOptions() []func(cln *Client) {
+	clnOptions := []func(cln *Client){
+		WithRetry(DefaultRetryPolicy),
+	}
+
+	if llm.http != nil {
+		clnOptions = append(clnOptions, WithClient(llm.http))
+	}
+
+	for key := range llm.headers {
+		clnOptions = append(clnOptions, WithHeader(key, llm.headers.Get(key)))
+	}
+
+	return clnOptions
+}
+
+// WithContextWindow sets the maximum number of tokens that can be sent and
+// received by the model. The default is 4K.
+func WithContextWindow(tokens int) func(llm *LLM) {
+	return func(llm *LLM) {
+		llm.contextWindow = tokens
+	}
+}
+
+// WithMaxTokens sets the maximum number of tokens the model can respond
+// with. The default is the size of the context window.
+func WithMaxTokens(tokens int) func(llm *LLM) {
+	return func(llm *LLM) {
+		llm.maxTokens = tokens
+	}
 }
 
-func NewLLM(url string, model string) *LLM {
-	contextWindow := 1024 * 4
-	if v := os.Getenv("OLLAMA_CONTEXT_LENGTH"); v != "" {
-		var err error
-		contextWindow, err = strconv.Atoi(v)
-		if err != nil {
-			log.Fatal(err)
+// WithDefaultParams sets the sampling parameters used by the chat requests
+// that don't provide their own with WithParams. The other sampling options,
+// like WithStop, WithSeed, WithPenalties, WithRepeatPenalty and WithMinP,
+// can be provided to be used by default as well. Any other option is
+// ignored.
+func WithDefaultParams(temperature float32, topP float32, topK int, options ...withParam) func(llm *LLM) {
+	return func(llm *LLM) {
+		llm.params = D{
+			"temperature": temperature,
+			"top_p":       topP,
+			"top_k":       topK,
+		}
+
+		for _, opt := range options {
+			if opt.typ == "params" {
+				maps.Copy(llm.params, opt.d)
+			}
 		}
 	}
+}
 
-	return &LLM{
-		cln:           New(StdoutLogger),
-		clnSSE:        NewSSE[ChatSSE](StdoutLogger),
-		url:           url,
-		model:         model,
-		contextWindow: contextWindow,
+// WithHTTPClient sets the HTTP client used to make the requests.
+func WithHTTPClient(http *http.Client) func(llm *LLM) {
+	return func(llm *LLM) {
+		llm.http = http
+	}
+}
+
+// WithAPIKey authenticates the requests with the key as a bearer token, as
+// expected by hosted OpenAI-compatible endpoints.
+func WithAPIKey(key string) func(llm *LLM) {
+	return func(llm *LLM) {
+		llm.headers.Set("Authorization", "Bearer "+key)
+	}
+}
+
+// WithHeaders sets headers that will be sent with every request.
+func WithHeaders(headers map[string]string) func(llm *LLM) {
+	return func(llm *LLM) {
+		for key, value := range headers {
+			llm.headers.Set(key, value)
+		}
 	}
 }
 
+// ContextWindow returns the maximum number of tokens that can be sent and
+// received by the model.
+func (llm *LLM) ContextWindow() int {
+	return llm.contextWindow
+}
+
+// Usage returns the tokens used by all the chat requests made by the LLM.
+func (llm *LLM) Usage() Usage {
+	llm.mu.Lock()
+	defer llm.mu.Unlock()
+
+	return llm.usage
+}
+
+// Cost returns the cost in dollars of all the chat requests made by the LLM
+// based on the price table. It returns false if the model is not in the
+// table.
+func (llm *LLM) Cost(prices PriceTable) (float64, bool) {
+	return prices.Cost(llm.model, llm.Usage())
+}
+
+// addUsage records the usage reported for a response against the LLM and
+// the conversation that was sent.
+func (llm *LLM) addUsage(conv *Conversation, u Usage) {
+	llm.mu.Lock()
+	llm.usage = llm.usage.Add(u)
+	llm.mu.Unlock()
+
+	conv.addUsage(u)
+}
+
 type withParam struct {
 	typ string
 	d   D
 }
 
 func WithImage(mimeType string, image []byte) withParam {
-	dataBase64 := base64.StdEncoding.EncodeToString(image)
+	return WithParts(ImagePart(mimeType, image))
+}
 
+// WithParts adds the content parts, like images, audio and files, to the
+// message sent by ChatCompletions.
+func WithParts(parts ...ContentPart) withParam {
 	return withParam{
-		typ: "image",
+		typ: "parts",
 		d: D{
-			"type": "image_url",
-			"image_url": D{
-				"url": fmt.Sprintf("data:%s;base64,%s", mimeType, dataBase64),
-			},
+			"parts": parts,
 		},
 	}
 }
@@ -68,121 +252,470 @@ func WithParams(temperature float32, topP float32, topK int) withParam {
 	}
 }
 
-func (llm *LLM) ChatCompletions(ctx context.Context, text string, options ...withParam) (string, error) {
-	content := []D{
-		{
-			"type": "text",
-			"text": text,
+// WithStop sets sequences that end the response when the model generates
+// them. The sequences are not included in the response.
+func WithStop(stop ...string) withParam {
+	return withParam{
+		typ: "params",
+		d: D{
+			"stop": stop,
 		},
 	}
+}
 
-	params := D{
-		"temperature": 1.0,
-		"top_p":       0.5,
-		"top_k":       20,
+// WithSeed sets the seed used for sampling so responses can be reproduced,
+// for the models and servers that support it.
+func WithSeed(seed int) withParam {
+	return withParam{
+		typ: "params",
+		d: D{
+			"seed": seed,
+		},
 	}
+}
 
-	for _, opt := range options {
-		switch opt.typ {
-		case "image":
-			content = append(content, opt.d)
-		case "params":
-			params = opt.d
-		}
+// WithPenalties sets the presence and frequency penalties, which discourage
+// the model from repeating tokens that are already in the response.
+func WithPenalties(presence float32, frequency float32) withParam {
+	return withParam{
+		typ: "params",
+		d: D{
+			"presence_penalty":  presence,
+			"frequency_penalty": frequency,
+		},
 	}
+}
 
-	d := D{
-		"model": llm.model,
-		"messages": []D{
-			{
-				"role":    "user",
-				"content": content,
-			},
+// WithRepeatPenalty sets the repeat penalty used by llama.cpp based servers
+// like Ollama. A value of 1.0 disables the penalty.
+func WithRepeatPenalty(penalty float32) withParam {
+	return withParam{
+		typ: "params",
+		d: D{
+			"repeat_penalty": penalty,
 		},
-		"max_tokens": llm.contextWindow,
 	}
+}
 
-	maps.Copy(d, params)
+// WithMinP sets the minimum probability for a token to be considered,
+// relative to the probability of the most likely token.
+func WithMinP(minP float32) withParam {
+	return withParam{
+		typ: "params",
+		d: D{
+			"min_p": minP,
+		},
+	}
+}
 
-	var chat Chat
-	if err := llm.cln.Do(ctx, http.MethodPost, llm.url, d, &chat); err != nil {
-		return "", fmt.Errorf("do: %w", err)
+// WithMaxCompletionTokens limits the number of tokens the model can respond
+// with for a single request, replacing the limit set for the LLM.
+func WithMaxCompletionTokens(tokens int) withParam {
+	return withParam{
+		typ: "max_tokens",
+		d: D{
+			"max_tokens": tokens,
+		},
 	}
+}
 
-	if len(chat.Choices) == 0 {
-		return "", fmt.Errorf("no response")
+// WithLogprobs asks for the log probabilities of the tokens in the response
+// along with the specified number of most likely alternatives per token. The
+// log probabilities are provided by the Logprobs field of the message.
+func WithLogprobs(topLogprobs int) withParam {
+	return withParam{
+		typ: "logprobs",
+		d: D{
+			"top_logprobs": topLogprobs,
+		},
 	}
+}
 
-	return chat.Choices[0].Message.Content, nil
+// WithChoices sets the number of completions ChatChoices will generate for
+// the conversation. The other chat methods always generate one completion.
+func WithChoices(n int) withParam {
+	return withParam{
+		typ: "n",
+		d: D{
+			"n": n,
+		},
+	}
 }
 
-func (llm *LLM) ChatCompletionsSSE(ctx context.Context, content string) (chan ChatSSE, error) {
-	d := D{
-		"model": llm.model,
-		"messages": []D{
-			{
-				"role":    "user",
-				"content": content,
-			},
+func WithTools(tools []D) withParam {
+	return withParam{
+		typ: "tools",
+		d: D{
+			"tools": tools,
 		},
-		"temperature": 1.0,
-		"top_p":       0.5,
-		"top_k":       20,
-		"stream":      true,
-		"max_tokens":  llm.contextWindow,
 	}
+}
 
-	ch := make(chan ChatSSE, 100)
-	if err := llm.clnSSE.Do(ctx, http.MethodPost, llm.url, d, ch); err != nil {
-		return nil, fmt.Errorf("do: %w", err)
+// WithThinking enables extended thinking for providers that support it,
+// allowing the model to use up to the specified number of tokens reasoning
+// before it responds.
+func WithThinking(budgetTokens int) withParam {
+	return withParam{
+		typ: "thinking",
+		d: D{
+			"budget_tokens": budgetTokens,
+		},
+	}
+}
+
+// WithBatch limits the number of inputs and the estimated number of tokens
+// sent in a single embedding request. A value of 0 means no limit.
+func WithBatch(size int, tokens int) withParam {
+	return withParam{
+		typ: "batch",
+		d: D{
+			"size":   size,
+			"tokens": tokens,
+		},
+	}
+}
+
+// WithConcurrency sets the number of embedding requests that can be in
+// flight at the same time.
+func WithConcurrency(n int) withParam {
+	return withParam{
+		typ: "concurrency",
+		d: D{
+			"n": n,
+		},
+	}
+}
+
+func (llm *LLM) ChatCompletions(ctx context.Context, text string, options ...withParam) (string, error) {
+	msg := UserMessage(text)
+
+	for _, opt := range options {
+		if opt.typ == "parts" {
+			msg.Parts = append(msg.Parts, opt.d["parts"].([]ContentPart)...)
+		}
+	}
+
+	conv := Conversation{
+		Messages: []Message{msg},
+	}
+
+	resp, err := llm.Chat(ctx, &conv, options...)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Content, nil
+}
+
+func (llm *LLM) ChatCompletionsSSE(ctx context.Context, content string) (chan ChatSSE, error) {
+	conv := Conversation{
+		Messages: []Message{UserMessage(content)},
+	}
+
+	stream, err := llm.ChatStream(ctx, &conv)
+	if err != nil {
+		return nil, err
 	}
 
+	ch := make(chan ChatSSE, 100)
+
+	go func() {
+		defer close(ch)
+
+		for v := range stream.Events() {
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := stream.Err(); err != nil {
+			llm.log(ctx, "llm: chatcompletionssse:", "ERROR", err)
+		}
+	}()
+
 	return ch, nil
 }
 
-func (llm *LLM) EmbedText(ctx context.Context, input string) ([]float64, error) {
-	d := D{
-		"model":              llm.model,
-		"truncate":           true,
-		"truncate_direction": "right",
-		"input":              input,
+// Chat sends the conversation to the model and returns the message the
+// model responded with. The response is not added to the conversation.
+func (llm *LLM) Chat(ctx context.Context, conv *Conversation, options ...withParam) (Message, error) {
+	req := newChatRequest(llm.model, llm.maxTokens, llm.params, conv.Messages, options)
+	req.N = 0
+
+	t := llm.startTrace(ctx, "chat")
+
+	if err := llm.acquire(ctx, t, priority(options, PriorityInteractive)); err != nil {
+		t.finish(ctx, Usage{}, err)
+		return Message{}, err
+	}
+
+	msg, err := llm.provider.Chat(ctx, req)
+	llm.release()
+
+	if err != nil {
+		t.finish(ctx, Usage{}, err)
+		return Message{}, err
+	}
+
+	llm.addUsage(conv, msg.Usage)
+	t.finish(ctx, msg.Usage, nil)
+
+	return msg, nil
+}
+
+// ChatChoices sends the conversation to the model and returns the number of
+// completions set with WithChoices, which defaults to 1. For the providers
+// that can't generate multiple completions in one request, a request is made
+// per completion. The responses are not added to the conversation.
+func (llm *LLM) ChatChoices(ctx context.Context, conv *Conversation, options ...withParam) ([]Message, error) {
+	req := newChatRequest(llm.model, llm.maxTokens, llm.params, conv.Messages, options)
+	req.N = max(req.N, 1)
+
+	t := llm.startTrace(ctx, "chat_choices")
+
+	if err := llm.acquire(ctx, t, priority(options, PriorityInteractive)); err != nil {
+		t.finish(ctx, Usage{}, err)
+		return nil, err
 	}
 
-	var resp Embedding
-	if err := llm.cln.Do(ctx, http.MethodPost, llm.url, d, &resp); err != nil {
-		return nil, fmt.Errorf("do: %w", err)
+	msgs, err := chatChoices(ctx, llm.provider, req)
+	llm.release()
+
+	if err != nil {
+		t.finish(ctx, Usage{}, err)
+		return nil, err
 	}
 
-	if len(resp.Data) == 0 {
-		return nil, fmt.Errorf("no embedding")
+	var usage Usage
+	for _, msg := range msgs {
+		usage = usage.Add(msg.Usage)
 	}
 
-	return resp.Data[0].Embedding, nil
+	llm.addUsage(conv, usage)
+	t.finish(ctx, usage, nil)
+
+	return msgs, nil
 }
 
+// ChatStream sends the conversation to the model and returns a stream of the
+// response chunks. Check the stream's Err method once all the chunks have
+// been received. The usage reported in the stream is recorded once the chunk
+// providing it is received.
+func (llm *LLM) ChatStream(ctx context.Context, conv *Conversation, options ...withParam) (*Stream[ChatSSE], error) {
+	req := newChatRequest(llm.model, llm.maxTokens, llm.params, conv.Messages, options)
+	req.N = 0
+
+	t := llm.startTrace(ctx, "chat_stream")
+
+	if err := llm.acquire(ctx, t, priority(options, PriorityInteractive)); err != nil {
+		t.finish(ctx, Usage{}, err)
+		return nil, err
+	}
+
+	// Closing the stream cancels the request, which ends the stream and
+	// releases the limiter slot.
+	ctx, cancel := context.WithCancel(ctx)
+
+	stream, err := llm.provider.ChatStream(ctx, req)
+	if err != nil {
+		cancel()
+		llm.release()
+		t.finish(ctx, Usage{}, err)
+		return nil, err
+	}
+
+	// The request stays in flight until the stream ends.
+	s := NewStream(ctx, func(send func(ChatSSE) error) (err error) {
+		var usage Usage
+		defer func() {
+			cancel()
+			llm.release()
+			t.finish(ctx, usage, err)
+		}()
+
+		for v := range stream.Events() {
+			if v.Usage != nil {
+				usage = usage.Add(*v.Usage)
+				llm.addUsage(conv, *v.Usage)
+			}
+
+			if len(v.Choices) > 0 {
+				delta := v.Choices[0].Delta
+				if delta.Content != "" || delta.Reasoning != "" || len(delta.ToolCalls) > 0 {
+					t.firstToken(ctx)
+				}
+			}
+
+			if err := send(v); err != nil {
+				return err
+			}
+		}
+
+		return stream.Err()
+	})
+
+	s.cancelWith(cancel)
+
+	return s, nil
+}
+
+func (llm *LLM) EmbedText(ctx context.Context, input string, options ...withParam) ([]float64, error) {
+	req := EmbedRequest{
+		Model:     llm.model,
+		Texts:     []string{input},
+		KeepAlive: keepAlive(options),
+	}
+
+	vectors, err := llm.embed(ctx, req, priority(options, PriorityBatch))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vectors) == 0 {
+		return nil, fmt.Errorf("embed text: %w", ErrNoEmbedding)
+	}
+
+	return vectors[0], nil
+}
+
+// EmbedTexts embeds the inputs using as few requests as possible. The inputs
+// are split into batches, 32 inputs per batch by default, and the vectors
+// are returned in the same order as the inputs.
+func (llm *LLM) EmbedTexts(ctx context.Context, inputs []string, options ...withParam) ([][]float64, error) {
+	size := 32
+	tokens := 0
+	concurrency := 1
+
+	for _, opt := range options {
+		switch opt.typ {
+		case "batch":
+			size = opt.d["size"].(int)
+			tokens = opt.d["tokens"].(int)
+		case "concurrency":
+			concurrency = max(opt.d["n"].(int), 1)
+		}
+	}
+
+	vectors := make([][]float64, len(inputs))
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+
+	for _, b := range batches(inputs, size, tokens) {
+		g.Go(func() error {
+			req := EmbedRequest{
+				Model:     llm.model,
+				Texts:     inputs[b.start:b.end],
+				KeepAlive: keepAlive(options),
+			}
+
+			resp, err := llm.embed(ctx, req, priority(options, PriorityBatch))
+			if err != nil {
+				return fmt.Errorf("batch[%d:%d]: %w", b.start, b.end, err)
+			}
+
+			if len(resp) != b.end-b.start {
+				return fmt.Errorf("batch[%d:%d]: expected %d embeddings, got %d", b.start, b.end, b.end-b.start, len(resp))
+			}
+
+			for i, vector := range resp {
+				if len(vector) == 0 {
+					return fmt.Errorf("batch[%d:%d]: input[%d]: %w", b.start, b.end, b.start+i, ErrNoEmbedding)
+				}
+			}
+
+			copy(vectors[b.start:b.end], resp)
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return vectors, nil
+}
+
+type batch struct {
+	start int
+	end   int
+}
+
+// batches splits the inputs into ranges that respect the maximum number of
+// inputs and the maximum number of estimated tokens per batch. An input
+// larger than the token budget is placed in a batch of its own.
+func batches(inputs []string, size int, tokens int) []batch {
+	var bs []batch
+
+	start, total := 0, 0
+	for i, input := range inputs {
+		n := estimateTokens(input)
+
+		full := size > 0 && i-start == size
+		over := tokens > 0 && i > start && total+n > tokens
+
+		if full || over {
+			bs = append(bs, batch{start: start, end: i})
+			start, total = i, 0
+		}
+
+		total += n
+	}
+
+	if start < len(inputs) {
+		bs = append(bs, batch{start: start, end: len(inputs)})
+	}
+
+	return bs
+}
+
+// estimateTokens provides a rough count of the tokens in the text, using the
+// common approximation of four characters per token.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// EmbedWithImage embeds the description and the image as a single input,
+// for multimodal embedding models. The description is optional.
 func (llm *LLM) EmbedWithImage(ctx context.Context, description string, image []byte, mimeType string) ([]float64, error) {
-	dataBase64 := base64.StdEncoding.EncodeToString(image)
-
-	d := D{
-		"model": llm.model,
-		"input": []D{
-			{
-				"type": "image_url",
-				"image_url": D{
-					"url": fmt.Sprintf("data:%s;base64,%s", mimeType, dataBase64),
-				},
-			},
-		},
+	var parts []ContentPart
+	if description != "" {
+		parts = append(parts, TextPart(description))
+	}
+
+	req := EmbedRequest{
+		Model: llm.model,
+		Parts: append(parts, ImagePart(mimeType, image)),
 	}
 
-	var resp Embedding
-	if err := llm.cln.Do(ctx, http.MethodPost, llm.url, d, &resp); err != nil {
-		return nil, fmt.Errorf("do: %w", err)
+	vectors, err := llm.embed(ctx, req, PriorityBatch)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(resp.Data) == 0 {
-		return nil, fmt.Errorf("no embedding")
+	if len(vectors) == 0 {
+		return nil, fmt.Errorf("embed with image: %w", ErrNoEmbedding)
 	}
 
-	return resp.Data[0].Embedding, nil
+	return vectors[0], nil
+}
+
+// embed sends the embedding request to the provider, calling the hooks.
+func (llm *LLM) embed(ctx context.Context, req EmbedRequest, priority Priority) ([][]float64, error) {
+	t := llm.startTrace(ctx, "embed")
+
+	if err := llm.acquire(ctx, t, priority); err != nil {
+		t.finish(ctx, Usage{}, err)
+		return nil, err
+	}
+
+	vectors, err := llm.provider.Embed(ctx, req)
+	llm.release()
+
+	t.finish(ctx, Usage{}, err)
+
+	return vectors, err
 }
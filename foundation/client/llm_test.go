@@ -0,0 +1,241 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+	"github.com/ardanlabs/ai-training/foundation/llmtest"
+)
+
+func TestChatStreamClose(t *testing.T) {
+	srv := llmtest.New(llmtest.WithChunkDelay(50 * time.Millisecond))
+	defer srv.Close()
+
+	llm, err := client.NewLLM(srv.ChatURL(), "test-model", client.WithMaxInflight(1))
+	if err != nil {
+		t.Fatalf("constructing llm: %s", err)
+	}
+
+	ctx := context.Background()
+
+	// The stream is abandoned after the first chunk while the server is
+	// still sending, which must release the only limiter slot.
+
+	conv := client.NewConversation("")
+	conv.Add(client.UserMessage(strings.Repeat("word ", 100)))
+
+	stream, err := llm.ChatStream(ctx, conv)
+	if err != nil {
+		t.Fatalf("starting stream: %s", err)
+	}
+
+	<-stream.Events()
+	stream.Close()
+
+	for range stream.Events() {
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	conv = client.NewConversation("")
+	conv.Add(client.UserMessage("hello"))
+
+	if _, err := llm.Chat(ctx, conv); err != nil {
+		t.Fatalf("chat after closing the stream: %s", err)
+	}
+}
+
+// headerTransport records the headers of the requests it sends.
+type headerTransport struct {
+	mu      sync.Mutex
+	headers http.Header
+}
+
+func (ht *headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ht.mu.Lock()
+	ht.headers = r.Header.Clone()
+	ht.mu.Unlock()
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestLLMOptions(t *testing.T) {
+	defaults := client.D{"temperature": 1.0, "top_p": 0.5, "top_k": 20.0}
+
+	tests := []struct {
+		name          string
+		options       []func(llm *client.LLM)
+		contextWindow int
+		maxTokens     float64
+		params        client.D
+		headers       map[string]string
+	}{
+		{
+			name:          "defaults",
+			contextWindow: 4096,
+			maxTokens:     4096,
+			params:        defaults,
+		},
+		{
+			name:          "context window",
+			options:       []func(llm *client.LLM){client.WithContextWindow(8192)},
+			contextWindow: 8192,
+			maxTokens:     8192,
+			params:        defaults,
+		},
+		{
+			name:          "max tokens",
+			options:       []func(llm *client.LLM){client.WithContextWindow(8192), client.WithMaxTokens(512)},
+			contextWindow: 8192,
+			maxTokens:     512,
+			params:        defaults,
+		},
+		{
+			name:          "default params",
+			options:       []func(llm *client.LLM){client.WithDefaultParams(0.2, 0.9, 40)},
+			contextWindow: 4096,
+			maxTokens:     4096,
+			params:        client.D{"temperature": 0.2, "top_p": 0.9, "top_k": 40.0},
+		},
+		{
+			name: "default sampling options",
+			options: []func(llm *client.LLM){
+				client.WithDefaultParams(0.2, 0.9, 40, client.WithSeed(7), client.WithStop("END"), client.WithPenalties(0.5, 0.25), client.WithRepeatPenalty(1.1), client.WithMinP(0.05), client.WithMaxCompletionTokens(10)),
+			},
+			contextWindow: 4096,
+			maxTokens:     4096,
+			params: client.D{
+				"temperature": 0.2, "top_p": 0.9, "top_k": 40.0, "seed": 7.0, "stop": []any{"END"},
+				"presence_penalty": 0.5, "frequency_penalty": 0.25, "repeat_penalty": 1.1, "min_p": 0.05,
+			},
+		},
+		{
+			name:          "api key",
+			options:       []func(llm *client.LLM){client.WithAPIKey("secret")},
+			contextWindow: 4096,
+			maxTokens:     4096,
+			params:        defaults,
+			headers:       map[string]string{"Authorization": "Bearer secret"},
+		},
+		{
+			name:          "headers",
+			options:       []func(llm *client.LLM){client.WithHeaders(map[string]string{"X-Org": "ardan", "X-Project": "training"})},
+			contextWindow: 4096,
+			maxTokens:     4096,
+			params:        defaults,
+			headers:       map[string]string{"X-Org": "ardan", "X-Project": "training"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.New()
+			defer srv.Close()
+
+			var ht headerTransport
+			options := append([]func(llm *client.LLM){client.WithHTTPClient(&http.Client{Transport: &ht})}, tt.options...)
+
+			llm, err := client.NewLLM(srv.ChatURL(), "test-model", options...)
+			if err != nil {
+				t.Fatalf("constructing llm: %s", err)
+			}
+
+			if llm.ContextWindow() != tt.contextWindow {
+				t.Errorf("got context window %d, expected %d", llm.ContextWindow(), tt.contextWindow)
+			}
+
+			if _, err := llm.Chat(context.Background(), conversation("hello")); err != nil {
+				t.Fatalf("chat: %s", err)
+			}
+
+			// The requests are sent with the HTTP client set by the options.
+			if ht.headers == nil {
+				t.Fatalf("expected the request sent with the http client")
+			}
+
+			body := srv.Requests()[0].Body
+
+			if body["max_tokens"] != tt.maxTokens {
+				t.Errorf("got max tokens %v, expected %v", body["max_tokens"], tt.maxTokens)
+			}
+
+			for key, expected := range tt.params {
+				if !reflect.DeepEqual(body[key], expected) {
+					t.Errorf("%s: got %v, expected %v", key, body[key], expected)
+				}
+			}
+
+			for key, expected := range tt.headers {
+				if got := ht.headers.Get(key); got != expected {
+					t.Errorf("header %s: got %q, expected %q", key, got, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestLLMLogger(t *testing.T) {
+	srv := llmtest.New()
+	defer srv.Close()
+
+	srv.Script(
+		llmtest.Response{StatusCode: http.StatusServiceUnavailable, Error: "overloaded"},
+		llmtest.Response{Content: "hello"},
+	)
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, nil))
+
+	llm, err := client.NewLLM(srv.ChatURL(), "test-model", client.WithLogger(log))
+	if err != nil {
+		t.Fatalf("constructing llm: %s", err)
+	}
+
+	if _, err := llm.Chat(context.Background(), conversation("hello")); err != nil {
+		t.Fatalf("chat: %s", err)
+	}
+
+	// The retry of the overloaded request is logged by the client the LLM
+	// constructs.
+	out := buf.String()
+	if !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "retrying") {
+		t.Errorf("got log %q, expected the retry logged at the error level", out)
+	}
+}
+
+func TestNewLLMErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		model   string
+		options []func(llm *client.LLM)
+		err     string
+	}{
+		{name: "missing url", model: "test-model", err: "url is required"},
+		{name: "missing model", url: "http://localhost:11434/v1/chat/completions", err: "model is required"},
+		{name: "negative context window", url: "http://localhost:11434/v1/chat/completions", model: "test-model", options: []func(llm *client.LLM){client.WithContextWindow(-1)}, err: "invalid context window -1"},
+		{name: "negative max tokens", url: "http://localhost:11434/v1/chat/completions", model: "test-model", options: []func(llm *client.LLM){client.WithMaxTokens(-10)}, err: "invalid max tokens -10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm, err := client.NewLLM(tt.url, tt.model, tt.options...)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v %v, expected error %q", llm, err, tt.err)
+			}
+		})
+	}
+
+	if _, err := client.NewProviderLLM(nil, "test-model"); err == nil {
+		t.Errorf("expected an error without a provider")
+	}
+}
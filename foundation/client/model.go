@@ -17,6 +17,13 @@ type Error struct {
 	} `json:"error"`
 }
 
+func newError(msg string) *Error {
+	var err Error
+	err.Err.Message = msg
+
+	return &err
+}
+
 func (err *Error) Error() string {
 	return err.Err.Message
 }
@@ -54,33 +61,76 @@ func (t Time) MarshalJSON() ([]byte, error) {
 // =============================================================================
 
 type Function struct {
-	Name      string
-	Arguments map[string]any
+	Name         string
+	Arguments    map[string]any
+	RawArguments string
 }
 
+// UnmarshalJSON accepts the arguments as a JSON encoded string or as a JSON
+// object. When the arguments are a partial JSON fragment, as they are when
+// streamed, the fragment is kept in RawArguments and Arguments is left nil.
 func (f *Function) UnmarshalJSON(b []byte) error {
 	var tmp struct {
-		Name         string `json:"name"`
-		RawArguments string `json:"arguments"`
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
 	}
 
 	if err := json.Unmarshal(b, &tmp); err != nil {
 		return err
 	}
 
-	arguments := make(map[string]any)
-	if err := json.Unmarshal([]byte(tmp.RawArguments), &arguments); err != nil {
-		return err
+	*f = Function{
+		Name: tmp.Name,
 	}
 
-	*f = Function{
-		Name:      tmp.Name,
-		Arguments: arguments,
+	if len(tmp.Arguments) == 0 || string(tmp.Arguments) == "null" {
+		return nil
+	}
+
+	if tmp.Arguments[0] == '{' {
+		f.RawArguments = string(tmp.Arguments)
+	} else {
+		if err := json.Unmarshal(tmp.Arguments, &f.RawArguments); err != nil {
+			return err
+		}
+	}
+
+	arguments := make(map[string]any)
+	if err := json.Unmarshal([]byte(f.RawArguments), &arguments); err == nil {
+		f.Arguments = arguments
 	}
 
 	return nil
 }
 
+func (f Function) MarshalJSON() ([]byte, error) {
+	var arguments []byte
+
+	switch {
+	case f.Arguments != nil:
+		var err error
+		if arguments, err = json.Marshal(f.Arguments); err != nil {
+			return nil, err
+		}
+
+	case f.RawArguments != "":
+		arguments = []byte(f.RawArguments)
+
+	default:
+		arguments = []byte("{}")
+	}
+
+	tmp := struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	}{
+		Name:      f.Name,
+		Arguments: string(arguments),
+	}
+
+	return json.Marshal(tmp)
+}
+
 type ToolCall struct {
 	ID       string   `json:"id,omitempty"`
 	Index    int      `json:"index"`
@@ -89,15 +139,17 @@ type ToolCall struct {
 }
 
 type ChatDeltaSSE struct {
-	Role      string     `json:"role"`
-	Content   string     `json:"content"`
-	Reasoning string     `json:"reasoning"`
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Role               string     `json:"role"`
+	Content            string     `json:"content"`
+	Reasoning          string     `json:"reasoning"`
+	ReasoningSignature string     `json:"reasoning_signature,omitempty"`
+	ToolCalls          []ToolCall `json:"tool_calls,omitempty"`
 }
 
 type ChatChoiceSSE struct {
 	Index        int          `json:"index"`
 	Delta        ChatDeltaSSE `json:"delta"`
+	Logprobs     *Logprobs    `json:"logprobs,omitempty"`
 	FinishReason string       `json:"finish_reason"`
 }
 
@@ -107,19 +159,24 @@ type ChatSSE struct {
 	Created Time            `json:"created"`
 	Model   string          `json:"model"`
 	Choices []ChatChoiceSSE `json:"choices"`
+	Usage   *Usage          `json:"usage,omitempty"`
 	Error   string          `json:"error"`
 }
 
 // =============================================================================
 
 type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Reasoning string     `json:"reasoning"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 }
 
 type ChatChoice struct {
-	Index   int         `json:"index"`
-	Message ChatMessage `json:"message"`
+	Index        int         `json:"index"`
+	Message      ChatMessage `json:"message"`
+	Logprobs     *Logprobs   `json:"logprobs,omitempty"`
+	FinishReason string      `json:"finish_reason"`
 }
 
 type Chat struct {
@@ -128,6 +185,29 @@ type Chat struct {
 	Created Time         `json:"created"`
 	Model   string       `json:"model"`
 	Choices []ChatChoice `json:"choices"`
+	Usage   *Usage       `json:"usage,omitempty"`
+}
+
+// Logprobs provides the log probabilities of the tokens in a choice when
+// they are requested with WithLogprobs.
+type Logprobs struct {
+	Content []TokenLogprob `json:"content"`
+}
+
+// TokenLogprob represents the log probability of a token along with the
+// most likely alternatives at that position.
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes,omitempty"`
+	TopLogprobs []TopLogprob `json:"top_logprobs,omitempty"`
+}
+
+// TopLogprob represents an alternative token at a position.
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes,omitempty"`
 }
 
 // =============================================================================
@@ -0,0 +1,546 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ollama provides access to the native Ollama API. This gives access to
+// features the OpenAI-compatible API doesn't provide, like model metadata,
+// model management and per request control of the context window.
+type Ollama struct {
+	cln    *Client
+	host   string
+	numCtx int
+}
+
+// NewOllama constructs an Ollama api client for the specified host, for
+// example http://localhost:11434.
+func NewOllama(log Logger, host string, options ...func(cln *Client)) *Ollama {
+	return &Ollama{
+		cln:  New(log, options...),
+		host: strings.TrimSuffix(host, "/"),
+	}
+}
+
+// NewOllamaLLM constructs an LLM that uses the native Ollama API. The context
+// window is read from the model metadata unless the options set it, and it's
+// sent as the num_ctx of every request so Ollama doesn't use its smaller
+// default. WithNumCtx overrides it for a single request.
+func NewOllamaLLM(ctx context.Context, host string, model string, options ...func(llm *LLM)) (*LLM, error) {
+	llm := newLLM(model, options)
+
+	ollama := NewOllama(llm.log, host, llm.clientOptions()...)
+
+	if llm.model != "" && llm.contextWindow == 0 {
+		info, err := ollama.Show(ctx, llm.model)
+		if err != nil {
+			return nil, fmt.Errorf("show: %w", err)
+		}
+
+		llm.contextWindow = info.ContextLength
+	}
+
+	if err := llm.validate(); err != nil {
+		return nil, err
+	}
+
+	ollama.numCtx = llm.contextWindow
+	llm.provider = ollama
+
+	return llm, nil
+}
+
+// =============================================================================
+
+// ModelInfo represents the metadata of a model installed in Ollama.
+type ModelInfo struct {
+	Name              string
+	Family            string
+	ParameterSize     string
+	QuantizationLevel string
+	ContextLength     int
+	EmbeddingLength   int
+	Capabilities      []string
+}
+
+// Show returns the metadata for the specified model.
+func (o *Ollama) Show(ctx context.Context, model string) (ModelInfo, error) {
+	var resp struct {
+		Parameters string `json:"parameters"`
+		Details    struct {
+			Family            string `json:"family"`
+			ParameterSize     string `json:"parameter_size"`
+			QuantizationLevel string `json:"quantization_level"`
+		} `json:"details"`
+		ModelInfo    map[string]any `json:"model_info"`
+		Capabilities []string       `json:"capabilities"`
+	}
+
+	if err := o.cln.Do(ctx, http.MethodPost, o.host+"/api/show", D{"model": model}, &resp); err != nil {
+		return ModelInfo{}, fmt.Errorf("do: %w", err)
+	}
+
+	info := ModelInfo{
+		Name:              model,
+		Family:            resp.Details.Family,
+		ParameterSize:     resp.Details.ParameterSize,
+		QuantizationLevel: resp.Details.QuantizationLevel,
+		Capabilities:      resp.Capabilities,
+	}
+
+	// The model info keys are prefixed with the architecture of the model,
+	// like llama.context_length or bert.embedding_length.
+	for k, v := range resp.ModelInfo {
+		n, ok := v.(float64)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(k, ".context_length"):
+			info.ContextLength = int(n)
+		case strings.HasSuffix(k, ".embedding_length"):
+			info.EmbeddingLength = int(n)
+		}
+	}
+
+	// A num_ctx parameter set in the model file overrides the context length
+	// the model was trained with.
+	for line := range strings.Lines(resp.Parameters) {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				info.ContextLength = n
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// ModelTag represents a model installed in Ollama.
+type ModelTag struct {
+	Name       string    `json:"name"`
+	Model      string    `json:"model"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Size       int64     `json:"size"`
+	Digest     string    `json:"digest"`
+}
+
+// Tags returns the list of models installed in Ollama.
+func (o *Ollama) Tags(ctx context.Context) ([]ModelTag, error) {
+	var resp struct {
+		Models []ModelTag `json:"models"`
+	}
+
+	if err := o.cln.Do(ctx, http.MethodGet, o.host+"/api/tags", nil, &resp); err != nil {
+		return nil, fmt.Errorf("do: %w", err)
+	}
+
+	return resp.Models, nil
+}
+
+// PullProgress represents a progress update received while pulling a model.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+}
+
+// Pull downloads the specified model, calling the progress function for
+// every progress update received from Ollama.
+func (o *Ollama) Pull(ctx context.Context, model string, progress func(PullProgress)) error {
+	resp, err := do(ctx, o.cln, http.MethodPost, o.host+"/api/pull", D{"model": model, "stream": true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return decodeLines(resp.Body, func(line []byte) error {
+		if err := streamError(Event{Data: string(line)}); err != nil {
+			return err
+		}
+
+		var p PullProgress
+		if err := json.Unmarshal(line, &p); err != nil {
+			return fmt.Errorf("decoding: %s: %w", line, err)
+		}
+
+		if progress != nil {
+			progress(p)
+		}
+
+		return nil
+	})
+}
+
+// =============================================================================
+
+// WithNumCtx sets the size of the context window Ollama will use for the
+// request. This is only supported by the native Ollama API.
+func WithNumCtx(numCtx int) withParam {
+	return withParam{
+		typ: "num_ctx",
+		d: D{
+			"num_ctx": numCtx,
+		},
+	}
+}
+
+// WithKeepAlive sets how long Ollama will keep the model loaded in memory
+// after the request. This is only supported by the native Ollama API.
+func WithKeepAlive(keepAlive time.Duration) withParam {
+	return withParam{
+		typ: "keep_alive",
+		d: D{
+			"keep_alive": keepAlive,
+		},
+	}
+}
+
+// =============================================================================
+
+type ollamaToolCall struct {
+	Function struct {
+		Index     int             `json:"index"`
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaChat struct {
+	Model           string         `json:"model"`
+	CreatedAt       time.Time      `json:"created_at"`
+	Message         ollamaMessage  `json:"message"`
+	Done            bool           `json:"done"`
+	DoneReason      string         `json:"done_reason"`
+	PromptEvalCount int            `json:"prompt_eval_count"`
+	EvalCount       int            `json:"eval_count"`
+	Logprobs        []TokenLogprob `json:"logprobs"`
+}
+
+// usage returns the token counts reported on the final response.
+func (c ollamaChat) usage() Usage {
+	return Usage{
+		PromptTokens:     c.PromptEvalCount,
+		CompletionTokens: c.EvalCount,
+		TotalTokens:      c.PromptEvalCount + c.EvalCount,
+	}
+}
+
+func (o *Ollama) Chat(ctx context.Context, req ChatRequest) (Message, error) {
+	d, err := o.chatDocument(req, false)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var chat ollamaChat
+	if err := o.cln.Do(ctx, http.MethodPost, o.host+"/api/chat", d, &chat); err != nil {
+		return Message{}, fmt.Errorf("do: %w", err)
+	}
+
+	toolCalls, err := toToolCalls(chat.Message.ToolCalls, 0)
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{
+		Role:      chat.Message.Role,
+		Content:   chat.Message.Content,
+		Reasoning: chat.Message.Thinking,
+		ToolCalls: toolCalls,
+		Usage:     chat.usage(),
+		Logprobs:  chat.Logprobs,
+	}
+
+	return msg, nil
+}
+
+func (o *Ollama) ChatStream(ctx context.Context, req ChatRequest) (*Stream[ChatSSE], error) {
+	d, err := o.chatDocument(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := do(ctx, o.cln, http.MethodPost, o.host+"/api/chat", d)
+	if err != nil {
+		return nil, fmt.Errorf("stream: %w", err)
+	}
+
+	s := NewStream(ctx, func(send func(ChatSSE) error) error {
+		defer resp.Body.Close()
+
+		var toolCalls int
+
+		return decodeLines(resp.Body, func(line []byte) error {
+			if err := streamError(Event{Data: string(line)}); err != nil {
+				return err
+			}
+
+			var chat ollamaChat
+			if err := json.Unmarshal(line, &chat); err != nil {
+				return fmt.Errorf("decoding: %s: %w", line, err)
+			}
+
+			// Ollama sends every tool call complete, so they are indexed
+			// across the whole response for the tool call accumulator.
+			calls, err := toToolCalls(chat.Message.ToolCalls, toolCalls)
+			if err != nil {
+				return err
+			}
+
+			delta := ChatDeltaSSE{
+				Role:      chat.Message.Role,
+				Content:   chat.Message.Content,
+				Reasoning: chat.Message.Thinking,
+				ToolCalls: calls,
+			}
+
+			toolCalls += len(delta.ToolCalls)
+
+			var finishReason string
+			if chat.Done {
+				finishReason = chat.DoneReason
+				if toolCalls > 0 {
+					finishReason = "tool_calls"
+				}
+			}
+
+			v := ChatSSE{
+				Object:  "chat.completion.chunk",
+				Created: Time{Time: chat.CreatedAt},
+				Model:   chat.Model,
+				Choices: []ChatChoiceSSE{
+					{
+						Delta:        delta,
+						FinishReason: finishReason,
+					},
+				},
+			}
+
+			if chat.Logprobs != nil {
+				v.Choices[0].Logprobs = &Logprobs{Content: chat.Logprobs}
+			}
+
+			if chat.Done {
+				u := chat.usage()
+				v.Usage = &u
+			}
+
+			return send(v)
+		})
+	})
+
+	return s, nil
+}
+
+func (o *Ollama) Embed(ctx context.Context, req EmbedRequest) ([][]float64, error) {
+	if req.Parts != nil {
+		return nil, fmt.Errorf("ollama: multimodal embeddings: %w", ErrNotSupported)
+	}
+
+	d := D{
+		"model":    req.Model,
+		"input":    req.Texts,
+		"truncate": true,
+	}
+
+	if req.KeepAlive != nil {
+		d["keep_alive"] = req.KeepAlive.String()
+	}
+
+	var resp struct {
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+
+	if err := o.cln.Do(ctx, http.MethodPost, o.host+"/api/embed", d, &resp); err != nil {
+		return nil, fmt.Errorf("do: %w", err)
+	}
+
+	if len(resp.Embeddings) == 0 {
+		return nil, fmt.Errorf("no embedding")
+	}
+
+	return resp.Embeddings, nil
+}
+
+func (o *Ollama) chatDocument(req ChatRequest, stream bool) (D, error) {
+	messages := make([]ollamaMessage, len(req.Messages))
+	for i, msg := range req.Messages {
+		m, err := toOllamaMessage(msg)
+		if err != nil {
+			return nil, fmt.Errorf("message[%d]: %w", i, err)
+		}
+		messages[i] = m
+	}
+
+	options := D{}
+	for k, v := range req.Params {
+		options[k] = v
+	}
+
+	numCtx := o.numCtx
+	if req.NumCtx > 0 {
+		numCtx = req.NumCtx
+	}
+
+	if numCtx > 0 {
+		options["num_ctx"] = numCtx
+	}
+
+	// The response can't be longer than the context window, so a limit that
+	// doesn't fit in it is left for the window to enforce.
+	if req.MaxTokens > 0 && (numCtx == 0 || req.MaxTokens < numCtx) {
+		options["num_predict"] = req.MaxTokens
+	}
+
+	d := D{
+		"model":    req.Model,
+		"messages": messages,
+		"stream":   stream,
+		"options":  options,
+	}
+
+	if req.Tools != nil {
+		d["tools"] = req.Tools
+	}
+
+	if req.Schema != nil {
+		d["format"] = req.Schema
+	}
+
+	if req.KeepAlive != nil {
+		d["keep_alive"] = req.KeepAlive.String()
+	}
+
+	if req.ThinkingBudget > 0 {
+		d["think"] = true
+	}
+
+	if req.Logprobs {
+		d["logprobs"] = true
+		if req.TopLogprobs > 0 {
+			d["top_logprobs"] = req.TopLogprobs
+		}
+	}
+
+	return d, nil
+}
+
+// toOllamaMessage converts a message to the native format where images are
+// provided as base64 encoded data and tool call arguments are objects.
+func toOllamaMessage(msg Message) (ollamaMessage, error) {
+	m := ollamaMessage{
+		Role:     msg.Role,
+		Content:  msg.Content,
+		ToolName: msg.ToolName,
+	}
+
+	for _, part := range msg.Parts {
+		switch part.Type {
+		case "text":
+			m.Content = strings.TrimSpace(m.Content + "\n" + part.Text)
+
+		case "image_url":
+			_, data, found := strings.Cut(part.ImageURL.URL, ";base64,")
+			if !found || !strings.HasPrefix(part.ImageURL.URL, "data:") {
+				return ollamaMessage{}, fmt.Errorf("image must be a base64 data url")
+			}
+			m.Images = append(m.Images, data)
+
+		default:
+			return ollamaMessage{}, fmt.Errorf("content part type %q: %w", part.Type, ErrNotSupported)
+		}
+	}
+
+	for _, tc := range msg.ToolCalls {
+		arguments, err := json.Marshal(tc.Function.Arguments)
+		if err != nil {
+			return ollamaMessage{}, fmt.Errorf("tool call %s: %w", tc.Function.Name, err)
+		}
+
+		var call ollamaToolCall
+		call.Function.Index = tc.Index
+		call.Function.Name = tc.Function.Name
+		call.Function.Arguments = arguments
+
+		m.ToolCalls = append(m.ToolCalls, call)
+	}
+
+	return m, nil
+}
+
+// toToolCalls converts the native tool calls. Ollama doesn't provide ids so
+// one is generated from the position of the tool call in the response. An
+// error is returned if the arguments of a tool call aren't a JSON object.
+func toToolCalls(calls []ollamaToolCall, offset int) ([]ToolCall, error) {
+	if len(calls) == 0 {
+		return nil, nil
+	}
+
+	toolCalls := make([]ToolCall, len(calls))
+
+	for i, call := range calls {
+		arguments := make(map[string]any)
+		if len(call.Function.Arguments) > 0 {
+			if err := json.Unmarshal(call.Function.Arguments, &arguments); err != nil {
+				return nil, fmt.Errorf("tool call %q: arguments: %s: %w", call.Function.Name, call.Function.Arguments, err)
+			}
+		}
+
+		toolCalls[i] = ToolCall{
+			ID:    fmt.Sprintf("call_%d", offset+i),
+			Index: offset + i,
+			Type:  "function",
+			Function: Function{
+				Name:         call.Function.Name,
+				Arguments:    arguments,
+				RawArguments: string(call.Function.Arguments),
+			},
+		}
+	}
+
+	return toolCalls, nil
+}
+
+// decodeLines calls the function for every non-empty line of a newline
+// delimited JSON stream.
+func decodeLines(r io.Reader, f func(line []byte) error) error {
+	br := bufio.NewReader(r)
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if line := bytes.TrimSpace(line); len(line) > 0 {
+			if err := f(line); err != nil {
+				return err
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
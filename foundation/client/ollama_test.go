@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestToToolCalls(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string
+		expected  map[string]any
+		fails     bool
+	}{
+		{name: "object", arguments: `{"city":"Miami"}`, expected: map[string]any{"city": "Miami"}},
+		{name: "missing", arguments: ``, expected: map[string]any{}},
+		{name: "malformed", arguments: `{"city":`, fails: true},
+		{name: "not an object", arguments: `"Miami"`, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var call ollamaToolCall
+			call.Function.Name = "get_weather"
+			call.Function.Arguments = json.RawMessage(tt.arguments)
+
+			toolCalls, err := toToolCalls([]ollamaToolCall{call}, 2)
+
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", toolCalls)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("converting: %s", err)
+			}
+
+			f := toolCalls[0].Function
+			if !maps.Equal(f.Arguments, tt.expected) {
+				t.Errorf("got arguments %v, expected %v", f.Arguments, tt.expected)
+			}
+
+			if f.RawArguments != tt.arguments {
+				t.Errorf("got raw arguments %q, expected %q", f.RawArguments, tt.arguments)
+			}
+
+			if toolCalls[0].ID != "call_2" || toolCalls[0].Index != 2 {
+				t.Errorf("got id %q index %d, expected call_2 at 2", toolCalls[0].ID, toolCalls[0].Index)
+			}
+		})
+	}
+}
+
+func TestOllamaShow(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected ModelInfo
+	}{
+		{
+			name:     "llama",
+			response: `{"details":{"family":"llama","parameter_size":"8.0B","quantization_level":"Q4_K_M"},"model_info":{"general.architecture":"llama","llama.context_length":131072,"llama.embedding_length":4096},"capabilities":["completion","tools"]}`,
+			expected: ModelInfo{Name: "model", Family: "llama", ParameterSize: "8.0B", QuantizationLevel: "Q4_K_M", ContextLength: 131072, EmbeddingLength: 4096, Capabilities: []string{"completion", "tools"}},
+		},
+		{
+			name:     "embedding model",
+			response: `{"details":{"family":"bert"},"model_info":{"bert.context_length":512,"bert.embedding_length":768},"capabilities":["embedding"]}`,
+			expected: ModelInfo{Name: "model", Family: "bert", ContextLength: 512, EmbeddingLength: 768, Capabilities: []string{"embedding"}},
+		},
+		{
+			name:     "num_ctx parameter",
+			response: `{"parameters":"stop \"<|eot_id|>\"\nnum_ctx 8192\ntemperature 0.6","model_info":{"qwen3.context_length":40960}}`,
+			expected: ModelInfo{Name: "model", ContextLength: 8192},
+		},
+		{
+			name:     "no model info",
+			response: `{}`,
+			expected: ModelInfo{Name: "model"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/show" {
+					t.Errorf("got path %s, expected /api/show", r.URL.Path)
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			info, err := NewOllama(NoopLogger, srv.URL).Show(context.Background(), "model")
+			if err != nil {
+				t.Fatalf("show: %s", err)
+			}
+
+			if !reflect.DeepEqual(info, tt.expected) {
+				t.Errorf("got %+v, expected %+v", info, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOllamaChatOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    []func(llm *LLM)
+		params     []withParam
+		numCtx     any
+		numPredict any
+		shown      bool
+	}{
+		{name: "context length from show", numCtx: 131072.0, shown: true},
+		{name: "context window option", options: []func(llm *LLM){WithContextWindow(16384)}, numCtx: 16384.0},
+		{name: "num_ctx per request", params: []withParam{WithNumCtx(4096)}, numCtx: 4096.0, shown: true},
+		{name: "max tokens", options: []func(llm *LLM){WithMaxTokens(512)}, numCtx: 131072.0, numPredict: 512.0, shown: true},
+		{name: "max tokens per request", params: []withParam{WithMaxCompletionTokens(100)}, numCtx: 131072.0, numPredict: 100.0, shown: true},
+		{name: "max tokens over num_ctx", options: []func(llm *LLM){WithMaxTokens(8192)}, params: []withParam{WithNumCtx(4096)}, numCtx: 4096.0, shown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var shown bool
+			var options D
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/show":
+					shown = true
+					w.Write([]byte(`{"model_info":{"llama.context_length":131072}}`))
+
+				case "/api/chat":
+					var req struct {
+						Options D `json:"options"`
+					}
+					if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+						t.Errorf("decoding request: %s", err)
+					}
+					options = req.Options
+
+					w.Write([]byte(`{"message":{"role":"assistant","content":"hi"},"done":true}`))
+				}
+			}))
+			defer srv.Close()
+
+			llm, err := NewOllamaLLM(context.Background(), srv.URL, "model", append(tt.options, WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))...)
+			if err != nil {
+				t.Fatalf("constructing llm: %s", err)
+			}
+
+			if _, err := llm.Chat(context.Background(), NewConversation(""), tt.params...); err != nil {
+				t.Fatalf("chat: %s", err)
+			}
+
+			if shown != tt.shown {
+				t.Errorf("got show requested %t, expected %t", shown, tt.shown)
+			}
+
+			if options["num_ctx"] != tt.numCtx {
+				t.Errorf("got num_ctx %v, expected %v", options["num_ctx"], tt.numCtx)
+			}
+
+			if options["num_predict"] != tt.numPredict {
+				t.Errorf("got num_predict %v, expected %v", options["num_predict"], tt.numPredict)
+			}
+		})
+	}
+}
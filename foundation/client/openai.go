@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// OpenAI implements the Provider interface for OpenAI-compatible endpoints
+// like the /v1 API served by Ollama, vLLM and llama.cpp.
+type OpenAI struct {
+	cln    *Client
+	clnSSE *SSEClient[ChatSSE]
+	url    string
+}
+
+// NewOpenAI constructs a provider for the specified endpoint. The url is the
+// full url of the endpoint being called, like /v1/chat/completions for chat
+// or /v1/embeddings for embeddings.
+func NewOpenAI(log Logger, url string, options ...func(cln *Client)) *OpenAI {
+	return &OpenAI{
+		cln:    New(log, options...),
+		clnSSE: NewSSE[ChatSSE](log, options...),
+		url:    url,
+	}
+}
+
+func (p *OpenAI) Chat(ctx context.Context, req ChatRequest) (Message, error) {
+	req.N = 0
+
+	msgs, err := p.ChatChoices(ctx, req)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return msgs[0], nil
+}
+
+// ChatChoices implements the ChoicesProvider interface. The messages are
+// ordered by the index of the choice and the usage for the request is set
+// on the first message. The choices a server doesn't provide are requested
+// separately, with the usage set on each of their messages.
+func (p *OpenAI) ChatChoices(ctx context.Context, req ChatRequest) ([]Message, error) {
+	d := p.chatDocument(req, false)
+
+	var chat Chat
+	if err := p.cln.Do(ctx, http.MethodPost, p.url, d, &chat); err != nil {
+		return nil, fmt.Errorf("do: %w", err)
+	}
+
+	if len(chat.Choices) == 0 {
+		return nil, fmt.Errorf("no response")
+	}
+
+	slices.SortStableFunc(chat.Choices, func(a, b ChatChoice) int {
+		return a.Index - b.Index
+	})
+
+	msgs := make([]Message, len(chat.Choices))
+	for i, choice := range chat.Choices {
+		msgs[i] = Message{
+			Role:      choice.Message.Role,
+			Content:   choice.Message.Content,
+			Reasoning: choice.Message.Reasoning,
+			ToolCalls: choice.Message.ToolCalls,
+		}
+
+		if choice.Logprobs != nil {
+			msgs[i].Logprobs = choice.Logprobs.Content
+		}
+	}
+
+	if chat.Usage != nil {
+		msgs[0].Usage = *chat.Usage
+	}
+
+	// Some OpenAI-compatible servers ignore n and respond with a single
+	// choice, so the missing choices are requested one at a time.
+	for len(msgs) < req.N {
+		msg, err := p.Chat(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("choice[%d]: %w", len(msgs), err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
+
+func (p *OpenAI) ChatStream(ctx context.Context, req ChatRequest) (*Stream[ChatSSE], error) {
+	d := p.chatDocument(req, true)
+
+	stream, err := p.clnSSE.Stream(ctx, http.MethodPost, p.url, d)
+	if err != nil {
+		return nil, fmt.Errorf("stream: %w", err)
+	}
+
+	return stream, nil
+}
+
+func (p *OpenAI) Embed(ctx context.Context, req EmbedRequest) ([][]float64, error) {
+	d := D{
+		"model": req.Model,
+	}
+
+	switch {
+	case req.Parts != nil:
+		d["input"] = req.Parts
+
+	default:
+		d["truncate"] = true
+		d["truncate_direction"] = "right"
+		d["input"] = req.Texts
+	}
+
+	var resp Embedding
+	if err := p.cln.Do(ctx, http.MethodPost, p.url, d, &resp); err != nil {
+		return nil, fmt.Errorf("do: %w", err)
+	}
+
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("no embedding")
+	}
+
+	// The embeddings are placed by index, so every index needs to be
+	// provided exactly once.
+	vectors := make([][]float64, len(resp.Data))
+	filled := make([]bool, len(resp.Data))
+
+	for _, data := range resp.Data {
+		switch {
+		case data.Index < 0 || data.Index >= len(vectors):
+			return nil, fmt.Errorf("invalid embedding index %d", data.Index)
+		case filled[data.Index]:
+			return nil, fmt.Errorf("duplicate embedding index %d", data.Index)
+		}
+
+		vectors[data.Index] = data.Embedding
+		filled[data.Index] = true
+	}
+
+	return vectors, nil
+}
+
+func (p *OpenAI) chatDocument(req ChatRequest, stream bool) D {
+	d := D{
+		"model":      req.Model,
+		"messages":   req.Messages,
+		"max_tokens": req.MaxTokens,
+	}
+
+	if stream {
+		d["stream"] = true
+		d["stream_options"] = D{
+			"include_usage": true,
+		}
+	}
+
+	maps.Copy(d, req.Params)
+
+	if req.N > 1 {
+		d["n"] = req.N
+	}
+
+	if req.Logprobs {
+		d["logprobs"] = true
+		if req.TopLogprobs > 0 {
+			d["top_logprobs"] = req.TopLogprobs
+		}
+	}
+
+	if req.Tools != nil {
+		d["tools"] = req.Tools
+		d["tool_choice"] = "auto"
+	}
+
+	if req.Schema != nil {
+		jsonSchema := D{
+			"name":   req.SchemaName,
+			"schema": req.Schema,
+		}
+
+		if req.SchemaStrict {
+			jsonSchema["strict"] = true
+		}
+
+		d["response_format"] = D{
+			"type":        "json_schema",
+			"json_schema": jsonSchema,
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// embeddingServer responds to every embedding request with the data, which
+// is a JSON array of embedding objects.
+func embeddingServer(t *testing.T, data string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"object":"list","model":"model","data":%s}`, data)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestOpenAIEmbedIndexes(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected [][]float64
+		err      string
+	}{
+		{name: "in order", data: `[{"index":0,"embedding":[1]},{"index":1,"embedding":[2]}]`, expected: [][]float64{{1}, {2}}},
+		{name: "out of order", data: `[{"index":1,"embedding":[2]},{"index":0,"embedding":[1]}]`, expected: [][]float64{{1}, {2}}},
+		{name: "duplicate", data: `[{"index":0,"embedding":[1]},{"index":0,"embedding":[2]}]`, err: "duplicate embedding index 0"},
+		{name: "missing", data: `[{"index":0,"embedding":[1]},{"index":2,"embedding":[3]}]`, err: "invalid embedding index 2"},
+		{name: "negative", data: `[{"index":-1,"embedding":[1]}]`, err: "invalid embedding index -1"},
+		{name: "none", data: `[]`, err: "no embedding"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := embeddingServer(t, tt.data)
+
+			vectors, err := NewOpenAI(NoopLogger, srv.URL).Embed(context.Background(), EmbedRequest{Model: "model", Texts: []string{"a", "b"}})
+
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v %v, expected error %q", vectors, err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("embed: %s", err)
+			}
+
+			if !reflect.DeepEqual(vectors, tt.expected) {
+				t.Errorf("got %v, expected %v", vectors, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEmbedTextsEmptyVector(t *testing.T) {
+	srv := embeddingServer(t, `[{"index":0,"embedding":[1]},{"index":1,"embedding":[]}]`)
+
+	llm, err := NewLLM(srv.URL, "model")
+	if err != nil {
+		t.Fatalf("constructing llm: %s", err)
+	}
+
+	vectors, err := llm.EmbedTexts(context.Background(), []string{"a", "b"})
+	if !errors.Is(err, ErrNoEmbedding) {
+		t.Errorf("got %v %v, expected %v", vectors, err, ErrNoEmbedding)
+	}
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// Set of errors returned by providers.
+var (
+	ErrNotSupported = errors.New("operation not supported by the provider")
+	ErrNoEmbedding  = errors.New("provider returned no embedding")
+)
+
+// ChoicesProvider is implemented by providers that can generate multiple
+// completions for a single request. The LLM makes a request per completion
+// for the providers that don't implement it.
+type ChoicesProvider interface {
+	ChatChoices(ctx context.Context, req ChatRequest) ([]Message, error)
+}
+
+// chatChoices generates the completions requested by req.N using the
+// provider, making a request per completion when the provider doesn't
+// implement ChoicesProvider.
+func chatChoices(ctx context.Context, provider Provider, req ChatRequest) ([]Message, error) {
+	if p, ok := provider.(ChoicesProvider); ok {
+		return p.ChatChoices(ctx, req)
+	}
+
+	n := req.N
+	req.N = 0
+
+	msgs := make([]Message, n)
+	for i := range msgs {
+		msg, err := provider.Chat(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("choice[%d]: %w", i, err)
+		}
+		msgs[i] = msg
+	}
+
+	return msgs, nil
+}
+
+// Provider represents an API that can serve the requests made by an LLM.
+// Providers convert the provider independent requests to the shapes their
+// API expects and convert the responses back, including streamed responses
+// which are provided as OpenAI-compatible chunks.
+type Provider interface {
+	Chat(ctx context.Context, req ChatRequest) (Message, error)
+	ChatStream(ctx context.Context, req ChatRequest) (*Stream[ChatSSE], error)
+	Embed(ctx context.Context, req EmbedRequest) ([][]float64, error)
+}
+
+// ChatRequest represents the provider independent chat request constructed
+// by an LLM from a conversation and the options provided for the call.
+type ChatRequest struct {
+	Model          string
+	Messages       []Message
+	Params         D
+	MaxTokens      int
+	Tools          []D
+	SchemaName     string
+	Schema         any
+	SchemaStrict   bool
+	NumCtx         int
+	KeepAlive      *time.Duration
+	ThinkingBudget int
+	N              int
+	Logprobs       bool
+	TopLogprobs    int
+}
+
+// EmbedRequest represents the provider independent embedding request. The
+// inputs are either text or multimodal content parts.
+type EmbedRequest struct {
+	Model     string
+	Texts     []string
+	Parts     []ContentPart
+	KeepAlive *time.Duration
+}
+
+// =============================================================================
+
+// newChatRequest applies the options to construct a chat request. The
+// params are used unless the options provide their own.
+func newChatRequest(model string, maxTokens int, params D, messages []Message, options []withParam) ChatRequest {
+	req := ChatRequest{
+		Model:     model,
+		Messages:  messages,
+		Params:    maps.Clone(params),
+		MaxTokens: maxTokens,
+	}
+
+	for _, opt := range options {
+		switch opt.typ {
+		case "params":
+			maps.Copy(req.Params, opt.d)
+		case "max_tokens":
+			req.MaxTokens = opt.d["max_tokens"].(int)
+		case "logprobs":
+			req.Logprobs = true
+			req.TopLogprobs = opt.d["top_logprobs"].(int)
+		case "n":
+			req.N = opt.d["n"].(int)
+		case "tools":
+			req.Tools = opt.d["tools"].([]D)
+		case "format":
+			req.SchemaName = opt.d["name"].(string)
+			req.Schema = opt.d["schema"]
+		case "strict":
+			req.SchemaStrict = true
+		case "num_ctx":
+			req.NumCtx = opt.d["num_ctx"].(int)
+		case "keep_alive":
+			req.KeepAlive = keepAlive(options)
+		case "thinking":
+			req.ThinkingBudget = opt.d["budget_tokens"].(int)
+		}
+	}
+
+	return req
+}
+
+// keepAlive returns the keep alive duration from the options if provided.
+func keepAlive(options []withParam) *time.Duration {
+	for _, opt := range options {
+		if opt.typ == "keep_alive" {
+			keepAlive := opt.d["keep_alive"].(time.Duration)
+			return &keepAlive
+		}
+	}
+
+	return nil
+}
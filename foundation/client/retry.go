@@ -0,0 +1,127 @@
+package client
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy defines how a failed request is retried. Requests rejected with
+// a 429 or 503 were not processed by the server, so they are retried for
+// every method. Transport errors and other 5xx responses are only retried
+// for idempotent methods unless RetryNonIdempotent is set, since the server
+// may have already acted on the request.
+type RetryPolicy struct {
+	MaxAttempts        int
+	InitialBackoff     time.Duration
+	MaxBackoff         time.Duration
+	Multiplier         float64
+	Jitter             float64
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy provides a policy that works well with a local model
+// server that is temporarily saturated.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// NoRetryPolicy makes a single attempt for every request.
+var NoRetryPolicy = RetryPolicy{
+	MaxAttempts: 1,
+}
+
+// WithRetry sets the retry policy the client will use for every request.
+func WithRetry(policy RetryPolicy) func(cln *Client) {
+	return func(cln *Client) {
+		cln.retry = policy
+	}
+}
+
+// shouldRetry decides if the specified attempt can be retried based on the
+// error and the method of the request.
+func (p RetryPolicy) shouldRetry(attempt int, method string, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return p.RetryNonIdempotent || idempotent(method)
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return p.RetryNonIdempotent || idempotent(method)
+	}
+
+	return false
+}
+
+// backoff calculates how long to wait before the next attempt. A Retry-After
+// value provided by the server takes precedence over the calculated value,
+// but is still capped by MaxBackoff.
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if p.MaxBackoff > 0 {
+			return min(apiErr.RetryAfter, p.MaxBackoff)
+		}
+		return apiErr.RetryAfter
+	}
+
+	multiplier := max(p.Multiplier, 1)
+
+	delay := float64(p.InitialBackoff)
+	for range attempt - 1 {
+		delay *= multiplier
+	}
+
+	if p.MaxBackoff > 0 {
+		delay = min(delay, float64(p.MaxBackoff))
+	}
+
+	if p.Jitter > 0 {
+		jitter := min(p.Jitter, 1)
+		delay = delay * (1 - jitter + 2*jitter*rand.Float64())
+	}
+
+	return time.Duration(delay)
+}
+
+// idempotent reports if the method can be safely sent more than once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// parseRetryAfter parses the Retry-After header which can be provided in
+// seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+
+	return 0
+}
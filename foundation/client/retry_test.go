@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	nonIdempotent := RetryPolicy{MaxAttempts: 3, RetryNonIdempotent: true}
+
+	transportErr := errors.New("connection reset")
+	apiErr := func(statusCode int) error { return &APIError{StatusCode: statusCode} }
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		method   string
+		err      error
+		expected bool
+	}{
+		{name: "rate limited post", policy: policy, attempt: 1, method: http.MethodPost, err: apiErr(http.StatusTooManyRequests), expected: true},
+		{name: "overloaded post", policy: policy, attempt: 2, method: http.MethodPost, err: apiErr(http.StatusServiceUnavailable), expected: true},
+		{name: "last attempt", policy: policy, attempt: 3, method: http.MethodPost, err: apiErr(http.StatusTooManyRequests), expected: false},
+		{name: "server error get", policy: policy, attempt: 1, method: http.MethodGet, err: apiErr(http.StatusInternalServerError), expected: true},
+		{name: "server error post", policy: policy, attempt: 1, method: http.MethodPost, err: apiErr(http.StatusBadGateway), expected: false},
+		{name: "server error post allowed", policy: nonIdempotent, attempt: 1, method: http.MethodPost, err: apiErr(http.StatusGatewayTimeout), expected: true},
+		{name: "transport error get", policy: policy, attempt: 1, method: http.MethodGet, err: transportErr, expected: true},
+		{name: "transport error post", policy: policy, attempt: 1, method: http.MethodPost, err: transportErr, expected: false},
+		{name: "transport error post allowed", policy: nonIdempotent, attempt: 1, method: http.MethodPost, err: transportErr, expected: true},
+		{name: "bad request", policy: nonIdempotent, attempt: 1, method: http.MethodGet, err: apiErr(http.StatusBadRequest), expected: false},
+		{name: "no retry policy", policy: NoRetryPolicy, attempt: 1, method: http.MethodGet, err: apiErr(http.StatusServiceUnavailable), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.shouldRetry(tt.attempt, tt.method, tt.err); got != tt.expected {
+				t.Errorf("got %t, expected %t", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	uncapped := policy
+	uncapped.MaxBackoff = 0
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		err      error
+		expected time.Duration
+	}{
+		{name: "first attempt", policy: policy, attempt: 1, expected: 100 * time.Millisecond},
+		{name: "third attempt", policy: policy, attempt: 3, expected: 400 * time.Millisecond},
+		{name: "capped", policy: policy, attempt: 6, expected: time.Second},
+		{name: "retry after", policy: policy, attempt: 1, err: &APIError{RetryAfter: 700 * time.Millisecond}, expected: 700 * time.Millisecond},
+		{name: "retry after capped", policy: policy, attempt: 1, err: &APIError{RetryAfter: time.Hour}, expected: time.Second},
+		{name: "retry after uncapped", policy: uncapped, attempt: 1, err: &APIError{RetryAfter: time.Hour}, expected: time.Hour},
+		{name: "no multiplier", policy: RetryPolicy{InitialBackoff: time.Second}, attempt: 4, expected: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err
+			if err == nil {
+				err = &APIError{StatusCode: http.StatusServiceUnavailable}
+			}
+
+			if got := tt.policy.backoff(tt.attempt, err); got != tt.expected {
+				t.Errorf("got %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+
+	// Jitter spreads the delay around the calculated value.
+
+	policy.Jitter = 0.2
+	for range 100 {
+		got := policy.backoff(1, errors.New("failed"))
+		if got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("got %s with jitter, expected between 80ms and 120ms", got)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		delta    time.Duration
+	}{
+		{name: "missing", value: "", expected: 0},
+		{name: "seconds", value: "3", expected: 3 * time.Second},
+		{name: "negative seconds", value: "-5", expected: 0},
+		{name: "date", value: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), expected: time.Minute, delta: 2 * time.Second},
+		{name: "past date", value: "Wed, 21 Oct 2015 07:28:00 GMT", expected: 0},
+		{name: "invalid", value: "soon", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseRetryAfter(tt.value)
+			if got < tt.expected-tt.delta || got > tt.expected+tt.delta {
+				t.Errorf("got %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	fast := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Multiplier:     2,
+	}
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		method   string
+		statuses []int
+		requests int32
+		fails    bool
+	}{
+		{name: "recovers", policy: fast, method: http.MethodPost, statuses: []int{503, 429, 200}, requests: 3},
+		{name: "gives up", policy: fast, method: http.MethodPost, statuses: []int{503, 503, 503, 200}, requests: 3, fails: true},
+		{name: "not retried", policy: fast, method: http.MethodPost, statuses: []int{500, 200}, requests: 1, fails: true},
+		{name: "idempotent retried", policy: fast, method: http.MethodGet, statuses: []int{500, 200}, requests: 2},
+		{name: "no retry policy", policy: NoRetryPolicy, method: http.MethodGet, statuses: []int{503, 200}, requests: 1, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+
+			// The Retry-After of an hour is capped by the policy.
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := requests.Add(1)
+
+				status := tt.statuses[n-1]
+				if status != http.StatusOK {
+					w.Header().Set("Retry-After", "3600")
+				}
+
+				w.WriteHeader(status)
+				w.Write([]byte(`{}`))
+			}))
+			defer srv.Close()
+
+			cln := New(NoopLogger, WithRetry(tt.policy))
+
+			var v D
+			err := cln.Do(context.Background(), tt.method, srv.URL, nil, &v)
+
+			if tt.fails != (err != nil) {
+				t.Errorf("got error %v, expected failure %t", err, tt.fails)
+			}
+
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("got %d requests, expected %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestClientRetryCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cln := New(NoopLogger, WithRetry(DefaultRetryPolicy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The context expires while waiting to retry, which must be reported
+	// instead of the error of the last attempt.
+
+	var v D
+	err := cln.Do(ctx, http.MethodPost, srv.URL, nil, &v)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,430 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrNoBackend is returned by a router when no backend can serve a request.
+var ErrNoBackend = errors.New("no backend available for the request")
+
+// Capability represents a kind of request a backend can serve.
+type Capability string
+
+// Set of capabilities used to route requests.
+const (
+	CapabilityChat       Capability = "chat"
+	CapabilityVision     Capability = "vision"
+	CapabilityAudio      Capability = "audio"
+	CapabilityTools      Capability = "tools"
+	CapabilityEmbeddings Capability = "embeddings"
+)
+
+// RoutingPolicy determines the order the backends are tried in.
+type RoutingPolicy int
+
+// Set of routing policies. With every policy, the next backend is tried
+// when a backend fails.
+const (
+	// PolicyPriority tries the backends in the order they were provided.
+	PolicyPriority RoutingPolicy = iota
+
+	// PolicyRoundRobin rotates the first backend tried on every request.
+	PolicyRoundRobin
+
+	// PolicyLeastInflight tries the backend with the fewest requests in
+	// flight first.
+	PolicyLeastInflight
+)
+
+// Backend represents a provider the router can send requests to. When Model
+// is set it replaces the model of the requests, since backends can serve the
+// same model under different names. A backend without capabilities can serve
+// any request. Health is optional and is used by the health checks.
+type Backend struct {
+	Name         string
+	Provider     Provider
+	Model        string
+	Capabilities []Capability
+	Health       func(ctx context.Context) error
+}
+
+// BackendStatus represents the state of a backend as seen by the router.
+type BackendStatus struct {
+	Name     string
+	Healthy  bool
+	Open     bool
+	Failures int
+	Inflight int
+}
+
+// =============================================================================
+
+// Router implements the Provider interface by routing requests across
+// multiple backends. A circuit breaker stops sending requests to a backend
+// after consecutive failures until a cooldown has passed, and health checks
+// can take backends out of rotation. Construct an LLM with NewProviderLLM to
+// use the router.
+type Router struct {
+	policy    RoutingPolicy
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	backends []*backendState
+	next     int
+}
+
+type backendState struct {
+	Backend
+	healthy   bool
+	failures  int
+	openUntil time.Time
+	inflight  int
+}
+
+// NewRouter constructs a router for the backends using the policy.
+func NewRouter(policy RoutingPolicy, backends []Backend, options ...func(r *Router)) *Router {
+	r := Router{
+		policy:    policy,
+		threshold: 3,
+		cooldown:  30 * time.Second,
+		backends:  make([]*backendState, len(backends)),
+	}
+
+	for i, b := range backends {
+		r.backends[i] = &backendState{
+			Backend: b,
+			healthy: true,
+		}
+	}
+
+	for _, option := range options {
+		option(&r)
+	}
+
+	return &r
+}
+
+// WithBreaker sets the number of consecutive failures that open the circuit
+// breaker of a backend and how long it stays open.
+func WithBreaker(threshold int, cooldown time.Duration) func(r *Router) {
+	return func(r *Router) {
+		r.threshold = max(threshold, 1)
+		r.cooldown = cooldown
+	}
+}
+
+// Status returns the state of the backends.
+func (r *Router) Status() []BackendStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	status := make([]BackendStatus, len(r.backends))
+	for i, b := range r.backends {
+		status[i] = BackendStatus{
+			Name:     b.Name,
+			Healthy:  b.healthy,
+			Open:     now.Before(b.openUntil),
+			Failures: b.failures,
+			Inflight: b.inflight,
+		}
+	}
+
+	return status
+}
+
+// CheckHealth runs the health check of every backend that has one. A
+// backend that fails its health check is skipped until it passes again,
+// unless no healthy backend can serve a request.
+func (r *Router) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, b := range r.backends {
+		if b.Health == nil {
+			continue
+		}
+
+		wg.Go(func() {
+			err := b.Health(ctx)
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			b.healthy = err == nil
+			if b.healthy {
+				b.failures = 0
+				b.openUntil = time.Time{}
+			}
+		})
+	}
+
+	wg.Wait()
+}
+
+// StartHealthChecks runs the health checks on the interval until the
+// context is canceled.
+func (r *Router) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			r.CheckHealth(ctx)
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Chat implements the Provider interface.
+func (r *Router) Chat(ctx context.Context, req ChatRequest) (Message, error) {
+	return route(ctx, r, chatCapabilities(req), func(b *backendState) (Message, error) {
+		return b.Provider.Chat(ctx, b.request(req))
+	})
+}
+
+// ChatChoices implements the ChoicesProvider interface. All the choices are
+// generated by the same backend.
+func (r *Router) ChatChoices(ctx context.Context, req ChatRequest) ([]Message, error) {
+	return route(ctx, r, chatCapabilities(req), func(b *backendState) ([]Message, error) {
+		return chatChoices(ctx, b.Provider, b.request(req))
+	})
+}
+
+// ChatStream implements the Provider interface. A backend is only replaced
+// when the stream fails to start. Failures once the stream has started are
+// reported by the stream and counted against the backend.
+func (r *Router) ChatStream(ctx context.Context, req ChatRequest) (*Stream[ChatSSE], error) {
+	var used *backendState
+
+	streamCtx, cancel := context.WithCancel(ctx)
+
+	stream, err := route(ctx, r, chatCapabilities(req), func(b *backendState) (*Stream[ChatSSE], error) {
+		used = b
+		return b.Provider.ChatStream(streamCtx, b.request(req))
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The request stays in flight until the stream ends.
+	r.acquire(used)
+
+	s := NewStream(streamCtx, func(send func(ChatSSE) error) error {
+		var err error
+		defer func() {
+			// A stream closed by the caller says nothing about the backend.
+			releaseCtx := ctx
+			if streamCtx.Err() != nil {
+				releaseCtx = streamCtx
+			}
+
+			cancel()
+			r.release(releaseCtx, used, err)
+		}()
+
+		for v := range stream.Events() {
+			if err = send(v); err != nil {
+				return err
+			}
+		}
+
+		err = stream.Err()
+		return err
+	})
+
+	s.cancelWith(cancel)
+
+	return s, nil
+}
+
+// Embed implements the Provider interface.
+func (r *Router) Embed(ctx context.Context, req EmbedRequest) ([][]float64, error) {
+	return route(ctx, r, []Capability{CapabilityEmbeddings}, func(b *backendState) ([][]float64, error) {
+		if b.Model != "" {
+			req.Model = b.Model
+		}
+		return b.Provider.Embed(ctx, req)
+	})
+}
+
+// =============================================================================
+
+// route calls the backends that can serve the request in the order set by
+// the policy until one succeeds.
+func route[T any](ctx context.Context, r *Router, required []Capability, call func(b *backendState) (T, error)) (T, error) {
+	var zero T
+
+	candidates := r.candidates(required)
+	if len(candidates) == 0 {
+		return zero, fmt.Errorf("router: %v: %w", required, ErrNoBackend)
+	}
+
+	var errs []error
+
+	for _, b := range candidates {
+		r.acquire(b)
+		v, err := call(b)
+		r.release(ctx, b, err)
+
+		if err == nil {
+			return v, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return zero, fmt.Errorf("router: all backends failed: %w", errors.Join(errs...))
+}
+
+// candidates returns the backends that can serve a request with the
+// required capabilities, ordered by the policy. Backends that are unhealthy
+// or have an open circuit breaker are skipped, unless that leaves nothing.
+func (r *Router) candidates(required []Capability) []*backendState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	var capable, available []*backendState
+	for _, b := range r.backends {
+		if !b.supports(required) {
+			continue
+		}
+
+		capable = append(capable, b)
+
+		if b.healthy && !now.Before(b.openUntil) {
+			available = append(available, b)
+		}
+	}
+
+	if len(available) == 0 {
+		available = capable
+	}
+
+	switch r.policy {
+	case PolicyRoundRobin:
+		if len(available) > 0 {
+			start := r.next % len(available)
+			r.next++
+			available = append(available[start:len(available):len(available)], available[:start]...)
+		}
+
+	case PolicyLeastInflight:
+		slices.SortStableFunc(available, func(a, b *backendState) int {
+			return a.inflight - b.inflight
+		})
+	}
+
+	return available
+}
+
+func (r *Router) acquire(b *backendState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b.inflight++
+}
+
+// release records the outcome of a request made to the backend.
+func (r *Router) release(ctx context.Context, b *backendState, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b.inflight--
+
+	switch {
+	case err == nil:
+		b.failures = 0
+
+	case ctx.Err() != nil:
+		// The caller gave up, which says nothing about the backend.
+
+	case backendFailure(err):
+		b.failures++
+		if b.failures >= r.threshold {
+			b.openUntil = time.Now().Add(r.cooldown)
+		}
+	}
+}
+
+// request applies the backend's model to the request.
+func (b *backendState) request(req ChatRequest) ChatRequest {
+	if b.Model != "" {
+		req.Model = b.Model
+	}
+
+	return req
+}
+
+func (b *backendState) supports(required []Capability) bool {
+	if len(b.Capabilities) == 0 {
+		return true
+	}
+
+	for _, c := range required {
+		if !slices.Contains(b.Capabilities, c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// chatCapabilities returns the capabilities needed to serve the request.
+func chatCapabilities(req ChatRequest) []Capability {
+	required := []Capability{CapabilityChat}
+
+	if req.Tools != nil {
+		required = append(required, CapabilityTools)
+	}
+
+	for _, c := range []struct {
+		partType   string
+		capability Capability
+	}{
+		{"image_url", CapabilityVision},
+		{"input_audio", CapabilityAudio},
+	} {
+		for _, msg := range req.Messages {
+			if slices.ContainsFunc(msg.Parts, func(p ContentPart) bool { return p.Type == c.partType }) {
+				required = append(required, c.capability)
+				break
+			}
+		}
+	}
+
+	return required
+}
+
+// backendFailure reports if the error says something about the health of
+// the backend. Errors caused by the request itself don't count.
+func backendFailure(err error) bool {
+	if errors.Is(err, ErrNotSupported) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == 429
+	}
+
+	return true
+}
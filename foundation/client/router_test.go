@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider fails the chat requests with the scripted errors in order
+// and succeeds once the script is exhausted.
+type fakeProvider struct {
+	name string
+
+	mu     sync.Mutex
+	script []error
+	calls  int
+}
+
+func (p *fakeProvider) Chat(ctx context.Context, req ChatRequest) (Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls++
+
+	if len(p.script) > 0 {
+		err := p.script[0]
+		p.script = p.script[1:]
+
+		if err != nil {
+			return Message{}, err
+		}
+	}
+
+	return Message{Role: RoleAssistant, Content: p.name}, nil
+}
+
+func (p *fakeProvider) ChatStream(ctx context.Context, req ChatRequest) (*Stream[ChatSSE], error) {
+	msg, err := p.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStream(ctx, func(send func(ChatSSE) error) error {
+		for {
+			chunk := ChatSSE{Choices: []ChatChoiceSSE{{Delta: ChatDeltaSSE{Content: msg.Content}}}}
+			if err := send(chunk); err != nil {
+				return err
+			}
+		}
+	}), nil
+}
+
+func (p *fakeProvider) Embed(ctx context.Context, req EmbedRequest) ([][]float64, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *fakeProvider) numCalls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.calls
+}
+
+func TestRouterBreaker(t *testing.T) {
+	serverErr := &APIError{StatusCode: http.StatusInternalServerError}
+	rateLimited := &APIError{StatusCode: http.StatusTooManyRequests}
+	badRequest := &APIError{StatusCode: http.StatusBadRequest}
+
+	tests := []struct {
+		name     string
+		script   []error
+		requests int
+		calls    int
+		failures int
+		open     bool
+		served   string
+	}{
+		{name: "below threshold", script: []error{serverErr}, requests: 1, calls: 1, failures: 1, served: "secondary"},
+		{name: "opens at threshold", script: []error{serverErr, rateLimited}, requests: 2, calls: 2, failures: 2, open: true, served: "secondary"},
+		{name: "open skips backend", script: []error{serverErr, serverErr, nil}, requests: 4, calls: 2, failures: 2, open: true, served: "secondary"},
+		{name: "success resets", script: []error{serverErr, nil}, requests: 2, calls: 2, failures: 0, served: "primary"},
+		{name: "request errors don't count", script: []error{badRequest, badRequest, ErrNotSupported}, requests: 3, calls: 3, failures: 0, served: "secondary"},
+		{name: "transport errors count", script: []error{errors.New("connection refused"), errors.New("connection refused")}, requests: 2, calls: 2, failures: 2, open: true, served: "secondary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &fakeProvider{name: "primary", script: tt.script}
+			secondary := &fakeProvider{name: "secondary"}
+
+			r := NewRouter(PolicyPriority, []Backend{
+				{Name: "primary", Provider: primary},
+				{Name: "secondary", Provider: secondary},
+			}, WithBreaker(2, time.Hour))
+
+			var msg Message
+			for i := range tt.requests {
+				var err error
+				if msg, err = r.Chat(context.Background(), ChatRequest{}); err != nil {
+					t.Fatalf("request %d: %s", i, err)
+				}
+			}
+
+			status := r.Status()[0]
+
+			if primary.numCalls() != tt.calls {
+				t.Errorf("got %d calls to the primary, expected %d", primary.numCalls(), tt.calls)
+			}
+
+			if status.Failures != tt.failures || status.Open != tt.open {
+				t.Errorf("got %d failures and open %t, expected %d and %t", status.Failures, status.Open, tt.failures, tt.open)
+			}
+
+			if msg.Content != tt.served {
+				t.Errorf("got the last request served by %s, expected %s", msg.Content, tt.served)
+			}
+		})
+	}
+}
+
+func TestRouterBreakerCooldown(t *testing.T) {
+	primary := &fakeProvider{name: "primary", script: []error{errors.New("down"), nil}}
+	secondary := &fakeProvider{name: "secondary"}
+
+	r := NewRouter(PolicyPriority, []Backend{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary},
+	}, WithBreaker(1, 20*time.Millisecond))
+
+	chat := func() string {
+		msg, err := r.Chat(context.Background(), ChatRequest{})
+		if err != nil {
+			t.Fatalf("chat: %s", err)
+		}
+		return msg.Content
+	}
+
+	steps := []struct {
+		wait     time.Duration
+		served   string
+		open     bool
+		failures int
+	}{
+		{served: "secondary", open: true, failures: 1},
+		{served: "secondary", open: true, failures: 1},
+		{wait: 30 * time.Millisecond, served: "primary", open: false, failures: 0},
+	}
+
+	for i, s := range steps {
+		time.Sleep(s.wait)
+
+		if got := chat(); got != s.served {
+			t.Errorf("step %d: got served by %s, expected %s", i, got, s.served)
+		}
+
+		status := r.Status()[0]
+		if status.Open != s.open || status.Failures != s.failures {
+			t.Errorf("step %d: got open %t with %d failures, expected %t with %d", i, status.Open, status.Failures, s.open, s.failures)
+		}
+	}
+}
+
+func TestRouterAllOpen(t *testing.T) {
+	down := errors.New("down")
+
+	primary := &fakeProvider{name: "primary", script: []error{down, nil}}
+	secondary := &fakeProvider{name: "secondary", script: []error{down}}
+
+	r := NewRouter(PolicyPriority, []Backend{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary},
+	}, WithBreaker(1, time.Hour))
+
+	// Both breakers open on the first request, which fails. The open
+	// backends are still tried rather than failing without a request.
+
+	if _, err := r.Chat(context.Background(), ChatRequest{}); err == nil {
+		t.Fatalf("expected the first request to fail")
+	}
+
+	msg, err := r.Chat(context.Background(), ChatRequest{})
+	if err != nil {
+		t.Fatalf("chat with every breaker open: %s", err)
+	}
+
+	if msg.Content != "primary" {
+		t.Errorf("got served by %s, expected primary", msg.Content)
+	}
+}
+
+func TestRouterCanceled(t *testing.T) {
+	primary := &fakeProvider{name: "primary"}
+
+	r := NewRouter(PolicyPriority, []Backend{{Name: "primary", Provider: primary}}, WithBreaker(1, time.Hour))
+
+	// Closing a stream early cancels its request, which isn't a failure of
+	// the backend.
+
+	stream, err := r.ChatStream(context.Background(), ChatRequest{})
+	if err != nil {
+		t.Fatalf("chat stream: %s", err)
+	}
+
+	<-stream.Events()
+	stream.Close()
+
+	for range stream.Events() {
+	}
+
+	status := r.Status()[0]
+	if status.Open || status.Failures != 0 || status.Inflight != 0 {
+		t.Errorf("got %+v, expected a closed breaker with nothing in flight", status)
+	}
+}
+
+func TestRouterPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RoutingPolicy
+		req      ChatRequest
+		expected []string
+	}{
+		{name: "priority", policy: PolicyPriority, expected: []string{"a", "a", "a"}},
+		{name: "round robin", policy: PolicyRoundRobin, expected: []string{"a", "b", "c", "a"}},
+		{name: "capabilities", policy: PolicyRoundRobin, req: ChatRequest{Tools: []D{{}}}, expected: []string{"b", "c", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter(tt.policy, []Backend{
+				{Name: "a", Provider: &fakeProvider{name: "a"}, Capabilities: []Capability{CapabilityChat}},
+				{Name: "b", Provider: &fakeProvider{name: "b"}, Capabilities: []Capability{CapabilityChat, CapabilityTools}},
+				{Name: "c", Provider: &fakeProvider{name: "c"}},
+			})
+
+			var served []string
+			for range tt.expected {
+				msg, err := r.Chat(context.Background(), tt.req)
+				if err != nil {
+					t.Fatalf("chat: %s", err)
+				}
+				served = append(served, msg.Content)
+			}
+
+			if fmt.Sprint(served) != fmt.Sprint(tt.expected) {
+				t.Errorf("got served by %v, expected %v", served, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event represents a single event decoded from a text/event-stream as
+// defined by the HTML Living Standard server-sent events specification.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// EventDecoder reads events from a text/event-stream. There is no limit on
+// the length of a line.
+type EventDecoder struct {
+	r       *bufio.Reader
+	started bool
+	skipLF  bool
+	lastID  string
+}
+
+// NewEventDecoder constructs a decoder that reads from the specified reader.
+func NewEventDecoder(r io.Reader) *EventDecoder {
+	return &EventDecoder{
+		r: bufio.NewReaderSize(r, 64*1024),
+	}
+}
+
+// Decode returns the next event from the stream. When the stream ends, any
+// partially received event is discarded and io.EOF is returned.
+func (dec *EventDecoder) Decode() (Event, error) {
+	var evt Event
+	var data strings.Builder
+	var hasData bool
+
+	for {
+		line, err := dec.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+
+		// A blank line dispatches the event if we have data.
+		if line == "" {
+			if !hasData {
+				evt = Event{}
+				continue
+			}
+
+			evt.ID = dec.lastID
+			evt.Data = strings.TrimSuffix(data.String(), "\n")
+
+			return evt, nil
+		}
+
+		// Lines starting with a colon are comments, servers use them to keep
+		// the connection alive.
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+
+		switch field {
+		case "event":
+			evt.Event = value
+
+		case "data":
+			hasData = true
+			data.WriteString(value)
+			data.WriteByte('\n')
+
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				dec.lastID = value
+			}
+
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				evt.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// LastEventID returns the value of the last id field seen on the stream.
+func (dec *EventDecoder) LastEventID() string {
+	return dec.lastID
+}
+
+// readLine returns the next line without the line terminator. Lines can be
+// terminated by CRLF, LF or CR. A final line without a terminator can't
+// complete an event, so it's reported as the end of the stream.
+func (dec *EventDecoder) readLine() (string, error) {
+	var line []byte
+
+	for {
+		b, err := dec.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		if dec.skipLF {
+			dec.skipLF = false
+			if b == '\n' {
+				continue
+			}
+		}
+
+		switch b {
+		case '\r':
+			dec.skipLF = true
+			return dec.text(line), nil
+
+		case '\n':
+			return dec.text(line), nil
+		}
+
+		line = append(line, b)
+	}
+}
+
+// text converts the line to a string, removing the byte order mark that
+// is allowed at the start of the stream.
+func (dec *EventDecoder) text(line []byte) string {
+	if !dec.started {
+		dec.started = true
+		line = bytes.TrimPrefix(line, []byte("\xEF\xBB\xBF"))
+	}
+
+	return string(line)
+}
@@ -0,0 +1,107 @@
+package client_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+)
+
+func TestEventDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   string
+		expected []client.Event
+	}{
+		{
+			name:     "LF",
+			stream:   "data: one\n\ndata: two\n\n",
+			expected: []client.Event{{Data: "one"}, {Data: "two"}},
+		},
+		{
+			name:     "CRLF",
+			stream:   "data: one\r\n\r\ndata: two\r\n\r\n",
+			expected: []client.Event{{Data: "one"}, {Data: "two"}},
+		},
+		{
+			name:     "CR",
+			stream:   "data: one\r\rdata: two\r\r",
+			expected: []client.Event{{Data: "one"}, {Data: "two"}},
+		},
+		{
+			name:     "mixed line endings",
+			stream:   "data: one\r\n\ndata: two\r\r\n",
+			expected: []client.Event{{Data: "one"}, {Data: "two"}},
+		},
+		{
+			name:     "comments",
+			stream:   ": keep-alive\n\n:ping\ndata: one\n: in the middle\n\n",
+			expected: []client.Event{{Data: "one"}},
+		},
+		{
+			name:     "multi-line data",
+			stream:   "data: {\"a\":\ndata:1}\ndata\n\n",
+			expected: []client.Event{{Data: "{\"a\":\n1}\n"}},
+		},
+		{
+			name:     "byte order mark",
+			stream:   "\xEF\xBB\xBFdata: one\n\n",
+			expected: []client.Event{{Data: "one"}},
+		},
+		{
+			name:     "byte order mark only at the start",
+			stream:   "data: one\n\n\xEF\xBB\xBFdata: two\n\n",
+			expected: []client.Event{{Data: "one"}},
+		},
+		{
+			name:     "fields",
+			stream:   "event: delta\nid: 7\nretry: 1500\ndata: one\n\ndata: two\n\n",
+			expected: []client.Event{{ID: "7", Event: "delta", Retry: 1500 * time.Millisecond, Data: "one"}, {ID: "7", Data: "two"}},
+		},
+		{
+			name:     "event without data",
+			stream:   "event: ping\n\ndata: one\n\n",
+			expected: []client.Event{{Data: "one"}},
+		},
+		{
+			name:     "unterminated event",
+			stream:   "data: one\n\ndata: two\n",
+			expected: []client.Event{{Data: "one"}},
+		},
+		{
+			name:     "unknown fields",
+			stream:   "foo: bar\ndata: one\n\n",
+			expected: []client.Event{{Data: "one"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reading a byte at a time splits CRLF across reads.
+			for _, r := range []io.Reader{strings.NewReader(tt.stream), iotest.OneByteReader(strings.NewReader(tt.stream))} {
+				dec := client.NewEventDecoder(r)
+
+				var events []client.Event
+				for {
+					evt, err := dec.Decode()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						t.Fatalf("decoding: %s", err)
+					}
+					events = append(events, evt)
+				}
+
+				if !reflect.DeepEqual(events, tt.expected) {
+					t.Errorf("got %+v, expected %+v", events, tt.expected)
+				}
+			}
+		})
+	}
+}
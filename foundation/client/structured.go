@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// WithJSONSchema constrains the response of the model to a JSON document
+// matching the specified schema.
+func WithJSONSchema(name string, schema any) withParam {
+	return withParam{
+		typ: "format",
+		d: D{
+			"name":   name,
+			"schema": schema,
+		},
+	}
+}
+
+// WithStrictSchema asks endpoints that support it, like hosted OpenAI models,
+// to enforce the JSON schema strictly. Strict mode requires every property
+// to be listed as required and additionalProperties to be false, so the
+// schema must be written for it. Schemas derived from types with omitempty
+// fields don't qualify.
+func WithStrictSchema() withParam {
+	return withParam{
+		typ: "strict",
+		d:   D{},
+	}
+}
+
+// WithAttempts sets the number of times ChatStructured will ask the model
+// for a valid response before giving up.
+func WithAttempts(attempts int) withParam {
+	return withParam{
+		typ: "attempts",
+		d: D{
+			"attempts": attempts,
+		},
+	}
+}
+
+// ChatStructured sends the conversation to the model asking for a response
+// that is a JSON document matching the schema derived from T. When the
+// response is not valid, the validation error is sent back to the model so it
+// can correct the response, up to 3 attempts by default. The messages of the
+// conversation provided are not modified, but the tokens used by every
+// attempt are recorded against it.
+func ChatStructured[T any](ctx context.Context, llm *LLM, conv *Conversation, options ...withParam) (T, error) {
+	var zero T
+
+	schema, err := jsonschema.For[T](nil)
+	if err != nil {
+		return zero, fmt.Errorf("schema: %w", err)
+	}
+
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return zero, fmt.Errorf("resolve schema: %w", err)
+	}
+
+	attempts := 3
+	for _, opt := range options {
+		if opt.typ == "attempts" {
+			attempts = max(opt.d["attempts"].(int), 1)
+		}
+	}
+
+	options = append(options, WithJSONSchema(schemaName[T](), schema))
+
+	c := Conversation{
+		Messages: append([]Message{}, conv.Messages...),
+	}
+	defer func() { conv.addUsage(c.Usage()) }()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := llm.Chat(ctx, &c, options...)
+		if err != nil {
+			return zero, fmt.Errorf("chat: %w", err)
+		}
+
+		v, err := decodeStructured[T](resolved, resp.Content)
+		if err == nil {
+			return v, nil
+		}
+
+		if attempt == attempts {
+			return zero, fmt.Errorf("invalid response after %d attempts: %w: %s", attempts, err, resp.Content)
+		}
+
+		c.Add(
+			AssistantMessage(resp.Content),
+			UserMessage(fmt.Sprintf("The response is not valid: %s. Respond again with only a JSON document that matches the schema.", err)),
+		)
+	}
+}
+
+// decodeStructured validates the content against the schema and decodes it
+// into a value of type T.
+func decodeStructured[T any](resolved *jsonschema.Resolved, content string) (T, error) {
+	var zero T
+
+	doc := extractJSON(content)
+
+	var instance any
+	if err := json.Unmarshal([]byte(doc), &instance); err != nil {
+		return zero, fmt.Errorf("decoding: %w", err)
+	}
+
+	if err := resolved.Validate(instance); err != nil {
+		return zero, fmt.Errorf("validating: %w", err)
+	}
+
+	var v T
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		return zero, fmt.Errorf("decoding: %w", err)
+	}
+
+	return v, nil
+}
+
+var fence = regexp.MustCompile("(?s)```(?:json)?\\s*(.*?)\\s*```")
+
+// extractJSON removes markdown code fences and any text surrounding the JSON
+// document that models tend to add even when asked not to.
+func extractJSON(content string) string {
+	content = strings.TrimSpace(content)
+
+	if m := fence.FindStringSubmatch(content); m != nil {
+		content = m[1]
+	}
+
+	start := strings.IndexAny(content, "{[")
+	if start == -1 {
+		return content
+	}
+
+	end := strings.LastIndexAny(content, "}]")
+	if end < start {
+		return content
+	}
+
+	return content[start : end+1]
+}
+
+var nonName = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// schemaName provides the name of the schema based on the name of T.
+func schemaName[T any]() string {
+	name := reflect.TypeFor[T]().Name()
+	name = nonName.ReplaceAllString(name, "_")
+
+	if name == "" {
+		return "response"
+	}
+
+	return name
+}
@@ -0,0 +1,151 @@
+package client_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+	"github.com/ardanlabs/ai-training/foundation/llmtest"
+)
+
+type person struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestChatStructured(t *testing.T) {
+	const valid = `{"name":"Bill","age":40}`
+
+	tests := []struct {
+		name      string
+		responses []string
+		attempts  int
+		requests  int
+		fails     bool
+	}{
+		{name: "valid", responses: []string{valid}, requests: 1},
+		{name: "code fence", responses: []string{"```json\n" + valid + "\n```"}, requests: 1},
+		{name: "surrounding text", responses: []string{"Here it is: " + valid + " Anything else?"}, requests: 1},
+		{name: "invalid json", responses: []string{"Bill is 40", valid}, requests: 2},
+		{name: "schema violation", responses: []string{`{"name":"Bill","age":"forty"}`, valid}, requests: 2},
+		{name: "gives up", responses: []string{"no", "no", "no", valid}, requests: 3, fails: true},
+		{name: "attempts", responses: []string{"no", "no", valid}, attempts: 2, requests: 2, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.New()
+			defer srv.Close()
+
+			for _, content := range tt.responses {
+				srv.Script(llmtest.Response{Content: content})
+			}
+
+			llm, err := client.NewLLM(srv.ChatURL(), "test-model")
+			if err != nil {
+				t.Fatalf("constructing llm: %s", err)
+			}
+
+			conv := client.NewConversation("")
+			conv.Add(client.UserMessage("describe Bill"))
+
+			attempts := 3
+			if tt.attempts > 0 {
+				attempts = tt.attempts
+			}
+
+			p, err := client.ChatStructured[person](context.Background(), llm, conv, client.WithAttempts(attempts))
+
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", p)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("chat structured: %s", err)
+				}
+
+				if p != (person{Name: "Bill", Age: 40}) {
+					t.Errorf("got %+v, expected Bill aged 40", p)
+				}
+			}
+
+			reqs := srv.Requests()
+			if len(reqs) != tt.requests {
+				t.Fatalf("got %d requests, expected %d", len(reqs), tt.requests)
+			}
+
+			// Every retry sends the invalid response back with the reason it
+			// was rejected.
+			for i, req := range reqs[1:] {
+				msgs := req.Messages
+				n := len(msgs)
+
+				if n != 2*(i+1)+1 || msgs[n-2].Content != tt.responses[i] || !strings.Contains(msgs[n-1].Content, "The response is not valid") {
+					t.Errorf("request %d: got messages %+v, expected the rejected response and the reason", i+1, msgs)
+				}
+			}
+
+			if len(conv.Messages) != 1 {
+				t.Errorf("got %d messages in the conversation, expected it to be unchanged", len(conv.Messages))
+			}
+		})
+	}
+}
+
+func TestChatStructuredSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		strict bool
+	}{
+		{name: "default"},
+		{name: "strict", strict: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.New()
+			defer srv.Close()
+
+			srv.Script(llmtest.Response{Content: `{"name":"Bill","age":40}`})
+
+			llm, err := client.NewLLM(srv.ChatURL(), "test-model")
+			if err != nil {
+				t.Fatalf("constructing llm: %s", err)
+			}
+
+			conv := client.NewConversation("")
+			conv.Add(client.UserMessage("describe Bill"))
+
+			switch {
+			case tt.strict:
+				_, err = client.ChatStructured[person](context.Background(), llm, conv, client.WithStrictSchema())
+			default:
+				_, err = client.ChatStructured[person](context.Background(), llm, conv)
+			}
+
+			if err != nil {
+				t.Fatalf("chat structured: %s", err)
+			}
+
+			body := srv.Requests()[0].Body
+
+			// The native Ollama format field is rejected by hosted OpenAI.
+			if _, exists := body["format"]; exists {
+				t.Errorf("got a format field in the request")
+			}
+
+			format, _ := body["response_format"].(map[string]any)
+			schema, _ := format["json_schema"].(map[string]any)
+
+			if format["type"] != "json_schema" || schema["name"] != "person" || schema["schema"] == nil {
+				t.Fatalf("got response format %v, expected the person schema", format)
+			}
+
+			if strict := schema["strict"] == true; strict != tt.strict {
+				t.Errorf("got strict %t, expected %t", strict, tt.strict)
+			}
+		})
+	}
+}
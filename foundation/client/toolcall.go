@@ -0,0 +1,125 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ToolCallAccumulator merges the tool call deltas received over a stream
+// into complete tool calls. OpenAI-compatible servers stream the id and name
+// of a tool call in the first delta and then the arguments as JSON fragments
+// spread over many deltas, all sharing the same index.
+type ToolCallAccumulator struct {
+	calls []*ToolCall
+	args  []*strings.Builder
+	index map[int]int
+}
+
+// NewToolCallAccumulator constructs an accumulator for a single response.
+func NewToolCallAccumulator() *ToolCallAccumulator {
+	return &ToolCallAccumulator{
+		index: make(map[int]int),
+	}
+}
+
+// Add merges the tool call deltas found in the chunk. When the chunk carries
+// a finish reason, the accumulated tool calls are returned complete and the
+// accumulator is reset for reuse.
+func (acc *ToolCallAccumulator) Add(chunk ChatSSE) ([]ToolCall, error) {
+	if len(chunk.Choices) == 0 {
+		return nil, nil
+	}
+
+	choice := chunk.Choices[0]
+
+	for _, delta := range choice.Delta.ToolCalls {
+		acc.merge(delta)
+	}
+
+	if choice.FinishReason == "" {
+		return nil, nil
+	}
+
+	return acc.Flush()
+}
+
+// Pending reports if there are tool calls that have not been returned yet.
+func (acc *ToolCallAccumulator) Pending() bool {
+	return len(acc.calls) > 0
+}
+
+// Flush returns the accumulated tool calls with their arguments decoded and
+// resets the accumulator. This is useful when a stream ends without
+// providing a finish reason.
+func (acc *ToolCallAccumulator) Flush() ([]ToolCall, error) {
+	if len(acc.calls) == 0 {
+		return nil, nil
+	}
+
+	defer acc.reset()
+
+	toolCalls := make([]ToolCall, len(acc.calls))
+
+	for i, call := range acc.calls {
+		toolCall := *call
+		toolCall.Function.RawArguments = acc.args[i].String()
+		toolCall.Function.Arguments = make(map[string]any)
+
+		if raw := strings.TrimSpace(toolCall.Function.RawArguments); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &toolCall.Function.Arguments); err != nil {
+				return nil, fmt.Errorf("tool call %q: arguments: %s: %w", toolCall.Function.Name, raw, err)
+			}
+		}
+
+		if toolCall.Type == "" {
+			toolCall.Type = "function"
+		}
+
+		toolCalls[i] = toolCall
+	}
+
+	return toolCalls, nil
+}
+
+func (acc *ToolCallAccumulator) merge(delta ToolCall) {
+	pos, exists := acc.index[delta.Index]
+
+	// Some servers send every tool call in a response with the same index.
+	// A new id for an index we have already seen starts a new tool call.
+	if exists && delta.ID != "" && acc.calls[pos].ID != "" && delta.ID != acc.calls[pos].ID {
+		exists = false
+	}
+
+	if !exists {
+		acc.calls = append(acc.calls, &ToolCall{
+			Index: delta.Index,
+		})
+		acc.args = append(acc.args, &strings.Builder{})
+
+		pos = len(acc.calls) - 1
+		acc.index[delta.Index] = pos
+	}
+
+	call := acc.calls[pos]
+
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+
+	if delta.Type != "" {
+		call.Type = delta.Type
+	}
+
+	if call.Function.Name == "" {
+		call.Function.Name = delta.Function.Name
+	}
+
+	acc.args[pos].WriteString(delta.Function.RawArguments)
+}
+
+func (acc *ToolCallAccumulator) reset() {
+	acc.calls = nil
+	acc.args = nil
+	acc.index = make(map[int]int)
+}
@@ -0,0 +1,167 @@
+package client_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+)
+
+func TestToolCallAccumulator(t *testing.T) {
+	type call struct {
+		id        string
+		name      string
+		arguments map[string]any
+	}
+
+	tests := []struct {
+		name     string
+		chunks   []string
+		expected []call
+		fails    bool
+	}{
+		{
+			name: "fragmented arguments",
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"ci"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":\"Mia"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"mi\"}"}}]}}]}`,
+				`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			},
+			expected: []call{{id: "call_1", name: "get_weather", arguments: map[string]any{"city": "Miami"}}},
+		},
+		{
+			name: "interleaved calls",
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"get_time","arguments":"{\"zone\":"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"EST\"}"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Miami\"}"}}]}}]}`,
+				`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			},
+			expected: []call{
+				{id: "call_1", name: "get_weather", arguments: map[string]any{"city": "Miami"}},
+				{id: "call_2", name: "get_time", arguments: map[string]any{"zone": "EST"}},
+			},
+		},
+		{
+			name: "reused index",
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":"{\"city\":\"Miami\"}"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_2","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`,
+				`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			},
+			expected: []call{
+				{id: "call_1", name: "get_weather", arguments: map[string]any{"city": "Miami"}},
+				{id: "call_2", name: "get_weather", arguments: map[string]any{"city": "Paris"}},
+			},
+		},
+		{
+			name: "arguments as an object",
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":{"city":"Miami"}}}]},"finish_reason":"tool_calls"}]}`,
+			},
+			expected: []call{{id: "call_1", name: "get_weather", arguments: map[string]any{"city": "Miami"}}},
+		},
+		{
+			name: "no arguments",
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_time"}}]}}]}`,
+				`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			},
+			expected: []call{{id: "call_1", name: "get_time", arguments: map[string]any{}}},
+		},
+		{
+			name: "malformed arguments",
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+				`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			},
+			fails: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := client.NewToolCallAccumulator()
+
+			var toolCalls []client.ToolCall
+			var err error
+
+			for i, data := range tt.chunks {
+				var chunk client.ChatSSE
+				if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+					t.Fatalf("chunk %d: decoding: %s", i, err)
+				}
+
+				if toolCalls, err = acc.Add(chunk); err != nil || toolCalls != nil {
+					if i != len(tt.chunks)-1 {
+						t.Fatalf("chunk %d: got tool calls before the finish reason", i)
+					}
+				}
+			}
+
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", toolCalls)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("accumulating: %s", err)
+			}
+
+			if len(toolCalls) != len(tt.expected) {
+				t.Fatalf("got %d tool calls, expected %d", len(toolCalls), len(tt.expected))
+			}
+
+			for i, exp := range tt.expected {
+				tc := toolCalls[i]
+				if tc.ID != exp.id || tc.Type != "function" || tc.Function.Name != exp.name || !reflect.DeepEqual(tc.Function.Arguments, exp.arguments) {
+					t.Errorf("tool call %d: got %+v, expected %+v", i, tc, exp)
+				}
+			}
+
+			if acc.Pending() {
+				t.Errorf("expected the accumulator to be reset")
+			}
+		})
+	}
+}
+
+func TestToolCallAccumulatorFlush(t *testing.T) {
+	acc := client.NewToolCallAccumulator()
+
+	// A stream can end without a finish reason, leaving the tool calls to
+	// be flushed.
+
+	var chunk client.ChatSSE
+	data := `{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_time","arguments":"{}"}}]}}]}`
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	if toolCalls, err := acc.Add(chunk); err != nil || toolCalls != nil {
+		t.Fatalf("got %v %v, expected nothing before the finish reason", toolCalls, err)
+	}
+
+	if !acc.Pending() {
+		t.Fatalf("expected a pending tool call")
+	}
+
+	toolCalls, err := acc.Flush()
+	if err != nil {
+		t.Fatalf("flushing: %s", err)
+	}
+
+	if len(toolCalls) != 1 || toolCalls[0].Function.Name != "get_time" {
+		t.Errorf("got %+v, expected the get_time tool call", toolCalls)
+	}
+
+	if toolCalls, err := acc.Flush(); err != nil || toolCalls != nil {
+		t.Errorf("flushing twice: got %v %v, expected nothing", toolCalls, err)
+	}
+}
@@ -0,0 +1,356 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TranscriptionFormat represents the format the transcription is requested
+// in. Every format provides the text, and the verbose JSON, SRT and VTT
+// formats also provide the segments with their timestamps.
+type TranscriptionFormat string
+
+// Set of transcription formats.
+const (
+	TranscriptionJSON        TranscriptionFormat = "json"
+	TranscriptionVerboseJSON TranscriptionFormat = "verbose_json"
+	TranscriptionText        TranscriptionFormat = "text"
+	TranscriptionSRT         TranscriptionFormat = "srt"
+	TranscriptionVTT         TranscriptionFormat = "vtt"
+)
+
+// TranscriptionSegment represents a part of the audio and its text.
+type TranscriptionSegment struct {
+	ID    int
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Transcription represents the text transcribed from audio. The language
+// and duration are only provided by the verbose JSON format. Raw holds the
+// document as it was returned for the text, SRT and VTT formats.
+type Transcription struct {
+	Text     string
+	Language string
+	Duration time.Duration
+	Segments []TranscriptionSegment
+	Raw      string
+}
+
+// TranscribeRequest represents the provider independent transcription
+// request constructed by an LLM.
+type TranscribeRequest struct {
+	Model    string
+	Audio    []byte
+	FileName string
+	Format   TranscriptionFormat
+	Language string
+	Prompt   string
+}
+
+// Transcriber is implemented by providers that can transcribe audio.
+type Transcriber interface {
+	Transcribe(ctx context.Context, req TranscribeRequest) (Transcription, error)
+}
+
+// =============================================================================
+
+// WithTranscriptionFormat sets the format the transcription is requested
+// in. The default is verbose JSON.
+func WithTranscriptionFormat(format TranscriptionFormat) withParam {
+	return withParam{
+		typ: "transcription_format",
+		d: D{
+			"format": format,
+		},
+	}
+}
+
+// WithLanguage sets the language of the audio as an ISO-639-1 code, like
+// en, instead of having the model detect it.
+func WithLanguage(language string) withParam {
+	return withParam{
+		typ: "language",
+		d: D{
+			"language": language,
+		},
+	}
+}
+
+// WithTranscriptionPrompt provides text that guides the style of the
+// transcription or the spelling of uncommon words.
+func WithTranscriptionPrompt(prompt string) withParam {
+	return withParam{
+		typ: "transcription_prompt",
+		d: D{
+			"prompt": prompt,
+		},
+	}
+}
+
+// WithAudioFileName sets the file name sent with the audio. Servers can use
+// the extension to detect the format of the audio. The default is audio.wav.
+func WithAudioFileName(fileName string) withParam {
+	return withParam{
+		typ: "audio_file_name",
+		d: D{
+			"file_name": fileName,
+		},
+	}
+}
+
+// Transcribe sends the audio to the model and returns the transcribed text.
+// Construct the LLM with the url of the transcriptions endpoint, like
+// /v1/audio/transcriptions.
+func (llm *LLM) Transcribe(ctx context.Context, audio io.Reader, options ...withParam) (Transcription, error) {
+	transcriber, ok := llm.provider.(Transcriber)
+	if !ok {
+		return Transcription{}, fmt.Errorf("transcribe: %w", ErrNotSupported)
+	}
+
+	data, err := io.ReadAll(audio)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("transcribe: read audio: %w", err)
+	}
+
+	req := TranscribeRequest{
+		Model:    llm.model,
+		Audio:    data,
+		FileName: "audio.wav",
+		Format:   TranscriptionVerboseJSON,
+	}
+
+	for _, opt := range options {
+		switch opt.typ {
+		case "transcription_format":
+			req.Format = opt.d["format"].(TranscriptionFormat)
+		case "language":
+			req.Language = opt.d["language"].(string)
+		case "transcription_prompt":
+			req.Prompt = opt.d["prompt"].(string)
+		case "audio_file_name":
+			req.FileName = opt.d["file_name"].(string)
+		}
+	}
+
+	t := llm.startTrace(ctx, "transcribe")
+
+	if err := llm.acquire(ctx, t, priority(options, PriorityBatch)); err != nil {
+		t.finish(ctx, Usage{}, err)
+		return Transcription{}, err
+	}
+
+	transcription, err := transcriber.Transcribe(ctx, req)
+	llm.release()
+
+	t.finish(ctx, Usage{}, err)
+
+	return transcription, err
+}
+
+// =============================================================================
+
+// Transcribe implements the Transcriber interface for the OpenAI-compatible
+// /v1/audio/transcriptions endpoint, also served by whisper.cpp's server.
+func (p *OpenAI) Transcribe(ctx context.Context, req TranscribeRequest) (Transcription, error) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+
+	fields := [][2]string{
+		{"model", req.Model},
+		{"response_format", string(req.Format)},
+		{"language", req.Language},
+		{"prompt", req.Prompt},
+	}
+
+	if req.Format == TranscriptionVerboseJSON {
+		fields = append(fields, [2]string{"timestamp_granularities[]", "segment"})
+	}
+
+	for _, field := range fields {
+		if field[1] == "" {
+			continue
+		}
+
+		if err := w.WriteField(field[0], field[1]); err != nil {
+			return Transcription{}, fmt.Errorf("write field %s: %w", field[0], err)
+		}
+	}
+
+	part, err := w.CreateFormFile("file", req.FileName)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("create form file: %w", err)
+	}
+
+	if _, err := part.Write(req.Audio); err != nil {
+		return Transcription{}, fmt.Errorf("write audio: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return Transcription{}, fmt.Errorf("close writer: %w", err)
+	}
+
+	switch req.Format {
+	case TranscriptionJSON, TranscriptionVerboseJSON:
+		var resp struct {
+			Text     string  `json:"text"`
+			Language string  `json:"language"`
+			Duration float64 `json:"duration"`
+			Segments []struct {
+				ID    int     `json:"id"`
+				Start float64 `json:"start"`
+				End   float64 `json:"end"`
+				Text  string  `json:"text"`
+			} `json:"segments"`
+		}
+
+		if err := p.cln.DoBody(ctx, http.MethodPost, p.url, w.FormDataContentType(), b.Bytes(), &resp); err != nil {
+			return Transcription{}, fmt.Errorf("do: %w", err)
+		}
+
+		transcription := Transcription{
+			Text:     strings.TrimSpace(resp.Text),
+			Language: resp.Language,
+			Duration: seconds(resp.Duration),
+		}
+
+		for _, seg := range resp.Segments {
+			transcription.Segments = append(transcription.Segments, TranscriptionSegment{
+				ID:    seg.ID,
+				Start: seconds(seg.Start),
+				End:   seconds(seg.End),
+				Text:  strings.TrimSpace(seg.Text),
+			})
+		}
+
+		return transcription, nil
+
+	default:
+		var resp string
+		if err := p.cln.DoBody(ctx, http.MethodPost, p.url, w.FormDataContentType(), b.Bytes(), &resp); err != nil {
+			return Transcription{}, fmt.Errorf("do: %w", err)
+		}
+
+		transcription := Transcription{
+			Text: strings.TrimSpace(resp),
+			Raw:  resp,
+		}
+
+		if req.Format == TranscriptionSRT || req.Format == TranscriptionVTT {
+			segments, err := parseCues(resp)
+			if err != nil {
+				return Transcription{}, fmt.Errorf("parse %s: %w", req.Format, err)
+			}
+
+			texts := make([]string, len(segments))
+			for i, seg := range segments {
+				texts[i] = seg.Text
+			}
+
+			transcription.Text = strings.Join(texts, " ")
+			transcription.Segments = segments
+		}
+
+		return transcription, nil
+	}
+}
+
+// =============================================================================
+
+// parseCues parses the cues of an SRT or VTT document into segments.
+func parseCues(doc string) ([]TranscriptionSegment, error) {
+	doc = strings.ReplaceAll(doc, "\r\n", "\n")
+
+	var segments []TranscriptionSegment
+
+	for block := range strings.SplitSeq(doc, "\n\n") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+
+		// Blocks without timings are headers, notes or styles.
+		if timing == -1 {
+			continue
+		}
+
+		from, to, _ := strings.Cut(lines[timing], "-->")
+
+		// VTT cue settings can follow the end timestamp.
+		to = strings.TrimSpace(to)
+		if fields := strings.Fields(to); len(fields) > 0 {
+			to = fields[0]
+		}
+
+		start, err := parseTimestamp(from)
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := parseTimestamp(to)
+		if err != nil {
+			return nil, err
+		}
+
+		id := len(segments)
+		if timing > 0 {
+			if n, err := strconv.Atoi(strings.TrimSpace(lines[timing-1])); err == nil {
+				id = n
+			}
+		}
+
+		segments = append(segments, TranscriptionSegment{
+			ID:    id,
+			Start: start,
+			End:   end,
+			Text:  strings.TrimSpace(strings.Join(lines[timing+1:], " ")),
+		})
+	}
+
+	return segments, nil
+}
+
+// parseTimestamp parses timestamps like 00:01:02,500 used by SRT and
+// 01:02.500 or 00:01:02.500 used by VTT.
+func parseTimestamp(ts string) (time.Duration, error) {
+	ts = strings.ReplaceAll(strings.TrimSpace(ts), ",", ".")
+
+	parts := strings.Split(ts, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+
+	secs, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q: %w", ts, err)
+	}
+
+	var total float64
+	for _, part := range parts[:len(parts)-1] {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q: %w", ts, err)
+		}
+		total = total*60 + float64(n)
+	}
+
+	return seconds(total*60 + secs), nil
+}
+
+// seconds converts a number of seconds to a duration.
+func seconds(secs float64) time.Duration {
+	return time.Duration(secs * float64(time.Second))
+}
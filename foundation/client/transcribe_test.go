@@ -0,0 +1,211 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		ts       string
+		expected time.Duration
+		fails    bool
+	}{
+		{ts: "00:00:01,500", expected: 1500 * time.Millisecond},
+		{ts: "00:00:01.500", expected: 1500 * time.Millisecond},
+		{ts: "01:02:03,004", expected: time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond},
+		{ts: "02:03.250", expected: 2*time.Minute + 3250*time.Millisecond},
+		{ts: " 00:00:10.000 ", expected: 10 * time.Second},
+		{ts: "10.000", fails: true},
+		{ts: "1:2:3:4.000", fails: true},
+		{ts: "aa:00:01.000", fails: true},
+		{ts: "00:00:xx", fails: true},
+		{ts: "", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ts, func(t *testing.T) {
+			got, err := parseTimestamp(tt.ts)
+
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parsing: %s", err)
+			}
+
+			if got != tt.expected {
+				t.Errorf("got %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseCues(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		expected []TranscriptionSegment
+		fails    bool
+	}{
+		{
+			name: "srt",
+			doc:  "1\n00:00:00,000 --> 00:00:01,500\nHello there.\n\n2\n00:00:01,500 --> 00:00:03,000\nHow are\nyou today?\n",
+			expected: []TranscriptionSegment{
+				{ID: 1, Start: 0, End: 1500 * time.Millisecond, Text: "Hello there."},
+				{ID: 2, Start: 1500 * time.Millisecond, End: 3 * time.Second, Text: "How are you today?"},
+			},
+		},
+		{
+			name: "srt with CRLF",
+			doc:  "1\r\n00:00:00,000 --> 00:00:01,500\r\nHello there.\r\n\r\n",
+			expected: []TranscriptionSegment{
+				{ID: 1, Start: 0, End: 1500 * time.Millisecond, Text: "Hello there."},
+			},
+		},
+		{
+			name: "vtt",
+			doc:  "WEBVTT\n\nNOTE transcribed by whisper\n\n00:01.000 --> 00:02.500 align:start position:10%\nHello there.\n\nintro\n00:02.500 --> 00:04.000\nHow are you?\n",
+			expected: []TranscriptionSegment{
+				{ID: 0, Start: time.Second, End: 2500 * time.Millisecond, Text: "Hello there."},
+				{ID: 1, Start: 2500 * time.Millisecond, End: 4 * time.Second, Text: "How are you?"},
+			},
+		},
+		{
+			name: "vtt with hours",
+			doc:  "WEBVTT\n\n1\n01:00:00.000 --> 01:00:01.000\nLate.\n",
+			expected: []TranscriptionSegment{
+				{ID: 1, Start: time.Hour, End: time.Hour + time.Second, Text: "Late."},
+			},
+		},
+		{
+			name:     "empty",
+			doc:      "WEBVTT\n\n",
+			expected: nil,
+		},
+		{
+			name:  "malformed start",
+			doc:   "1\n00:00:aa,000 --> 00:00:01,500\nHello.\n",
+			fails: true,
+		},
+		{
+			name:  "malformed end",
+			doc:   "1\n00:00:00,000 --> soon\nHello.\n",
+			fails: true,
+		},
+		{
+			name:  "missing end",
+			doc:   "1\n00:00:00,000 -->\nHello.\n",
+			fails: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := parseCues(tt.doc)
+
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", segments)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parsing: %s", err)
+			}
+
+			if !reflect.DeepEqual(segments, tt.expected) {
+				t.Errorf("got %+v, expected %+v", segments, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTranscribeRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      TranscribeRequest
+		expected map[string]string
+	}{
+		{
+			name:     "verbose json",
+			req:      TranscribeRequest{Model: "whisper", FileName: "audio.wav", Format: TranscriptionVerboseJSON},
+			expected: map[string]string{"model": "whisper", "response_format": "verbose_json", "timestamp_granularities[]": "segment"},
+		},
+		{
+			name:     "language and prompt",
+			req:      TranscribeRequest{Model: "whisper", FileName: "speech.mp3", Format: TranscriptionJSON, Language: "en", Prompt: "Ardan Labs"},
+			expected: map[string]string{"model": "whisper", "response_format": "json", "language": "en", "prompt": "Ardan Labs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields map[string]string
+			var fileName, audio string
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("parsing form: %s", err)
+					return
+				}
+
+				fields = make(map[string]string)
+				for key, values := range r.MultipartForm.Value {
+					fields[key] = strings.Join(values, ",")
+				}
+
+				f, header, err := r.FormFile("file")
+				if err != nil {
+					t.Errorf("form file: %s", err)
+					return
+				}
+				defer f.Close()
+
+				data, _ := io.ReadAll(f)
+				fileName, audio = header.Filename, string(data)
+
+				w.Write([]byte(`{"text":" Hello. ","language":"english","duration":1.5,"segments":[{"id":0,"start":0,"end":1.5,"text":" Hello. "}]}`))
+			}))
+			defer srv.Close()
+
+			req := tt.req
+			req.Audio = []byte("RIFF")
+
+			tr, err := NewOpenAI(NoopLogger, srv.URL).Transcribe(context.Background(), req)
+			if err != nil {
+				t.Fatalf("transcribe: %s", err)
+			}
+
+			if !reflect.DeepEqual(fields, tt.expected) {
+				t.Errorf("got fields %v, expected %v", fields, tt.expected)
+			}
+
+			if fileName != tt.req.FileName || audio != "RIFF" {
+				t.Errorf("got file %q with %q, expected %q with the audio", fileName, audio, tt.req.FileName)
+			}
+
+			expected := Transcription{
+				Text:     "Hello.",
+				Language: "english",
+				Duration: 1500 * time.Millisecond,
+				Segments: []TranscriptionSegment{{ID: 0, Start: 0, End: 1500 * time.Millisecond, Text: "Hello."}},
+			}
+
+			if !reflect.DeepEqual(tr, expected) {
+				t.Errorf("got %+v, expected %+v", tr, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"encoding/json"
+)
+
+// Usage represents the number of tokens used by one or more requests. The
+// reasoning tokens are included in the completion tokens and the cached
+// tokens are included in the prompt tokens.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	ReasoningTokens  int
+	CachedTokens     int
+}
+
+// Add returns the sum of the two usages.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		ReasoningTokens:  u.ReasoningTokens + other.ReasoningTokens,
+		CachedTokens:     u.CachedTokens + other.CachedTokens,
+	}
+}
+
+// Cost returns the cost of the usage in dollars based on the price.
+func (u Usage) Cost(price Price) float64 {
+	cached := price.Cached
+	if cached == 0 {
+		cached = price.Prompt
+	}
+
+	cost := float64(u.PromptTokens-u.CachedTokens)*price.Prompt +
+		float64(u.CachedTokens)*cached +
+		float64(u.CompletionTokens)*price.Completion
+
+	return cost / 1_000_000
+}
+
+type usageDocument struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details,omitempty"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details,omitempty"`
+}
+
+// UnmarshalJSON decodes the usage object provided by OpenAI-compatible
+// endpoints, where the cached and reasoning tokens are provided as details.
+func (u *Usage) UnmarshalJSON(b []byte) error {
+	var doc usageDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+
+	*u = Usage{
+		PromptTokens:     doc.PromptTokens,
+		CompletionTokens: doc.CompletionTokens,
+		TotalTokens:      doc.TotalTokens,
+	}
+
+	if doc.PromptTokensDetails != nil {
+		u.CachedTokens = doc.PromptTokensDetails.CachedTokens
+	}
+
+	if doc.CompletionTokensDetails != nil {
+		u.ReasoningTokens = doc.CompletionTokensDetails.ReasoningTokens
+	}
+
+	if u.TotalTokens == 0 {
+		u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the usage in the shape used by OpenAI-compatible
+// endpoints.
+func (u Usage) MarshalJSON() ([]byte, error) {
+	doc := usageDocument{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+
+	doc.PromptTokensDetails = &struct {
+		CachedTokens int `json:"cached_tokens"`
+	}{u.CachedTokens}
+
+	doc.CompletionTokensDetails = &struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	}{u.ReasoningTokens}
+
+	return json.Marshal(doc)
+}
+
+// =============================================================================
+
+// Price represents the cost in dollars per million tokens for a model. When
+// the cached price is zero, cached tokens are charged at the prompt price.
+type Price struct {
+	Prompt     float64
+	Completion float64
+	Cached     float64
+}
+
+// PriceTable maps a model name to its price.
+type PriceTable map[string]Price
+
+// Cost returns the cost of the usage in dollars for the specified model. It
+// returns false if the model is not in the table.
+func (pt PriceTable) Cost(model string, u Usage) (float64, bool) {
+	price, exists := pt[model]
+	if !exists {
+		return 0, false
+	}
+
+	return u.Cost(price), true
+}
@@ -0,0 +1,195 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+	"github.com/ardanlabs/ai-training/foundation/llmtest"
+)
+
+func TestUsageDecoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		expected client.Usage
+	}{
+		{
+			name:     "counts",
+			doc:      `{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}`,
+			expected: client.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		},
+		{
+			name:     "details",
+			doc:      `{"prompt_tokens":100,"completion_tokens":50,"total_tokens":150,"prompt_tokens_details":{"cached_tokens":80},"completion_tokens_details":{"reasoning_tokens":30}}`,
+			expected: client.Usage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150, CachedTokens: 80, ReasoningTokens: 30},
+		},
+		{
+			name:     "missing total",
+			doc:      `{"prompt_tokens":10,"completion_tokens":5}`,
+			expected: client.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var u client.Usage
+			if err := json.Unmarshal([]byte(tt.doc), &u); err != nil {
+				t.Fatalf("decoding: %s", err)
+			}
+
+			if u != tt.expected {
+				t.Errorf("got %+v, expected %+v", u, tt.expected)
+			}
+
+			// The encoded usage decodes back to the same usage.
+			data, err := json.Marshal(u)
+			if err != nil {
+				t.Fatalf("encoding: %s", err)
+			}
+
+			var back client.Usage
+			if err := json.Unmarshal(data, &back); err != nil || back != u {
+				t.Errorf("got %+v %v after a round trip, expected %+v", back, err, u)
+			}
+		})
+	}
+}
+
+func TestPriceTableCost(t *testing.T) {
+	prices := client.PriceTable{
+		"model":        {Prompt: 3, Completion: 15},
+		"cached-model": {Prompt: 3, Completion: 15, Cached: 0.3},
+	}
+
+	usage := client.Usage{PromptTokens: 1_000_000, CompletionTokens: 200_000, TotalTokens: 1_200_000, CachedTokens: 500_000}
+
+	tests := []struct {
+		name     string
+		model    string
+		usage    client.Usage
+		expected float64
+		found    bool
+	}{
+		{name: "prompt and completion", model: "model", usage: client.Usage{PromptTokens: 1_000_000, CompletionTokens: 200_000}, expected: 6, found: true},
+		{name: "cached at the prompt price", model: "model", usage: usage, expected: 6, found: true},
+		{name: "cached price", model: "cached-model", usage: usage, expected: 1.5 + 0.15 + 3, found: true},
+		{name: "no usage", model: "model", expected: 0, found: true},
+		{name: "unknown model", model: "other", usage: usage, found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost, found := prices.Cost(tt.model, tt.usage)
+
+			if found != tt.found {
+				t.Fatalf("got found %t, expected %t", found, tt.found)
+			}
+
+			if math.Abs(cost-tt.expected) > 1e-9 {
+				t.Errorf("got $%f, expected $%f", cost, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUsageAggregation(t *testing.T) {
+	srv := llmtest.New()
+	defer srv.Close()
+
+	llm, err := client.NewLLM(srv.ChatURL(), "test-model")
+	if err != nil {
+		t.Fatalf("constructing llm: %s", err)
+	}
+
+	ctx := context.Background()
+
+	// The fake server counts words, so "hello world" answered with "one two
+	// three" uses 2 prompt and 3 completion tokens.
+	expected := client.Usage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5}
+
+	tests := []struct {
+		name string
+		chat func(conv *client.Conversation) error
+	}{
+		{
+			name: "chat",
+			chat: func(conv *client.Conversation) error {
+				_, err := llm.Chat(ctx, conv)
+				return err
+			},
+		},
+		{
+			name: "stream",
+			chat: func(conv *client.Conversation) error {
+				stream, err := llm.ChatStream(ctx, conv)
+				if err != nil {
+					return err
+				}
+
+				for range stream.Events() {
+				}
+
+				return stream.Err()
+			},
+		},
+	}
+
+	var total client.Usage
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Script(llmtest.Response{Content: "one two three"})
+
+			conv := client.NewConversation("")
+			conv.Add(client.UserMessage("hello world"))
+
+			for range 2 {
+				if err := tt.chat(conv); err != nil {
+					t.Fatalf("chat: %s", err)
+				}
+				srv.Script(llmtest.Response{Content: "one two three"})
+			}
+
+			want := expected.Add(expected)
+			if got := conv.Usage(); got != want {
+				t.Errorf("got conversation usage %+v, expected %+v", got, want)
+			}
+
+			total = total.Add(want)
+			if got := llm.Usage(); got != total {
+				t.Errorf("got llm usage %+v, expected %+v", got, total)
+			}
+		})
+	}
+}
+
+func TestChatStructuredUsage(t *testing.T) {
+	srv := llmtest.New()
+	defer srv.Close()
+
+	srv.Script(
+		llmtest.Response{Content: "not json"},
+		llmtest.Response{Content: `{"name":"Bill","age":40}`},
+	)
+
+	llm, err := client.NewLLM(srv.ChatURL(), "test-model")
+	if err != nil {
+		t.Fatalf("constructing llm: %s", err)
+	}
+
+	conv := client.NewConversation("")
+	conv.Add(client.UserMessage("describe Bill"))
+
+	if _, err := client.ChatStructured[person](context.Background(), llm, conv); err != nil {
+		t.Fatalf("chat structured: %s", err)
+	}
+
+	// Both attempts are recorded against the caller's conversation.
+	u := conv.Usage()
+	if u != llm.Usage() || u.CompletionTokens != 3 {
+		t.Errorf("got conversation usage %+v, expected the usage of both attempts %+v", u, llm.Usage())
+	}
+}
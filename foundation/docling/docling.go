@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net"
 	"net/http"
@@ -33,12 +34,14 @@ var defaultClient = http.Client{
 type Docling struct {
 	client *http.Client
 	host   string
+	log    *slog.Logger
 }
 
 func New(host string, options ...func(doc *Docling)) *Docling {
 	doc := &Docling{
 		client: &defaultClient,
 		host:   host,
+		log:    slog.New(slog.DiscardHandler),
 	}
 
 	for _, option := range options {
@@ -54,7 +57,28 @@ func WithClient(http *http.Client) func(doc *Docling) {
 	}
 }
 
+// WithLogger logs the conversions performed with their duration.
+func WithLogger(log *slog.Logger) func(doc *Docling) {
+	return func(doc *Docling) {
+		doc.log = log
+	}
+}
+
 func (doc *Docling) ConvertFile(ctx context.Context, fileName string, fields map[string]string) (string, error) {
+	start := time.Now()
+
+	content, err := doc.convertFile(ctx, fileName, fields)
+	if err != nil {
+		doc.log.ErrorContext(ctx, "docling: convertfile", "file", fileName, "duration", time.Since(start), "ERROR", err)
+		return "", err
+	}
+
+	doc.log.InfoContext(ctx, "docling: convertfile", "file", fileName, "duration", time.Since(start), "bytes", len(content))
+
+	return content, nil
+}
+
+func (doc *Docling) convertFile(ctx context.Context, fileName string, fields map[string]string) (string, error) {
 	var b bytes.Buffer
 	writer := multipart.NewWriter(&b)
 
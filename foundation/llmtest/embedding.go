@@ -0,0 +1,49 @@
+package llmtest
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"unicode"
+)
+
+// Embedding returns a deterministic embedding for the text with the
+// specified number of dimensions. Each word contributes a pseudo-random
+// vector seeded by its hash, so texts sharing words are similar to each
+// other. The embedding is normalized to a unit vector unless the text has
+// no words.
+func Embedding(text string, dimensions int) []float64 {
+	vector := make([]float64, dimensions)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	for _, word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		seed := h.Sum64()
+
+		rnd := rand.New(rand.NewPCG(seed, seed>>32))
+		for i := range vector {
+			vector[i] += rnd.NormFloat64()
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+
+	if norm == 0 {
+		return vector
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+
+	return vector
+}
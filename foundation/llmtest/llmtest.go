@@ -0,0 +1,682 @@
+// Package llmtest provides a programmable OpenAI-compatible model server for
+// running tests without a model server. The server supports chat completions,
+// both streaming and not, with tool calls, embeddings and audio
+// transcriptions. Responses can be scripted, latency can be injected and
+// errors can be returned.
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+)
+
+// ToolCall represents a tool call the model responds with.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments map[string]any
+}
+
+// Response represents a scripted response for a chat completion request.
+// When StatusCode is set the request fails with that status code and the
+// Error message. When StreamError is set a streamed response is ended with
+// an error event after the content. The log probabilities are sent with
+// the choice, or with the final chunk of a streamed response.
+type Response struct {
+	Content     string
+	Reasoning   string
+	ToolCalls   []ToolCall
+	Logprobs    []client.TokenLogprob
+	Latency     time.Duration
+	StatusCode  int
+	Error       string
+	RetryAfter  time.Duration
+	StreamError string
+}
+
+// Request represents a chat completion request received by the server.
+type Request struct {
+	Model    string
+	Messages []Message
+	Stream   bool
+	Tools    []client.D
+	Body     client.D
+}
+
+// Message represents a message in a received request. The text of a
+// multimodal message is provided in Content.
+type Message struct {
+	Role       string
+	Content    string
+	ToolCalls  []client.ToolCall
+	ToolCallID string
+}
+
+// LastUserMessage returns the content of the last user message in the
+// request.
+func (r Request) LastUserMessage() string {
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == client.RoleUser {
+			return r.Messages[i].Content
+		}
+	}
+
+	return ""
+}
+
+// Handler computes the response for a request that doesn't have a scripted
+// response.
+type Handler func(req Request) Response
+
+// EchoHandler responds with the content of the last user message. It's the
+// handler used by default.
+func EchoHandler(req Request) Response {
+	return Response{
+		Content: req.LastUserMessage(),
+	}
+}
+
+// =============================================================================
+
+// Server is a fake model server that speaks the OpenAI-compatible API on
+// /v1/chat/completions, /v1/embeddings and /v1/audio/transcriptions.
+type Server struct {
+	srv        *httptest.Server
+	handler    Handler
+	dimensions int
+	chunkDelay time.Duration
+	chunkSize  int
+	maxChoices int
+	transcript string
+
+	mu       sync.Mutex
+	script   []Response
+	requests []Request
+}
+
+// New constructs and starts a server. Call Close when the server is no
+// longer needed.
+func New(options ...func(s *Server)) *Server {
+	s := Server{
+		handler:    EchoHandler,
+		dimensions: 384,
+		chunkSize:  1,
+		transcript: defaultTranscript,
+	}
+
+	for _, option := range options {
+		option(&s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.chatCompletions)
+	mux.HandleFunc("POST /v1/embeddings", s.embeddings)
+	mux.HandleFunc("POST /v1/audio/transcriptions", s.transcriptions)
+
+	s.srv = httptest.NewServer(mux)
+
+	return &s
+}
+
+// WithHandler sets the handler used for requests without a scripted
+// response.
+func WithHandler(handler Handler) func(s *Server) {
+	return func(s *Server) {
+		s.handler = handler
+	}
+}
+
+// WithDimensions sets the number of dimensions of the embeddings.
+func WithDimensions(dimensions int) func(s *Server) {
+	return func(s *Server) {
+		s.dimensions = dimensions
+	}
+}
+
+// WithChunkDelay sets the delay between the chunks of a streamed response.
+func WithChunkDelay(delay time.Duration) func(s *Server) {
+	return func(s *Server) {
+		s.chunkDelay = delay
+	}
+}
+
+// WithChunkSize sets the number of words sent in each chunk of a streamed
+// response.
+func WithChunkSize(words int) func(s *Server) {
+	return func(s *Server) {
+		s.chunkSize = max(words, 1)
+	}
+}
+
+// WithMaxChoices limits the number of choices in a response regardless of
+// the number asked for with n, like the servers that ignore n when set to 1.
+func WithMaxChoices(n int) func(s *Server) {
+	return func(s *Server) {
+		s.maxChoices = max(n, 1)
+	}
+}
+
+// URL returns the base url of the server, like http://127.0.0.1:54321.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// ChatURL returns the url of the chat completions endpoint.
+func (s *Server) ChatURL() string {
+	return s.srv.URL + "/v1/chat/completions"
+}
+
+// EmbeddingsURL returns the url of the embeddings endpoint.
+func (s *Server) EmbeddingsURL() string {
+	return s.srv.URL + "/v1/embeddings"
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Script queues responses that are returned in order for the next chat
+// completion requests. Once the script is exhausted the handler is used.
+func (s *Server) Script(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.script = append(s.script, responses...)
+}
+
+// Requests returns the chat completion requests received by the server.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request{}, s.requests...)
+}
+
+// =============================================================================
+
+func (s *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var body client.D
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("decoding: %s", err), 0)
+		return
+	}
+
+	req, err := toRequest(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), 0)
+		return
+	}
+
+	resp := s.next(req)
+
+	if resp.Latency > 0 {
+		select {
+		case <-time.After(resp.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if resp.StatusCode != 0 {
+		writeError(w, resp.StatusCode, resp.Error, resp.RetryAfter)
+		return
+	}
+
+	if req.Stream {
+		s.stream(w, r, req, resp)
+		return
+	}
+
+	// Every extra choice asked for with n takes the next response.
+	responses := []Response{resp}
+	if n, ok := body["n"].(float64); ok {
+		if s.maxChoices > 0 {
+			n = min(n, float64(s.maxChoices))
+		}
+		for range int(n) - 1 {
+			responses = append(responses, s.respond(req))
+		}
+	}
+
+	chat := client.Chat{
+		ID:      "chatcmpl-llmtest",
+		Object:  "chat.completion",
+		Created: client.ToTime(time.Now().Unix()),
+		Model:   req.Model,
+		Usage:   &client.Usage{},
+	}
+
+	for i, resp := range responses {
+		chat.Choices = append(chat.Choices, client.ChatChoice{
+			Index: i,
+			Message: client.ChatMessage{
+				Role:      client.RoleAssistant,
+				Content:   resp.Content,
+				Reasoning: resp.Reasoning,
+				ToolCalls: toToolCalls(resp.ToolCalls, true),
+			},
+			Logprobs:     logprobs(resp),
+			FinishReason: finishReason(resp),
+		})
+
+		// The prompt is only counted once for the request.
+		u := usage(req, resp)
+		if i > 0 {
+			u.TotalTokens -= u.PromptTokens
+			u.PromptTokens = 0
+		}
+		*chat.Usage = chat.Usage.Add(u)
+	}
+
+	writeJSON(w, chat)
+}
+
+// stream writes the response as an event stream, with the content split in
+// chunks of words and each tool call split in two chunks so clients need to
+// accumulate them.
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, req Request, resp Response) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	f, _ := w.(http.Flusher)
+
+	send := func(v any) bool {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if f != nil {
+			f.Flush()
+		}
+
+		if s.chunkDelay > 0 {
+			select {
+			case <-time.After(s.chunkDelay):
+			case <-r.Context().Done():
+				return false
+			}
+		}
+
+		return true
+	}
+
+	chunk := func(delta client.ChatDeltaSSE, finishReason string) client.ChatSSE {
+		return client.ChatSSE{
+			ID:      "chatcmpl-llmtest",
+			Object:  "chat.completion.chunk",
+			Created: client.ToTime(time.Now().Unix()),
+			Model:   req.Model,
+			Choices: []client.ChatChoiceSSE{
+				{
+					Delta:        delta,
+					FinishReason: finishReason,
+				},
+			},
+		}
+	}
+
+	if !send(chunk(client.ChatDeltaSSE{Role: client.RoleAssistant}, "")) {
+		return
+	}
+
+	for _, text := range split(resp.Reasoning, s.chunkSize) {
+		if !send(chunk(client.ChatDeltaSSE{Reasoning: text}, "")) {
+			return
+		}
+	}
+
+	for _, text := range split(resp.Content, s.chunkSize) {
+		if !send(chunk(client.ChatDeltaSSE{Content: text}, "")) {
+			return
+		}
+	}
+
+	for i, tc := range toToolCalls(resp.ToolCalls, false) {
+		args := []byte("{}")
+		if resp.ToolCalls[i].Arguments != nil {
+			args, _ = json.Marshal(resp.ToolCalls[i].Arguments)
+		}
+		half := len(args) / 2
+
+		tc.Function.RawArguments = string(args[:half])
+		if !send(chunk(client.ChatDeltaSSE{ToolCalls: []client.ToolCall{tc}}, "")) {
+			return
+		}
+
+		rest := client.ToolCall{
+			Index: i,
+			Function: client.Function{
+				RawArguments: string(args[half:]),
+			},
+		}
+		if !send(chunk(client.ChatDeltaSSE{ToolCalls: []client.ToolCall{rest}}, "")) {
+			return
+		}
+	}
+
+	if resp.StreamError != "" {
+		send(client.D{"error": client.D{"message": resp.StreamError}})
+		return
+	}
+
+	last := chunk(client.ChatDeltaSSE{}, finishReason(resp))
+	last.Choices[0].Logprobs = logprobs(resp)
+
+	if !send(last) {
+		return
+	}
+
+	if opts, ok := req.Body["stream_options"].(map[string]any); ok && opts["include_usage"] == true {
+		u := usage(req, resp)
+
+		v := chunk(client.ChatDeltaSSE{}, "")
+		v.Choices = []client.ChatChoiceSSE{}
+		v.Usage = &u
+
+		if !send(v) {
+			return
+		}
+	}
+
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if f != nil {
+		f.Flush()
+	}
+}
+
+func (s *Server) embeddings(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("decoding: %s", err), 0)
+		return
+	}
+
+	inputs, err := toInputs(body.Input)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), 0)
+		return
+	}
+
+	resp := client.Embedding{
+		Object:  "list",
+		Created: client.ToTime(time.Now().Unix()),
+		Model:   body.Model,
+		Data:    make([]client.EmbeddingData, len(inputs)),
+	}
+
+	for i, input := range inputs {
+		resp.Data[i] = client.EmbeddingData{
+			Index:     i,
+			Object:    "embedding",
+			Embedding: Embedding(input, s.dimensions),
+		}
+	}
+
+	writeJSON(w, resp)
+}
+
+// next returns the next scripted response or the handler's response and
+// records the request.
+func (s *Server) next(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+
+	return s.response(req)
+}
+
+// respond returns the next response without recording the request.
+func (s *Server) respond(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.response(req)
+}
+
+func (s *Server) response(req Request) Response {
+	if len(s.script) > 0 {
+		resp := s.script[0]
+		s.script = s.script[1:]
+		return resp
+	}
+
+	return s.handler(req)
+}
+
+// =============================================================================
+
+// toRequest converts the decoded body of a chat completion request.
+func toRequest(body client.D) (Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return Request{}, err
+	}
+
+	var doc struct {
+		Model    string `json:"model"`
+		Stream   bool   `json:"stream"`
+		Messages []struct {
+			Role       string            `json:"role"`
+			Content    json.RawMessage   `json:"content"`
+			ToolCalls  []client.ToolCall `json:"tool_calls"`
+			ToolCallID string            `json:"tool_call_id"`
+		} `json:"messages"`
+		Tools []client.D `json:"tools"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Request{}, fmt.Errorf("decoding: %w", err)
+	}
+
+	req := Request{
+		Model:    doc.Model,
+		Stream:   doc.Stream,
+		Tools:    doc.Tools,
+		Body:     body,
+		Messages: make([]Message, len(doc.Messages)),
+	}
+
+	for i, msg := range doc.Messages {
+		content, err := toText(msg.Content)
+		if err != nil {
+			return Request{}, fmt.Errorf("message[%d]: %w", i, err)
+		}
+
+		req.Messages[i] = Message{
+			Role:       msg.Role,
+			Content:    content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
+		}
+	}
+
+	return req, nil
+}
+
+// toText converts message content, which is either a string or an array of
+// content parts, to text.
+func toText(content json.RawMessage) (string, error) {
+	if len(content) == 0 || string(content) == "null" {
+		return "", nil
+	}
+
+	var s string
+	if err := json.Unmarshal(content, &s); err == nil {
+		return s, nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return "", fmt.Errorf("content: %w", err)
+	}
+
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+
+	return strings.Join(texts, "\n"), nil
+}
+
+// toInputs converts the embedding input, which is a string, an array of
+// strings or an array of content parts, to the texts to embed.
+func toInputs(input json.RawMessage) ([]string, error) {
+	var s string
+	if err := json.Unmarshal(input, &s); err == nil {
+		return []string{s}, nil
+	}
+
+	var ss []string
+	if err := json.Unmarshal(input, &ss); err == nil {
+		return ss, nil
+	}
+
+	text, err := toText(input)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+
+	return []string{text}, nil
+}
+
+func toToolCalls(calls []ToolCall, arguments bool) []client.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	toolCalls := make([]client.ToolCall, len(calls))
+
+	for i, call := range calls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+
+		toolCalls[i] = client.ToolCall{
+			ID:    id,
+			Index: i,
+			Type:  "function",
+			Function: client.Function{
+				Name: call.Name,
+			},
+		}
+
+		if arguments {
+			toolCalls[i].Function.Arguments = call.Arguments
+			if call.Arguments == nil {
+				toolCalls[i].Function.Arguments = map[string]any{}
+			}
+		}
+	}
+
+	return toolCalls
+}
+
+func finishReason(resp Response) string {
+	if len(resp.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+
+	return "stop"
+}
+
+func logprobs(resp Response) *client.Logprobs {
+	if resp.Logprobs == nil {
+		return nil
+	}
+
+	return &client.Logprobs{Content: resp.Logprobs}
+}
+
+// usage estimates the tokens used by counting words.
+func usage(req Request, resp Response) client.Usage {
+	var prompt int
+	for _, msg := range req.Messages {
+		prompt += len(strings.Fields(msg.Content))
+	}
+
+	completion := len(strings.Fields(resp.Content)) + len(strings.Fields(resp.Reasoning))
+
+	return client.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+		ReasoningTokens:  len(strings.Fields(resp.Reasoning)),
+	}
+}
+
+// split breaks the text into chunks of the specified number of words,
+// keeping the whitespace so the chunks join back to the text.
+func split(text string, words int) []string {
+	if text == "" {
+		return nil
+	}
+
+	var chunks []string
+	var start, count int
+	inWord := false
+
+	for i, r := range text {
+		isSpace := r == ' ' || r == '\n' || r == '\t'
+
+		if !isSpace && !inWord {
+			if count == words {
+				chunks = append(chunks, text[start:i])
+				start, count = i, 0
+			}
+			count++
+		}
+
+		inWord = !isSpace
+	}
+
+	return append(chunks, text[start:])
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, msg string, retryAfter time.Duration) {
+	if msg == "" {
+		msg = http.StatusText(statusCode)
+	}
+
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	json.NewEncoder(w).Encode(client.D{
+		"error": client.D{
+			"message": msg,
+			"type":    http.StatusText(statusCode),
+			"code":    statusCode,
+		},
+	})
+}
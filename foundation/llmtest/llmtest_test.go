@@ -0,0 +1,489 @@
+package llmtest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/ai-training/foundation/client"
+	"github.com/ardanlabs/ai-training/foundation/llmtest"
+	"github.com/ardanlabs/ai-training/foundation/vector"
+)
+
+func TestChat(t *testing.T) {
+	srv := llmtest.New()
+	defer srv.Close()
+
+	srv.Script(llmtest.Response{Content: "scripted answer"})
+
+	llm := newLLM(t, srv.ChatURL())
+
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{content: "first question", expected: "scripted answer"},
+		{content: "echo this", expected: "echo this"},
+	}
+
+	for _, tt := range tests {
+		msg, err := llm.Chat(context.Background(), conversation(tt.content))
+		if err != nil {
+			t.Fatalf("chat: %s", err)
+		}
+
+		if msg.Content != tt.expected {
+			t.Errorf("got %q, expected %q", msg.Content, tt.expected)
+		}
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, expected 2", len(requests))
+	}
+
+	if requests[0].Model != "test-model" || requests[0].LastUserMessage() != "first question" || requests[0].Stream {
+		t.Errorf("got request %+v, expected the first question", requests[0])
+	}
+}
+
+func TestChatToolCalls(t *testing.T) {
+	srv := llmtest.New()
+	defer srv.Close()
+
+	call := llmtest.ToolCall{ID: "call_weather", Name: "get_weather", Arguments: map[string]any{"city": "Miami"}}
+
+	srv.Script(llmtest.Response{ToolCalls: []llmtest.ToolCall{call}})
+
+	llm := newLLM(t, srv.ChatURL())
+
+	msg, err := llm.Chat(context.Background(), conversation("what's the weather in Miami?"))
+	if err != nil {
+		t.Fatalf("chat: %s", err)
+	}
+
+	checkToolCalls(t, msg.ToolCalls)
+}
+
+func TestChatStreamToolCalls(t *testing.T) {
+	srv := llmtest.New(llmtest.WithChunkSize(1))
+	defer srv.Close()
+
+	call := llmtest.ToolCall{ID: "call_weather", Name: "get_weather", Arguments: map[string]any{"city": "Miami"}}
+
+	srv.Script(llmtest.Response{Content: "let me check", ToolCalls: []llmtest.ToolCall{call}})
+
+	llm := newLLM(t, srv.ChatURL())
+
+	stream, err := llm.ChatStream(context.Background(), conversation("what's the weather in Miami?"))
+	if err != nil {
+		t.Fatalf("chat stream: %s", err)
+	}
+
+	// The content is sent a word at a time and the arguments of the tool
+	// call are split over two chunks, so the events must put them back
+	// together.
+
+	var deltas []string
+	var toolCalls []client.ToolCall
+	var done client.Done
+
+	events := client.ChatEvents(context.Background(), stream)
+	for evt := range events.Events() {
+		switch evt := evt.(type) {
+		case client.ContentDelta:
+			deltas = append(deltas, evt.Text)
+		case client.ToolCallComplete:
+			toolCalls = evt.ToolCalls
+		case client.Done:
+			done = evt
+		}
+	}
+
+	if err := events.Err(); err != nil {
+		t.Fatalf("chat events: %s", err)
+	}
+
+	if len(deltas) != 3 || strings.Join(deltas, "") != "let me check" {
+		t.Errorf("got content deltas %q, expected the words of %q", deltas, "let me check")
+	}
+
+	checkToolCalls(t, toolCalls)
+
+	if done.FinishReason != "tool_calls" {
+		t.Errorf("got finish reason %q, expected tool_calls", done.FinishReason)
+	}
+}
+
+func TestChatStreamError(t *testing.T) {
+	srv := llmtest.New()
+	defer srv.Close()
+
+	srv.Script(llmtest.Response{Content: "partial answer", StreamError: "model crashed"})
+
+	llm := newLLM(t, srv.ChatURL())
+
+	stream, err := llm.ChatStream(context.Background(), conversation("hello"))
+	if err != nil {
+		t.Fatalf("chat stream: %s", err)
+	}
+
+	for range stream.Events() {
+	}
+
+	if err := stream.Err(); err == nil || !strings.Contains(err.Error(), "model crashed") {
+		t.Errorf("got error %v, expected the stream error", err)
+	}
+}
+
+func TestEmbedTexts(t *testing.T) {
+	srv := llmtest.New(llmtest.WithDimensions(64))
+	defer srv.Close()
+
+	llm := newLLM(t, srv.EmbeddingsURL())
+
+	inputs := []string{"the cat sat on the mat", "a cat on a mat", "quarterly tax filing"}
+
+	vectors, err := llm.EmbedTexts(context.Background(), inputs, client.WithBatch(2, 0))
+	if err != nil {
+		t.Fatalf("embedding: %s", err)
+	}
+
+	if len(vectors) != len(inputs) {
+		t.Fatalf("got %d vectors, expected %d", len(vectors), len(inputs))
+	}
+
+	for i, v := range vectors {
+		expected := llmtest.Embedding(inputs[i], 64)
+		if len(v) != 64 || v[0] != expected[0] || v[63] != expected[63] {
+			t.Errorf("input %d: got a different embedding than Embedding returns", i)
+		}
+	}
+
+	// Texts sharing words are more similar than unrelated texts.
+
+	similar, _ := vector.CosineSimilarity(vectors[0], vectors[1])
+	unrelated, _ := vector.CosineSimilarity(vectors[0], vectors[2])
+
+	if similar <= unrelated {
+		t.Errorf("got similarity %.3f for related texts, expected more than %.3f for unrelated texts", similar, unrelated)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+	}{
+		{name: "bad request", statusCode: http.StatusBadRequest},
+		{name: "unauthorized", statusCode: http.StatusUnauthorized},
+		{name: "server error", statusCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.New()
+			defer srv.Close()
+
+			srv.Script(llmtest.Response{StatusCode: tt.statusCode, Error: "injected failure"})
+
+			llm := newLLM(t, srv.ChatURL())
+
+			_, err := llm.Chat(context.Background(), conversation("hello"))
+
+			var apiErr *client.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got error %v, expected an API error", err)
+			}
+
+			if apiErr.StatusCode != tt.statusCode || !strings.Contains(apiErr.Error(), "injected failure") {
+				t.Errorf("got %d %q, expected %d with the injected message", apiErr.StatusCode, apiErr.Error(), tt.statusCode)
+			}
+
+			if n := len(srv.Requests()); n != 1 {
+				t.Errorf("got %d requests, expected the error not to be retried", n)
+			}
+		})
+	}
+
+	t.Run("retried", func(t *testing.T) {
+		srv := llmtest.New()
+		defer srv.Close()
+
+		srv.Script(llmtest.Response{StatusCode: http.StatusTooManyRequests}, llmtest.Response{Content: "finally"})
+
+		llm := newLLM(t, srv.ChatURL())
+
+		msg, err := llm.Chat(context.Background(), conversation("hello"))
+		if err != nil {
+			t.Fatalf("chat: %s", err)
+		}
+
+		if msg.Content != "finally" || len(srv.Requests()) != 2 {
+			t.Errorf("got %q after %d requests, expected the second response", msg.Content, len(srv.Requests()))
+		}
+	})
+}
+
+func TestLatency(t *testing.T) {
+	srv := llmtest.New()
+	defer srv.Close()
+
+	const latency = 200 * time.Millisecond
+
+	srv.Script(llmtest.Response{Content: "slow", Latency: latency}, llmtest.Response{Content: "too slow", Latency: latency})
+
+	llm := newLLM(t, srv.ChatURL())
+
+	start := time.Now()
+
+	if _, err := llm.Chat(context.Background(), conversation("hello")); err != nil {
+		t.Fatalf("chat: %s", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("got a response after %s, expected at least %s", elapsed, latency)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), latency/4)
+	defer cancel()
+
+	if _, err := llm.Chat(ctx, conversation("hello")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestTranscribe(t *testing.T) {
+	srv := llmtest.New(llmtest.WithTranscript("Hello there. How are you today."))
+	defer srv.Close()
+
+	llm := newLLM(t, srv.TranscriptionsURL())
+
+	verbose := []client.TranscriptionSegment{
+		{ID: 0, Start: 0, End: 800 * time.Millisecond, Text: "Hello there."},
+		{ID: 1, Start: 800 * time.Millisecond, End: 2400 * time.Millisecond, Text: "How are you today."},
+	}
+
+	cues := []client.TranscriptionSegment{
+		{ID: 1, Start: 0, End: 800 * time.Millisecond, Text: "Hello there."},
+		{ID: 2, Start: 800 * time.Millisecond, End: 2400 * time.Millisecond, Text: "How are you today."},
+	}
+
+	tests := []struct {
+		format   client.TranscriptionFormat
+		language string
+		duration time.Duration
+		segments []client.TranscriptionSegment
+		raw      bool
+	}{
+		{format: client.TranscriptionVerboseJSON, language: "english", duration: 2400 * time.Millisecond, segments: verbose},
+		{format: client.TranscriptionJSON},
+		{format: client.TranscriptionText, raw: true},
+		{format: client.TranscriptionSRT, segments: cues, raw: true},
+		{format: client.TranscriptionVTT, segments: cues, raw: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			tr, err := llm.Transcribe(context.Background(), strings.NewReader("RIFF"), client.WithTranscriptionFormat(tt.format))
+			if err != nil {
+				t.Fatalf("transcribe: %s", err)
+			}
+
+			if tr.Text != "Hello there. How are you today." {
+				t.Errorf("got text %q", tr.Text)
+			}
+
+			if tr.Language != tt.language || tr.Duration != tt.duration {
+				t.Errorf("got language %q duration %s, expected %q %s", tr.Language, tr.Duration, tt.language, tt.duration)
+			}
+
+			if !reflect.DeepEqual(tr.Segments, tt.segments) {
+				t.Errorf("got segments %+v, expected %+v", tr.Segments, tt.segments)
+			}
+
+			if (tr.Raw != "") != tt.raw {
+				t.Errorf("got raw %q, expected raw %t", tr.Raw, tt.raw)
+			}
+		})
+	}
+}
+
+// chatOnly hides the ChoicesProvider implementation of the provider so the
+// LLM makes a request per choice.
+type chatOnly struct {
+	client.Provider
+}
+
+func TestChatChoices(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []func(s *llmtest.Server)
+		chatOnly bool
+		n        int
+		requests int
+		sentN    []any
+		expected []string
+	}{
+		{name: "one choice", n: 1, requests: 1, sentN: []any{nil}, expected: []string{"a"}},
+		{name: "choices", n: 3, requests: 1, sentN: []any{3.0}, expected: []string{"a", "b", "c"}},
+		{name: "n ignored", options: []func(s *llmtest.Server){llmtest.WithMaxChoices(1)}, n: 3, requests: 3, sentN: []any{3.0, nil, nil}, expected: []string{"a", "b", "c"}},
+		{name: "n partially honored", options: []func(s *llmtest.Server){llmtest.WithMaxChoices(2)}, n: 3, requests: 2, sentN: []any{3.0, nil}, expected: []string{"a", "b", "c"}},
+		{name: "provider without choices", chatOnly: true, n: 3, requests: 3, sentN: []any{nil, nil, nil}, expected: []string{"a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.New(tt.options...)
+			defer srv.Close()
+
+			srv.Script(
+				llmtest.Response{Content: "a"},
+				llmtest.Response{Content: "b"},
+				llmtest.Response{Content: "c"},
+			)
+
+			llm := newLLM(t, srv.ChatURL())
+			if tt.chatOnly {
+				var err error
+				llm, err = client.NewProviderLLM(chatOnly{client.NewOpenAI(client.NoopLogger, srv.ChatURL())}, "test-model")
+				if err != nil {
+					t.Fatalf("constructing llm: %s", err)
+				}
+			}
+
+			conv := conversation("pick a letter")
+
+			msgs, err := llm.ChatChoices(context.Background(), conv, client.WithChoices(tt.n))
+			if err != nil {
+				t.Fatalf("chat choices: %s", err)
+			}
+
+			contents := make([]string, len(msgs))
+			for i, msg := range msgs {
+				contents[i] = msg.Content
+			}
+
+			if !reflect.DeepEqual(contents, tt.expected) {
+				t.Errorf("got %v, expected %v", contents, tt.expected)
+			}
+
+			requests := srv.Requests()
+			if len(requests) != tt.requests {
+				t.Fatalf("got %d requests, expected %d", len(requests), tt.requests)
+			}
+
+			for i, req := range requests {
+				if req.Body["n"] != tt.sentN[i] {
+					t.Errorf("request %d: got n %v, expected %v", i, req.Body["n"], tt.sentN[i])
+				}
+			}
+
+			// Every choice is a word and the prompt is counted once per
+			// request.
+			expected := client.Usage{PromptTokens: 3 * tt.requests, CompletionTokens: tt.n, TotalTokens: 3*tt.requests + tt.n}
+			if u := conv.Usage(); u != expected {
+				t.Errorf("got usage %+v, expected %+v", u, expected)
+			}
+		})
+	}
+}
+
+func TestLogprobs(t *testing.T) {
+	lps := []client.TokenLogprob{
+		{Token: "Hello", Logprob: -0.25, Bytes: []int{72, 101, 108, 108, 111}, TopLogprobs: []client.TopLogprob{{Token: "Hello", Logprob: -0.25}, {Token: "Hi", Logprob: -1.5}}},
+		{Token: " world", Logprob: -0.5, TopLogprobs: []client.TopLogprob{{Token: " world", Logprob: -0.5}, {Token: " there", Logprob: -2}}},
+	}
+
+	tests := []struct {
+		name   string
+		stream bool
+	}{
+		{name: "chat"},
+		{name: "stream", stream: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.New()
+			defer srv.Close()
+
+			srv.Script(llmtest.Response{Content: "Hello world", Logprobs: lps})
+
+			llm := newLLM(t, srv.ChatURL())
+			ctx := context.Background()
+
+			var msg client.Message
+			switch tt.stream {
+			case true:
+				events, err := llm.ChatEvents(ctx, conversation("greet me"), client.WithLogprobs(2))
+				if err != nil {
+					t.Fatalf("chat events: %s", err)
+				}
+
+				for ev := range events.Events() {
+					if done, ok := ev.(client.Done); ok {
+						msg = done.Message
+					}
+				}
+
+				if err := events.Err(); err != nil {
+					t.Fatalf("chat events: %s", err)
+				}
+
+			default:
+				var err error
+				msg, err = llm.Chat(ctx, conversation("greet me"), client.WithLogprobs(2))
+				if err != nil {
+					t.Fatalf("chat: %s", err)
+				}
+			}
+
+			if !reflect.DeepEqual(msg.Logprobs, lps) {
+				t.Errorf("got %+v, expected %+v", msg.Logprobs, lps)
+			}
+
+			body := srv.Requests()[0].Body
+			if body["logprobs"] != true || body["top_logprobs"] != 2.0 {
+				t.Errorf("got logprobs %v and top_logprobs %v, expected true and 2", body["logprobs"], body["top_logprobs"])
+			}
+		})
+	}
+}
+
+// =============================================================================
+
+func newLLM(t *testing.T, url string) *client.LLM {
+	t.Helper()
+
+	llm, err := client.NewLLM(url, "test-model")
+	if err != nil {
+		t.Fatalf("constructing llm: %s", err)
+	}
+
+	return llm
+}
+
+func conversation(content string) *client.Conversation {
+	conv := client.NewConversation("")
+	conv.Add(client.UserMessage(content))
+
+	return conv
+}
+
+func checkToolCalls(t *testing.T, toolCalls []client.ToolCall) {
+	t.Helper()
+
+	if len(toolCalls) != 1 {
+		t.Fatalf("got %d tool calls, expected 1", len(toolCalls))
+	}
+
+	tc := toolCalls[0]
+	if tc.ID != "call_weather" || tc.Function.Name != "get_weather" || tc.Function.Arguments["city"] != "Miami" {
+		t.Errorf("got tool call %+v, expected get_weather for Miami", tc)
+	}
+}
@@ -0,0 +1,132 @@
+package llmtest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultTranscript is the transcript of zarf/samples/audio/jfk.wav.
+const defaultTranscript = "And so, my fellow Americans, ask not what your country can do for you. Ask what you can do for your country."
+
+// wordDuration is how long every word of the transcript is said to last.
+const wordDuration = 400 * time.Millisecond
+
+// WithTranscript sets the text returned for every transcription request.
+// The text is split in a segment per sentence.
+func WithTranscript(text string) func(s *Server) {
+	return func(s *Server) {
+		s.transcript = text
+	}
+}
+
+// TranscriptionsURL returns the url of the audio transcriptions endpoint.
+func (s *Server) TranscriptionsURL() string {
+	return s.srv.URL + "/v1/audio/transcriptions"
+}
+
+// =============================================================================
+
+type segment struct {
+	ID    int     `json:"id"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+func (s *Server) transcriptions(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("parsing form: %s", err), 0)
+		return
+	}
+
+	if _, _, err := r.FormFile("file"); err != nil {
+		writeError(w, http.StatusBadRequest, "file is required", 0)
+		return
+	}
+
+	segments := transcriptSegments(s.transcript)
+
+	var duration float64
+	if len(segments) > 0 {
+		duration = segments[len(segments)-1].End
+	}
+
+	switch format := r.FormValue("response_format"); format {
+	case "", "json":
+		writeJSON(w, map[string]any{
+			"text": s.transcript,
+		})
+
+	case "verbose_json":
+		writeJSON(w, map[string]any{
+			"task":     "transcribe",
+			"language": "english",
+			"duration": duration,
+			"text":     s.transcript,
+			"segments": segments,
+		})
+
+	case "text":
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintln(w, s.transcript)
+
+	case "srt", "vtt":
+		var b strings.Builder
+
+		sep := ","
+		if format == "vtt" {
+			sep = "."
+			b.WriteString("WEBVTT\n\n")
+		}
+
+		for _, seg := range segments {
+			fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", seg.ID+1, timestamp(seg.Start, sep), timestamp(seg.End, sep), seg.Text)
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, b.String())
+
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown response format %q", format), 0)
+	}
+}
+
+// transcriptSegments splits the transcript in a segment per sentence.
+func transcriptSegments(transcript string) []segment {
+	var segments []segment
+	var start time.Duration
+
+	for sentence := range strings.SplitAfterSeq(transcript, ". ") {
+		sentence = strings.TrimSpace(sentence)
+		if sentence == "" {
+			continue
+		}
+
+		end := start + time.Duration(len(strings.Fields(sentence)))*wordDuration
+
+		segments = append(segments, segment{
+			ID:    len(segments),
+			Start: start.Seconds(),
+			End:   end.Seconds(),
+			Text:  sentence,
+		})
+
+		start = end
+	}
+
+	return segments
+}
+
+// timestamp formats the seconds as a timestamp like 00:00:01,500.
+func timestamp(secs float64, sep string) string {
+	d := time.Duration(secs * float64(time.Second))
+
+	h := d / time.Hour
+	m := d % time.Hour / time.Minute
+	sec := d % time.Minute / time.Second
+	ms := d % time.Second / time.Millisecond
+
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, sec, sep, ms)
+}
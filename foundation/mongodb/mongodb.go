@@ -5,23 +5,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-// Connect attempts to connect to a mongo db instance.
-func Connect(ctx context.Context, host string, userName string, password string) (*mongo.Client, error) {
-	auth := options.Client().SetAuth(options.Credential{
-		Username: userName,
-		Password: password,
-	})
-
-	uri := options.Client().ApplyURI(host + "/?directConnection=true")
+// Connect attempts to connect to a mongo db instance. The settings can be
+// used to change the client options, like WithLogger.
+func Connect(ctx context.Context, host string, userName string, password string, settings ...func(opts *options.ClientOptions)) (*mongo.Client, error) {
+	opts := options.Client().
+		SetAuth(options.Credential{
+			Username: userName,
+			Password: password,
+		}).
+		ApplyURI(host + "/?directConnection=true")
+
+	for _, setting := range settings {
+		setting(opts)
+	}
 
-	client, err := mongo.Connect(ctx, auth, uri)
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("connect: %w", err)
 	}
@@ -33,6 +40,22 @@ func Connect(ctx context.Context, host string, userName string, password string)
 	return client, nil
 }
 
+// WithLogger logs every command sent to the database with its duration.
+// Successful commands are logged at the debug level and failed commands at
+// the error level.
+func WithLogger(log *slog.Logger) func(opts *options.ClientOptions) {
+	return func(opts *options.ClientOptions) {
+		opts.SetMonitor(&event.CommandMonitor{
+			Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+				log.DebugContext(ctx, "mongodb: command", "name", evt.CommandName, "database", evt.DatabaseName, "requestid", evt.RequestID, "duration", evt.Duration)
+			},
+			Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+				log.ErrorContext(ctx, "mongodb: command", "name", evt.CommandName, "database", evt.DatabaseName, "requestid", evt.RequestID, "duration", evt.Duration, "ERROR", evt.Failure)
+			},
+		})
+	}
+}
+
 // CreateCollection will create the specified collection in the specified
 // database if it doesn't already exist.
 func CreateCollection(ctx context.Context, db *mongo.Database, collectionName string) (*mongo.Collection, error) {
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
 	"strings"
 	"time"
@@ -75,7 +76,7 @@ func Open(cfg Config) (*sqlx.DB, error) {
 
 // StatusCheck returns nil if it can successfully talk to the database. It
 // returns a non-nil error otherwise.
-func StatusCheck(ctx context.Context, db *sqlx.DB) error {
+func StatusCheck(ctx context.Context, log *slog.Logger, db *sqlx.DB) error {
 
 	// If the user doesn't give us a deadline set 1 second.
 	if _, ok := ctx.Deadline(); !ok {
@@ -85,10 +86,15 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 	}
 
 	for attempts := 1; ; attempts++ {
-		if err := db.Ping(); err == nil {
+		err := db.Ping()
+		if err == nil {
 			break
 		}
 
+		if log != nil {
+			log.WarnContext(ctx, "database: statuscheck", "attempts", attempts, "ERROR", err)
+		}
+
 		time.Sleep(time.Duration(attempts) * 100 * time.Millisecond)
 
 		if ctx.Err() != nil {
@@ -109,13 +115,15 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 
 // ExecContext is a helper function to execute a CUD operation with
 // logging and tracing.
-func ExecContext(ctx context.Context, db sqlx.ExtContext, query string) error {
-	return NamedExecContext(ctx, db, query, struct{}{})
+func ExecContext(ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string) error {
+	return NamedExecContext(ctx, log, db, query, struct{}{})
 }
 
 // NamedExecContext is a helper function to execute a CUD operation with
 // logging and tracing where field replacement is necessary.
-func NamedExecContext(ctx context.Context, db sqlx.ExtContext, query string, data any) (err error) {
+func NamedExecContext(ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, data any) (err error) {
+	defer logQuery(ctx, log, "database: namedexeccontext", query, data, time.Now(), &err)
+
 	if _, err := sqlx.NamedExecContext(ctx, db, query, data); err != nil {
 		var pqerr *pgconn.PgError
 		if errors.As(err, &pqerr) {
@@ -134,25 +142,27 @@ func NamedExecContext(ctx context.Context, db sqlx.ExtContext, query string, dat
 
 // QuerySlice is a helper function for executing queries that return a
 // collection of data to be unmarshalled into a slice.
-func QuerySlice[T any](ctx context.Context, db sqlx.ExtContext, query string, dest *[]T) error {
-	return namedQuerySlice(ctx, db, query, struct{}{}, dest, false)
+func QuerySlice[T any](ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, dest *[]T) error {
+	return namedQuerySlice(ctx, log, db, query, struct{}{}, dest, false)
 }
 
 // NamedQuerySlice is a helper function for executing queries that return a
 // collection of data to be unmarshalled into a slice where field replacement is
 // necessary.
-func NamedQuerySlice[T any](ctx context.Context, db sqlx.ExtContext, query string, data any, dest *[]T) error {
-	return namedQuerySlice(ctx, db, query, data, dest, false)
+func NamedQuerySlice[T any](ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, data any, dest *[]T) error {
+	return namedQuerySlice(ctx, log, db, query, data, dest, false)
 }
 
 // NamedQuerySliceUsingIn is a helper function for executing queries that return
 // a collection of data to be unmarshalled into a slice where field replacement
 // is necessary. Use this if the query has an IN clause.
-func NamedQuerySliceUsingIn[T any](ctx context.Context, db sqlx.ExtContext, query string, data any, dest *[]T) error {
-	return namedQuerySlice(ctx, db, query, data, dest, true)
+func NamedQuerySliceUsingIn[T any](ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, data any, dest *[]T) error {
+	return namedQuerySlice(ctx, log, db, query, data, dest, true)
 }
 
-func namedQuerySlice[T any](ctx context.Context, db sqlx.ExtContext, query string, data any, dest *[]T, withIn bool) (err error) {
+func namedQuerySlice[T any](ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, data any, dest *[]T, withIn bool) (err error) {
+	defer logQuery(ctx, log, "database: namedqueryslice", query, data, time.Now(), &err)
+
 	var rows *sqlx.Rows
 
 	switch withIn {
@@ -200,24 +210,26 @@ func namedQuerySlice[T any](ctx context.Context, db sqlx.ExtContext, query strin
 
 // QueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type where field replacement is necessary.
-func QueryStruct(ctx context.Context, db sqlx.ExtContext, query string, dest any) error {
-	return namedQueryStruct(ctx, db, query, struct{}{}, dest, false)
+func QueryStruct(ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, dest any) error {
+	return namedQueryStruct(ctx, log, db, query, struct{}{}, dest, false)
 }
 
 // NamedQueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type where field replacement is necessary.
-func NamedQueryStruct(ctx context.Context, db sqlx.ExtContext, query string, data any, dest any) error {
-	return namedQueryStruct(ctx, db, query, data, dest, false)
+func NamedQueryStruct(ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, data any, dest any) error {
+	return namedQueryStruct(ctx, log, db, query, data, dest, false)
 }
 
 // NamedQueryStructUsingIn is a helper function for executing queries that return
 // a single value to be unmarshalled into a struct type where field replacement
 // is necessary. Use this if the query has an IN clause.
-func NamedQueryStructUsingIn(ctx context.Context, db sqlx.ExtContext, query string, data any, dest any) error {
-	return namedQueryStruct(ctx, db, query, data, dest, true)
+func NamedQueryStructUsingIn(ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, data any, dest any) error {
+	return namedQueryStruct(ctx, log, db, query, data, dest, true)
 }
 
-func namedQueryStruct(ctx context.Context, db sqlx.ExtContext, query string, data any, dest any, withIn bool) (err error) {
+func namedQueryStruct(ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, data any, dest any, withIn bool) (err error) {
+	defer logQuery(ctx, log, "database: namedquerystruct", query, data, time.Now(), &err)
+
 	var rows *sqlx.Rows
 
 	switch withIn {
@@ -262,7 +274,9 @@ func namedQueryStruct(ctx context.Context, db sqlx.ExtContext, query string, dat
 }
 
 // QueryMap executes a query and places results into a map.
-func QueryMap(ctx context.Context, db sqlx.ExtContext, query string, dest *[]map[string]any) (err error) {
+func QueryMap(ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, dest *[]map[string]any) (err error) {
+	defer logQuery(ctx, log, "database: querymap", query, struct{}{}, time.Now(), &err)
+
 	var rows *sqlx.Rows
 
 	rows, err = sqlx.NamedQueryContext(ctx, db, query, struct{}{})
@@ -288,6 +302,21 @@ func QueryMap(ctx context.Context, db sqlx.ExtContext, query string, dest *[]map
 	return nil
 }
 
+// logQuery logs the query with its parameters and duration at the debug
+// level once it has completed. Nothing is logged when the logger is nil.
+func logQuery(ctx context.Context, log *slog.Logger, msg string, query string, data any, start time.Time, err *error) {
+	if log == nil {
+		return
+	}
+
+	args := []any{"query", queryString(query, data), "duration", time.Since(start)}
+	if *err != nil {
+		args = append(args, "ERROR", *err)
+	}
+
+	log.DebugContext(ctx, msg, args...)
+}
+
 // queryString provides a pretty print version of the query and parameters.
 func queryString(query string, args any) string {
 	query, params, err := sqlx.Named(query, args)
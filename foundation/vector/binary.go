@@ -0,0 +1,291 @@
+package vector
+
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// BinaryIndex is an in-memory vector index that searches binary codes by
+// their Hamming distance to the code of the query and rescores the closest
+// candidates with the vectors. By default the float64 vectors are kept for
+// rescoring. Use WithScalarRescoring to keep int8 codes instead, which take
+// an eighth of the memory. It's safe for concurrent use, with searches
+// running in parallel.
+type BinaryIndex struct {
+	metric  Metric
+	bq      *BinaryQuantizer
+	sq      *ScalarQuantizer
+	rescore int
+
+	mu      sync.RWMutex
+	ids     map[string]int
+	entries []binaryEntry
+}
+
+type binaryEntry struct {
+	id       string
+	code     []uint64
+	vector   []float64
+	scalar   []int8
+	norm     float64
+	metadata map[string]any
+}
+
+// NewBinaryIndex constructs an index that encodes the vectors with the
+// binary quantizer. By default the search rescores 4 times as many
+// candidates as the number of results asked for.
+func NewBinaryIndex(metric Metric, bq *BinaryQuantizer, options ...func(idx *BinaryIndex)) *BinaryIndex {
+	idx := BinaryIndex{
+		metric:  metric,
+		bq:      bq,
+		rescore: 4,
+		ids:     make(map[string]int),
+	}
+
+	for _, option := range options {
+		option(&idx)
+	}
+
+	return &idx
+}
+
+// WithRescoreFactor sets how many candidates are rescored as a multiple of
+// the number of results asked for. More candidates improve recall at the
+// cost of speed.
+func WithRescoreFactor(factor int) func(idx *BinaryIndex) {
+	return func(idx *BinaryIndex) {
+		idx.rescore = max(factor, 1)
+	}
+}
+
+// WithScalarRescoring keeps the vectors as int8 codes encoded by the scalar
+// quantizer and rescores the candidates with the decoded vectors. The scalar
+// quantizer must have the dimensions of the binary quantizer.
+func WithScalarRescoring(sq *ScalarQuantizer) func(idx *BinaryIndex) {
+	return func(idx *BinaryIndex) {
+		idx.sq = sq
+	}
+}
+
+// Len returns the number of vectors in the index.
+func (idx *BinaryIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.entries)
+}
+
+// Add adds the vector with its metadata to the index. It fails if the id
+// already exists.
+func (idx *BinaryIndex) Add(id string, vector []float64, metadata map[string]any) error {
+	e, err := idx.newEntry(id, vector, metadata)
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, exists := idx.ids[id]; exists {
+		return fmt.Errorf("add %q: %w", id, ErrDuplicateID)
+	}
+
+	idx.ids[id] = len(idx.entries)
+	idx.entries = append(idx.entries, e)
+
+	return nil
+}
+
+// Upsert adds the vector with its metadata to the index, replacing the
+// vector and metadata if the id already exists.
+func (idx *BinaryIndex) Upsert(id string, vector []float64, metadata map[string]any) error {
+	e, err := idx.newEntry(id, vector, metadata)
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if i, exists := idx.ids[id]; exists {
+		idx.entries[i] = e
+		return nil
+	}
+
+	idx.ids[id] = len(idx.entries)
+	idx.entries = append(idx.entries, e)
+
+	return nil
+}
+
+// Delete removes the vector from the index. It returns false if the id
+// doesn't exist.
+func (idx *BinaryIndex) Delete(id string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	i, exists := idx.ids[id]
+	if !exists {
+		return false
+	}
+
+	last := len(idx.entries) - 1
+
+	idx.entries[i] = idx.entries[last]
+	idx.ids[idx.entries[i].id] = i
+
+	idx.entries[last] = binaryEntry{}
+	idx.entries = idx.entries[:last]
+	delete(idx.ids, id)
+
+	return true
+}
+
+// Get returns a copy of the vector and the metadata stored for the id. When
+// the vectors are kept as int8 codes, the decoded vector is returned.
+func (idx *BinaryIndex) Get(id string) ([]float64, map[string]any, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	i, exists := idx.ids[id]
+	if !exists {
+		return nil, nil, false
+	}
+
+	e := idx.entries[i]
+
+	if idx.sq != nil {
+		vector := make([]float64, len(e.scalar))
+		idx.sq.decode(vector, e.scalar)
+		return vector, e.metadata, true
+	}
+
+	return slices.Clone(e.vector), e.metadata, true
+}
+
+// Search returns approximately the k vectors most similar to the query,
+// ordered from the most similar.
+func (idx *BinaryIndex) Search(query []float64, k int) ([]Result, error) {
+	return idx.SearchFunc(query, k, nil)
+}
+
+// SearchFunc returns approximately the k vectors most similar to the query
+// that are accepted by the filter, ordered from the most similar. A nil
+// filter accepts every vector. The scores are computed with the vectors
+// kept for rescoring.
+func (idx *BinaryIndex) SearchFunc(query []float64, k int, filter func(id string, metadata map[string]any) bool) ([]Result, error) {
+	code, err := idx.bq.Encode(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if k <= 0 {
+		return nil, nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Find the candidates with the smallest Hamming distance, using the
+	// negated distance as the score so the top k heap can be reused.
+
+	n := k * idx.rescore
+	candidates := make(topK, 0, min(n, len(idx.entries)))
+
+	for _, e := range idx.entries {
+		if filter != nil && !filter(e.id, e.metadata) {
+			continue
+		}
+
+		r := Result{
+			ID:    e.id,
+			Score: -float64(Hamming(code, e.code)),
+		}
+
+		switch {
+		case len(candidates) < n:
+			heap.Push(&candidates, r)
+
+		case candidates.less(candidates[0], r):
+			candidates[0] = r
+			heap.Fix(&candidates, 0)
+		}
+	}
+
+	// Rescore the candidates with the vectors.
+
+	qNorm := norm(query)
+
+	var decoded []float64
+	if idx.sq != nil {
+		decoded = make([]float64, len(query))
+	}
+
+	top := make(topK, 0, min(k, len(candidates)))
+
+	for _, c := range candidates {
+		e := idx.entries[idx.ids[c.ID]]
+
+		vector := e.vector
+		if idx.sq != nil {
+			idx.sq.decode(decoded, e.scalar)
+			vector = decoded
+		}
+
+		r := Result{
+			ID:       e.id,
+			Score:    score(idx.metric, query, qNorm, vector, e.norm),
+			Metadata: e.metadata,
+		}
+
+		switch {
+		case len(top) < k:
+			heap.Push(&top, r)
+
+		case top.less(top[0], r):
+			top[0] = r
+			heap.Fix(&top, 0)
+		}
+	}
+
+	results := make([]Result, len(top))
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = heap.Pop(&top).(Result)
+	}
+
+	return results, nil
+}
+
+// newEntry encodes the vector, keeping the vector or its int8 code for
+// rescoring.
+func (idx *BinaryIndex) newEntry(id string, vector []float64, metadata map[string]any) (binaryEntry, error) {
+	code, err := idx.bq.Encode(vector)
+	if err != nil {
+		return binaryEntry{}, err
+	}
+
+	e := binaryEntry{
+		id:       id,
+		code:     code,
+		metadata: metadata,
+	}
+
+	switch idx.sq {
+	case nil:
+		e.vector = slices.Clone(vector)
+		e.norm = norm(vector)
+
+	default:
+		if e.scalar, err = idx.sq.Encode(vector); err != nil {
+			return binaryEntry{}, err
+		}
+
+		decoded := make([]float64, len(vector))
+		idx.sq.decode(decoded, e.scalar)
+		e.norm = norm(decoded)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,84 @@
+package vector
+
+import (
+	"testing"
+)
+
+func TestBinaryIndexRecall(t *testing.T) {
+	const dimensions = 128
+
+	centers := randomVectors(20, dimensions, 1)
+	vectors := clusteredVectors(centers, 2000, 0.5, 2)
+	queries := clusteredVectors(centers, 50, 0.5, 3)
+
+	exact := NewIndex(Cosine, dimensions)
+	fill(t, exact, vectors)
+
+	bq, err := CalibrateBinary(vectors)
+	if err != nil {
+		t.Fatalf("calibrating binary: %s", err)
+	}
+
+	sq, err := CalibrateScalar(vectors)
+	if err != nil {
+		t.Fatalf("calibrating scalar: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		idx     *BinaryIndex
+		minimum float64
+	}{
+		{name: "vectors", idx: NewBinaryIndex(Cosine, bq, WithRescoreFactor(10)), minimum: 0.9},
+		{name: "scalar", idx: NewBinaryIndex(Cosine, bq, WithRescoreFactor(10), WithScalarRescoring(sq)), minimum: 0.9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fill(t, tt.idx, vectors)
+
+			recall, err := Recall(exact, tt.idx, queries, 10)
+			if err != nil {
+				t.Fatalf("measuring recall: %s", err)
+			}
+
+			if recall < tt.minimum {
+				t.Errorf("got recall %.3f, expected at least %.2f", recall, tt.minimum)
+			}
+
+			t.Logf("recall %.3f", recall)
+		})
+	}
+}
+
+func TestBinaryIndexRescoreFactor(t *testing.T) {
+	const dimensions = 64
+
+	vectors := randomVectors(1000, dimensions, 1)
+	queries := randomVectors(50, dimensions, 2)
+
+	exact := NewIndex(Cosine, dimensions)
+	fill(t, exact, vectors)
+
+	bq := NewBinaryQuantizer(dimensions)
+
+	// Rescoring more candidates can only find more of the exact results.
+
+	var last float64
+
+	for _, factor := range []int{1, 4, 16} {
+		idx := NewBinaryIndex(Cosine, bq, WithRescoreFactor(factor))
+		fill(t, idx, vectors)
+
+		recall, err := Recall(exact, idx, queries, 10)
+		if err != nil {
+			t.Fatalf("measuring recall: %s", err)
+		}
+
+		if recall < last {
+			t.Errorf("factor %d: got recall %.3f, expected at least %.3f", factor, recall, last)
+		}
+
+		last = recall
+	}
+}
@@ -0,0 +1,723 @@
+package vector
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sync"
+)
+
+// ErrCorruptIndex is returned when a saved index doesn't describe a valid
+// graph.
+var ErrCorruptIndex = errors.New("corrupt index")
+
+// HNSW is an approximate vector index using a Hierarchical Navigable Small
+// World graph. Searches visit a small part of the graph instead of every
+// vector, trading some recall for speed on large sets of vectors. Deleted
+// vectors are marked with a tombstone and stay in the graph to keep it
+// connected. It's safe for concurrent use, with searches running in
+// parallel and inserts running one at a time.
+//
+// The M option sets the number of neighbors per node, efConstruction the
+// size of the candidate list used while inserting and efSearch the size of
+// the candidate list used while searching. Higher values improve recall at
+// the cost of memory and speed.
+type HNSW struct {
+	metric         Metric
+	dimensions     int
+	m              int
+	mMax0          int
+	efConstruction int
+	efSearch       int
+	ml             float64
+
+	mu       sync.RWMutex
+	rng      *rand.Rand
+	nodes    []*hnswNode
+	ids      map[string]int32
+	entry    int32
+	maxLevel int
+}
+
+type hnswNode struct {
+	id        string
+	vector    []float64
+	norm      float64
+	metadata  map[string]any
+	neighbors [][]int32
+	deleted   bool
+}
+
+// NewHNSW constructs an approximate index for vectors of the specified
+// dimensions. The defaults are an M of 16, an efConstruction of 200 and an
+// efSearch of 50.
+func NewHNSW(metric Metric, dimensions int, options ...func(h *HNSW)) *HNSW {
+	h := HNSW{
+		metric:         metric,
+		dimensions:     dimensions,
+		m:              16,
+		efConstruction: 200,
+		efSearch:       50,
+		rng:            rand.New(rand.NewPCG(1, 2)),
+		ids:            make(map[string]int32),
+		entry:          -1,
+	}
+
+	for _, option := range options {
+		option(&h)
+	}
+
+	h.mMax0 = 2 * h.m
+	h.ml = 1 / math.Log(float64(h.m))
+
+	return &h
+}
+
+// WithM sets the number of neighbors of each node in the graph. The nodes
+// on the bottom layer have twice as many.
+func WithM(m int) func(h *HNSW) {
+	return func(h *HNSW) {
+		h.m = max(m, 2)
+	}
+}
+
+// WithEfConstruction sets the size of the candidate list used to find the
+// neighbors of an inserted vector.
+func WithEfConstruction(ef int) func(h *HNSW) {
+	return func(h *HNSW) {
+		h.efConstruction = max(ef, 1)
+	}
+}
+
+// WithEfSearch sets the size of the candidate list used by searches. The
+// list is never smaller than the number of results asked for.
+func WithEfSearch(ef int) func(h *HNSW) {
+	return func(h *HNSW) {
+		h.efSearch = max(ef, 1)
+	}
+}
+
+// WithSeed sets the seed used to assign the layers of the inserted vectors,
+// so the same inserts always build the same graph.
+func WithSeed(seed uint64) func(h *HNSW) {
+	return func(h *HNSW) {
+		h.rng = rand.New(rand.NewPCG(seed, seed))
+	}
+}
+
+// SetEfSearch changes the size of the candidate list used by searches.
+func (h *HNSW) SetEfSearch(ef int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.efSearch = max(ef, 1)
+}
+
+// Len returns the number of vectors in the index, not counting the deleted
+// vectors.
+func (h *HNSW) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.ids)
+}
+
+// Add adds the vector with its metadata to the index. The vector is copied.
+// It fails if the id already exists.
+func (h *HNSW) Add(id string, vector []float64, metadata map[string]any) error {
+	if err := h.check(vector); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.ids[id]; exists {
+		return fmt.Errorf("add %q: %w", id, ErrDuplicateID)
+	}
+
+	h.insert(id, vector, metadata)
+
+	return nil
+}
+
+// Upsert adds the vector with its metadata to the index. If the id already
+// exists, the existing vector is deleted and the new vector is inserted.
+func (h *HNSW) Upsert(id string, vector []float64, metadata map[string]any) error {
+	if err := h.check(vector); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n, exists := h.ids[id]; exists {
+		h.nodes[n].deleted = true
+	}
+
+	h.insert(id, vector, metadata)
+
+	return nil
+}
+
+// Delete marks the vector as deleted so searches no longer return it. It
+// returns false if the id doesn't exist.
+func (h *HNSW) Delete(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, exists := h.ids[id]
+	if !exists {
+		return false
+	}
+
+	h.nodes[n].deleted = true
+	delete(h.ids, id)
+
+	return true
+}
+
+// Get returns a copy of the vector and the metadata stored for the id.
+func (h *HNSW) Get(id string) ([]float64, map[string]any, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	n, exists := h.ids[id]
+	if !exists {
+		return nil, nil, false
+	}
+
+	node := h.nodes[n]
+
+	return slices.Clone(node.vector), node.metadata, true
+}
+
+// Search returns approximately the k vectors most similar to the query,
+// ordered from the most similar.
+func (h *HNSW) Search(query []float64, k int) ([]Result, error) {
+	return h.SearchFunc(query, k, nil)
+}
+
+// SearchFunc returns approximately the k vectors most similar to the query
+// that are accepted by the filter, ordered from the most similar. A nil
+// filter accepts every vector. A selective filter makes the search visit
+// more of the graph.
+func (h *HNSW) SearchFunc(query []float64, k int, filter func(id string, metadata map[string]any) bool) ([]Result, error) {
+	if err := h.check(query); err != nil {
+		return nil, err
+	}
+
+	if k <= 0 {
+		return nil, nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.entry == -1 {
+		return nil, nil
+	}
+
+	q := h.newQuery(query)
+
+	ep := []candidate{{node: h.entry, score: q.score(h.nodes[h.entry])}}
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.searchLayer(q, ep, 1, l, nil)
+	}
+
+	accept := func(n *hnswNode) bool {
+		return !n.deleted && (filter == nil || filter(n.id, n.metadata))
+	}
+
+	found := h.searchLayer(q, ep, max(h.efSearch, k), 0, accept)
+
+	results := make([]Result, 0, min(k, len(found)))
+	for _, c := range found[:min(k, len(found))] {
+		n := h.nodes[c.node]
+		results = append(results, Result{
+			ID:       n.id,
+			Score:    c.score,
+			Metadata: n.metadata,
+		})
+	}
+
+	return results, nil
+}
+
+// Save writes the index to the writer so it can be loaded with LoadHNSW.
+// The metadata is encoded with encoding/gob, so metadata values of custom
+// types need to be registered with gob.Register.
+func (h *HNSW) Save(w io.Writer) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	snap := hnswSnapshot{
+		Version:        1,
+		Metric:         h.metric,
+		Dimensions:     h.dimensions,
+		M:              h.m,
+		EfConstruction: h.efConstruction,
+		EfSearch:       h.efSearch,
+		Entry:          h.entry,
+		MaxLevel:       h.maxLevel,
+		Nodes:          make([]hnswNodeSnapshot, len(h.nodes)),
+	}
+
+	for i, n := range h.nodes {
+		snap.Nodes[i] = hnswNodeSnapshot{
+			ID:        n.id,
+			Vector:    n.vector,
+			Metadata:  n.metadata,
+			Neighbors: n.neighbors,
+			Deleted:   n.deleted,
+		}
+	}
+
+	if err := gob.NewEncoder(w).Encode(snap); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	return nil
+}
+
+// SaveFile writes the index to the file at the path.
+func (h *HNSW) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	if err := h.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// LoadHNSW reads an index written by Save.
+func LoadHNSW(r io.Reader) (*HNSW, error) {
+	var snap hnswSnapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	if snap.Version != 1 {
+		return nil, fmt.Errorf("unsupported version %d", snap.Version)
+	}
+
+	if err := snap.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruptIndex, err)
+	}
+
+	h := NewHNSW(snap.Metric, snap.Dimensions, WithM(snap.M), WithEfConstruction(snap.EfConstruction), WithEfSearch(snap.EfSearch))
+	h.entry = snap.Entry
+	h.maxLevel = snap.MaxLevel
+	h.nodes = make([]*hnswNode, len(snap.Nodes))
+
+	for i, sn := range snap.Nodes {
+		if len(sn.Vector) != h.dimensions {
+			return nil, fmt.Errorf("node %q: got %d dimensions, index has %d: %w", sn.ID, len(sn.Vector), h.dimensions, ErrDimensionMismatch)
+		}
+
+		h.nodes[i] = &hnswNode{
+			id:        sn.ID,
+			vector:    sn.Vector,
+			norm:      norm(sn.Vector),
+			metadata:  sn.Metadata,
+			neighbors: sn.Neighbors,
+			deleted:   sn.Deleted,
+		}
+
+		if !sn.Deleted {
+			if _, exists := h.ids[sn.ID]; exists {
+				return nil, fmt.Errorf("%w: node %q: %w", ErrCorruptIndex, sn.ID, ErrDuplicateID)
+			}
+
+			h.ids[sn.ID] = int32(i)
+		}
+	}
+
+	return h, nil
+}
+
+// LoadHNSWFile reads an index from the file at the path.
+func LoadHNSWFile(path string) (*HNSW, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	return LoadHNSW(f)
+}
+
+// Recall measures how many of the k vectors found by the exact searcher
+// are also found by the approximate searcher, averaged over the queries. A
+// recall of 1 means the approximate searcher found every vector.
+func Recall(exact Searcher, approx Searcher, queries [][]float64, k int) (float64, error) {
+	if len(queries) == 0 || k <= 0 {
+		return 0, nil
+	}
+
+	var total float64
+
+	for i, q := range queries {
+		want, err := exact.Search(q, k)
+		if err != nil {
+			return 0, fmt.Errorf("exact search: query[%d]: %w", i, err)
+		}
+
+		if len(want) == 0 {
+			total++
+			continue
+		}
+
+		got, err := approx.Search(q, k)
+		if err != nil {
+			return 0, fmt.Errorf("approx search: query[%d]: %w", i, err)
+		}
+
+		found := make(map[string]struct{}, len(got))
+		for _, r := range got {
+			found[r.ID] = struct{}{}
+		}
+
+		var hits int
+		for _, r := range want {
+			if _, exists := found[r.ID]; exists {
+				hits++
+			}
+		}
+
+		total += float64(hits) / float64(len(want))
+	}
+
+	return total / float64(len(queries)), nil
+}
+
+// =============================================================================
+
+type hnswSnapshot struct {
+	Version        int
+	Metric         Metric
+	Dimensions     int
+	M              int
+	EfConstruction int
+	EfSearch       int
+	Entry          int32
+	MaxLevel       int
+	Nodes          []hnswNodeSnapshot
+}
+
+type hnswNodeSnapshot struct {
+	ID        string
+	Vector    []float64
+	Metadata  map[string]any
+	Neighbors [][]int32
+	Deleted   bool
+}
+
+// validate verifies the graph only references nodes that exist on the
+// layers they're linked on, so a corrupt snapshot can't make a search index
+// out of range.
+func (snap hnswSnapshot) validate() error {
+	if len(snap.Nodes) == 0 {
+		if snap.Entry != -1 {
+			return fmt.Errorf("entry point %d in an empty graph", snap.Entry)
+		}
+
+		return nil
+	}
+
+	if snap.Entry < 0 || int(snap.Entry) >= len(snap.Nodes) {
+		return fmt.Errorf("entry point %d out of range for %d nodes", snap.Entry, len(snap.Nodes))
+	}
+
+	if snap.MaxLevel < 0 || len(snap.Nodes[snap.Entry].Neighbors) != snap.MaxLevel+1 {
+		return fmt.Errorf("entry point has %d layers, max level is %d", len(snap.Nodes[snap.Entry].Neighbors), snap.MaxLevel)
+	}
+
+	for _, sn := range snap.Nodes {
+		if len(sn.Neighbors) == 0 || len(sn.Neighbors) > snap.MaxLevel+1 {
+			return fmt.Errorf("node %q: has %d layers, max level is %d", sn.ID, len(sn.Neighbors), snap.MaxLevel)
+		}
+
+		for l, neighbors := range sn.Neighbors {
+			for _, nb := range neighbors {
+				if nb < 0 || int(nb) >= len(snap.Nodes) {
+					return fmt.Errorf("node %q: layer %d: neighbor %d out of range for %d nodes", sn.ID, l, nb, len(snap.Nodes))
+				}
+
+				if len(snap.Nodes[nb].Neighbors) <= l {
+					return fmt.Errorf("node %q: layer %d: neighbor %d has %d layers", sn.ID, l, nb, len(snap.Nodes[nb].Neighbors))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// insert adds a node for the vector and connects it to the graph. The
+// caller must hold the write lock.
+func (h *HNSW) insert(id string, vector []float64, metadata map[string]any) {
+	level := int(-math.Log(1-h.rng.Float64()) * h.ml)
+
+	node := hnswNode{
+		id:        id,
+		vector:    slices.Clone(vector),
+		norm:      norm(vector),
+		metadata:  metadata,
+		neighbors: make([][]int32, level+1),
+	}
+
+	n := int32(len(h.nodes))
+	h.nodes = append(h.nodes, &node)
+	h.ids[id] = n
+
+	if h.entry == -1 {
+		h.entry = n
+		h.maxLevel = level
+		return
+	}
+
+	q := h.newQuery(node.vector)
+
+	ep := []candidate{{node: h.entry, score: q.score(h.nodes[h.entry])}}
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.searchLayer(q, ep, 1, l, nil)
+	}
+
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(q, ep, h.efConstruction, l, nil)
+
+		node.neighbors[l] = h.selectNeighbors(found, h.m)
+
+		for _, nb := range node.neighbors[l] {
+			h.connect(nb, n, l)
+		}
+
+		ep = found
+	}
+
+	if level > h.maxLevel {
+		h.entry = n
+		h.maxLevel = level
+	}
+}
+
+// connect adds the node to the neighbors of the neighbor on the layer,
+// pruning the neighbors when there are too many.
+func (h *HNSW) connect(neighbor int32, node int32, level int) {
+	nb := h.nodes[neighbor]
+	nb.neighbors[level] = append(nb.neighbors[level], node)
+
+	limit := h.m
+	if level == 0 {
+		limit = h.mMax0
+	}
+
+	if len(nb.neighbors[level]) <= limit {
+		return
+	}
+
+	q := h.newQuery(nb.vector)
+
+	candidates := make([]candidate, len(nb.neighbors[level]))
+	for i, c := range nb.neighbors[level] {
+		candidates[i] = candidate{node: c, score: q.score(h.nodes[c])}
+	}
+
+	sortCandidates(candidates)
+
+	nb.neighbors[level] = h.selectNeighbors(candidates, limit)
+}
+
+// selectNeighbors picks up to m neighbors from the candidates, which must be
+// sorted from the most similar. A candidate is skipped when it's more similar
+// to an already selected neighbor than to the node, which keeps links to
+// different regions of the graph. Skipped candidates fill any remaining room.
+func (h *HNSW) selectNeighbors(candidates []candidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+
+		cq := h.newQuery(h.nodes[c.node].vector)
+
+		keep := true
+		for _, s := range selected {
+			if cq.score(h.nodes[s]) > c.score {
+				keep = false
+				break
+			}
+		}
+
+		switch keep {
+		case true:
+			selected = append(selected, c.node)
+		default:
+			skipped = append(skipped, c.node)
+		}
+	}
+
+	for _, s := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, s)
+	}
+
+	return selected
+}
+
+// searchLayer finds the ef nodes most similar to the query on the layer,
+// starting from the entry points, and returns them sorted from the most
+// similar. Only the nodes accepted by accept are returned, but every node
+// is used to move through the graph.
+func (h *HNSW) searchLayer(q query, entryPoints []candidate, ef int, level int, accept func(n *hnswNode) bool) []candidate {
+	visited := make(map[int32]struct{}, ef*4)
+
+	candidates := candidateHeap{max: true}
+	results := candidateHeap{}
+
+	for _, ep := range entryPoints {
+		visited[ep.node] = struct{}{}
+		heap.Push(&candidates, ep)
+
+		if accept == nil || accept(h.nodes[ep.node]) {
+			heap.Push(&results, ep)
+			if results.Len() > ef {
+				heap.Pop(&results)
+			}
+		}
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(&candidates).(candidate)
+
+		if results.Len() >= ef && c.score < results.items[0].score {
+			break
+		}
+
+		neighbors := h.nodes[c.node].neighbors
+		if level >= len(neighbors) {
+			continue
+		}
+
+		for _, nb := range neighbors[level] {
+			if _, seen := visited[nb]; seen {
+				continue
+			}
+			visited[nb] = struct{}{}
+
+			node := h.nodes[nb]
+			s := q.score(node)
+
+			if results.Len() < ef || s > results.items[0].score {
+				heap.Push(&candidates, candidate{node: nb, score: s})
+
+				if accept == nil || accept(node) {
+					heap.Push(&results, candidate{node: nb, score: s})
+					if results.Len() > ef {
+						heap.Pop(&results)
+					}
+				}
+			}
+		}
+	}
+
+	found := results.items
+	sortCandidates(found)
+
+	return found
+}
+
+// check verifies the vector has the dimensions of the index.
+func (h *HNSW) check(vector []float64) error {
+	if len(vector) != h.dimensions {
+		return fmt.Errorf("got %d dimensions, index has %d: %w", len(vector), h.dimensions, ErrDimensionMismatch)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// query holds a vector being compared to the nodes with its norm computed
+// once.
+type query struct {
+	metric Metric
+	vector []float64
+	norm   float64
+}
+
+func (h *HNSW) newQuery(vector []float64) query {
+	return query{
+		metric: h.metric,
+		vector: vector,
+		norm:   norm(vector),
+	}
+}
+
+func (q query) score(n *hnswNode) float64 {
+	return score(q.metric, q.vector, q.norm, n.vector, n.norm)
+}
+
+// candidate is a node found by a search with its score.
+type candidate struct {
+	node  int32
+	score float64
+}
+
+// sortCandidates sorts the candidates from the most similar.
+func sortCandidates(candidates []candidate) {
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return int(a.node - b.node)
+	})
+}
+
+// candidateHeap is a heap of candidates with the most similar candidate at
+// the root when max is set, otherwise the least similar.
+type candidateHeap struct {
+	items []candidate
+	max   bool
+}
+
+func (h candidateHeap) Len() int { return len(h.items) }
+
+func (h candidateHeap) Less(i, j int) bool {
+	if h.max {
+		return h.items[i].score > h.items[j].score
+	}
+	return h.items[i].score < h.items[j].score
+}
+
+func (h candidateHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x any)   { h.items = append(h.items, x.(candidate)) }
+
+func (h *candidateHeap) Pop() any {
+	n := len(h.items)
+	c := h.items[n-1]
+	h.items = h.items[:n-1]
+
+	return c
+}
@@ -0,0 +1,305 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestHNSWRecall(t *testing.T) {
+	vectors := randomVectors(2000, 32, 1)
+	queries := randomVectors(50, 32, 2)
+
+	exact := NewIndex(Cosine, 32)
+	approx := NewHNSW(Cosine, 32, WithSeed(1), WithEfSearch(100))
+	fill(t, exact, vectors)
+	fill(t, approx, vectors)
+
+	recall, err := Recall(exact, approx, queries, 10)
+	if err != nil {
+		t.Fatalf("measuring recall: %s", err)
+	}
+
+	if recall < 0.9 {
+		t.Errorf("got recall %.3f, expected at least 0.9", recall)
+	}
+}
+
+func TestHNSWTombstones(t *testing.T) {
+	vectors := randomVectors(500, 16, 1)
+
+	h := NewHNSW(Euclidean, 16, WithSeed(1))
+	fill(t, h, vectors)
+
+	deleted := make(map[string]bool)
+	for i := 0; i < len(vectors); i += 3 {
+		id := fmt.Sprint(i)
+		if !h.Delete(id) {
+			t.Fatalf("deleting %s: not found", id)
+		}
+		deleted[id] = true
+	}
+
+	if h.Delete("0") {
+		t.Errorf("deleting 0 twice: expected false")
+	}
+
+	if got, expected := h.Len(), len(vectors)-len(deleted); got != expected {
+		t.Errorf("got len %d, expected %d", got, expected)
+	}
+
+	if _, _, exists := h.Get("0"); exists {
+		t.Errorf("get 0: expected the deleted vector to be missing")
+	}
+
+	// Searching for a deleted vector must return its live neighbors and
+	// never the vector itself.
+
+	for i := 0; i < 30; i += 3 {
+		results, err := h.Search(vectors[i], 10)
+		if err != nil {
+			t.Fatalf("searching: %s", err)
+		}
+
+		if len(results) != 10 {
+			t.Fatalf("got %d results, expected 10", len(results))
+		}
+
+		for _, r := range results {
+			if deleted[r.ID] {
+				t.Fatalf("search %d: got deleted vector %s", i, r.ID)
+			}
+		}
+	}
+
+	// A deleted id can be added again and an upserted id is only returned
+	// with its new vector.
+
+	if err := h.Add("0", vectors[0], nil); err != nil {
+		t.Fatalf("adding a deleted id: %s", err)
+	}
+
+	if err := h.Upsert("1", vectors[0], map[string]any{"upserted": true}); err != nil {
+		t.Fatalf("upserting: %s", err)
+	}
+
+	results, err := h.Search(vectors[0], 3)
+	if err != nil {
+		t.Fatalf("searching: %s", err)
+	}
+
+	ids := []string{results[0].ID, results[1].ID}
+	slices.Sort(ids)
+
+	if !slices.Equal(ids, []string{"0", "1"}) || results[2].ID == "1" {
+		t.Errorf("got results %v, expected 0 and 1 once each first", results)
+	}
+}
+
+func TestHNSWSaveLoad(t *testing.T) {
+	vectors := randomVectors(300, 8, 1)
+
+	h := NewHNSW(DotProduct, 8, WithSeed(1), WithM(8), WithEfSearch(40))
+	for i, v := range vectors {
+		if err := h.Add(fmt.Sprint(i), v, map[string]any{"n": i}); err != nil {
+			t.Fatalf("adding: %s", err)
+		}
+	}
+	h.Delete("7")
+
+	var buf bytes.Buffer
+	if err := h.Save(&buf); err != nil {
+		t.Fatalf("saving: %s", err)
+	}
+
+	loaded, err := LoadHNSW(&buf)
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+
+	if loaded.Len() != h.Len() {
+		t.Errorf("got len %d, expected %d", loaded.Len(), h.Len())
+	}
+
+	if _, _, exists := loaded.Get("7"); exists {
+		t.Errorf("get 7: expected the deleted vector to stay deleted")
+	}
+
+	v, metadata, exists := loaded.Get("42")
+	if !exists || !slices.Equal(v, vectors[42]) || metadata["n"] != 42 {
+		t.Errorf("get 42: got %v %v %t, expected the saved vector and metadata", v, metadata, exists)
+	}
+
+	for i, q := range randomVectors(20, 8, 2) {
+		want, err := h.Search(q, 5)
+		if err != nil {
+			t.Fatalf("searching: %s", err)
+		}
+
+		got, err := loaded.Search(q, 5)
+		if err != nil {
+			t.Fatalf("searching the loaded index: %s", err)
+		}
+
+		if !slices.EqualFunc(got, want, func(a, b Result) bool { return a.ID == b.ID && a.Score == b.Score }) {
+			t.Errorf("query %d: got %v, expected %v", i, got, want)
+		}
+	}
+
+	if err := loaded.Add("new", vectors[0], nil); err != nil {
+		t.Errorf("adding to the loaded index: %s", err)
+	}
+}
+
+func TestLoadHNSWCorrupt(t *testing.T) {
+	h := NewHNSW(Cosine, 4, WithSeed(1))
+	fill(t, h, randomVectors(50, 4, 1))
+
+	tests := []struct {
+		name    string
+		corrupt func(snap *hnswSnapshot)
+	}{
+		{name: "entry out of range", corrupt: func(snap *hnswSnapshot) { snap.Entry = int32(len(snap.Nodes)) }},
+		{name: "negative entry", corrupt: func(snap *hnswSnapshot) { snap.Entry = -1 }},
+		{name: "entry in empty graph", corrupt: func(snap *hnswSnapshot) { snap.Nodes = nil; snap.Entry = 0 }},
+		{name: "max level too high", corrupt: func(snap *hnswSnapshot) { snap.MaxLevel++ }},
+		{name: "neighbor out of range", corrupt: func(snap *hnswSnapshot) { snap.Nodes[3].Neighbors[0][0] = 1000 }},
+		{name: "negative neighbor", corrupt: func(snap *hnswSnapshot) { snap.Nodes[3].Neighbors[0][0] = -1 }},
+		{name: "node without layers", corrupt: func(snap *hnswSnapshot) { snap.Nodes[3].Neighbors = nil }},
+		{name: "duplicate id", corrupt: func(snap *hnswSnapshot) { snap.Nodes[3].ID = snap.Nodes[4].ID }},
+		{name: "neighbor missing the layer", corrupt: func(snap *hnswSnapshot) {
+			entry := snap.Nodes[snap.Entry]
+			for i, sn := range snap.Nodes {
+				if len(sn.Neighbors) == 1 {
+					entry.Neighbors[snap.MaxLevel] = append(entry.Neighbors[snap.MaxLevel], int32(i))
+					return
+				}
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap := snapshot(t, h)
+			tt.corrupt(&snap)
+
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+				t.Fatalf("encoding: %s", err)
+			}
+
+			if _, err := LoadHNSW(&buf); !errors.Is(err, ErrCorruptIndex) {
+				t.Errorf("got error %v, expected %v", err, ErrCorruptIndex)
+			}
+		})
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	const dimensions = 64
+
+	// Embeddings form clusters of similar texts, which is what makes the
+	// graph efficient, so the vectors are spread around random centers.
+	centers := randomVectors(100, dimensions, 1)
+	vectors := clusteredVectors(centers, 10_000, 0.3, 2)
+	queries := clusteredVectors(centers, 100, 0.3, 3)
+
+	exact := NewIndex(Cosine, dimensions)
+	fill(b, exact, vectors)
+
+	approx := NewHNSW(Cosine, dimensions, WithSeed(1))
+	fill(b, approx, vectors)
+
+	for _, bb := range []struct {
+		name string
+		idx  Searcher
+	}{
+		{name: "Index", idx: exact},
+		{name: "HNSW", idx: approx},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			recall, err := Recall(exact, bb.idx, queries, 10)
+			if err != nil {
+				b.Fatalf("measuring recall: %s", err)
+			}
+
+			var i int
+			for b.Loop() {
+				if _, err := bb.idx.Search(queries[i%len(queries)], 10); err != nil {
+					b.Fatalf("searching: %s", err)
+				}
+				i++
+			}
+
+			b.ReportMetric(recall, "recall")
+		})
+	}
+}
+
+// =============================================================================
+
+// randomVectors returns n vectors with values between -1 and 1, which are
+// the same for the same seed.
+func randomVectors(n int, dimensions int, seed uint64) [][]float64 {
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	vectors := make([][]float64, n)
+	for i := range vectors {
+		vectors[i] = make([]float64, dimensions)
+		for d := range vectors[i] {
+			vectors[i][d] = rng.Float64()*2 - 1
+		}
+	}
+
+	return vectors
+}
+
+// clusteredVectors returns n vectors spread around the centers by up to the
+// spread in every dimension, which are the same for the same seed.
+func clusteredVectors(centers [][]float64, n int, spread float64, seed uint64) [][]float64 {
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	vectors := make([][]float64, n)
+	for i := range vectors {
+		c := centers[rng.IntN(len(centers))]
+
+		vectors[i] = make([]float64, len(c))
+		for d := range vectors[i] {
+			vectors[i][d] = c[d] + (rng.Float64()*2-1)*spread
+		}
+	}
+
+	return vectors
+}
+
+// fill adds the vectors to the index using their position as the id.
+func fill(tb testing.TB, idx Searcher, vectors [][]float64) {
+	tb.Helper()
+
+	for i, v := range vectors {
+		if err := idx.Add(fmt.Sprint(i), v, nil); err != nil {
+			tb.Fatalf("adding %d: %s", i, err)
+		}
+	}
+}
+
+// snapshot saves the index and decodes the snapshot so it can be modified.
+func snapshot(t *testing.T, h *HNSW) hnswSnapshot {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := h.Save(&buf); err != nil {
+		t.Fatalf("saving: %s", err)
+	}
+
+	var snap hnswSnapshot
+	if err := gob.NewDecoder(&buf).Decode(&snap); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	return snap
+}
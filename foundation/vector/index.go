@@ -0,0 +1,324 @@
+package vector
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Set of errors returned by the index.
+var (
+	ErrDimensionMismatch = errors.New("vector dimensions don't match")
+	ErrDuplicateID       = errors.New("id already exists")
+)
+
+// Metric represents the way vectors are compared.
+type Metric int
+
+// Set of metrics supported by the index.
+const (
+	// Cosine scores vectors by the cosine of the angle between them.
+	Cosine Metric = iota
+
+	// DotProduct scores vectors by their dot product, which is the same as
+	// cosine for normalized vectors but cheaper to compute.
+	DotProduct
+
+	// Euclidean scores vectors by the negated straight line distance
+	// between them, so a higher score is still more similar.
+	Euclidean
+
+	// Manhattan scores vectors by the negated sum of the absolute
+	// differences between them, which is less affected by a single large
+	// difference than Euclidean.
+	Manhattan
+)
+
+// String implements the fmt.Stringer interface.
+func (m Metric) String() string {
+	switch m {
+	case Cosine:
+		return "cosine"
+	case DotProduct:
+		return "dot"
+	case Euclidean:
+		return "euclidean"
+	case Manhattan:
+		return "manhattan"
+	}
+
+	return fmt.Sprintf("Metric(%d)", int(m))
+}
+
+// Result represents a vector found by a search. A higher score is more
+// similar to the query.
+type Result struct {
+	ID       string
+	Score    float64
+	Metadata map[string]any
+}
+
+// Searcher represents a vector index that can find the vectors most similar
+// to a query. It's implemented by the exact Index and the approximate HNSW
+// index so they can be used interchangeably.
+type Searcher interface {
+	Len() int
+	Add(id string, vector []float64, metadata map[string]any) error
+	Upsert(id string, vector []float64, metadata map[string]any) error
+	Delete(id string) bool
+	Get(id string) ([]float64, map[string]any, bool)
+	Search(query []float64, k int) ([]Result, error)
+	SearchFunc(query []float64, k int, filter func(id string, metadata map[string]any) bool) ([]Result, error)
+}
+
+// =============================================================================
+
+// Index is an in-memory vector index that finds the vectors most similar to
+// a query by comparing the query to every vector. It's safe for concurrent
+// use, with searches running in parallel.
+type Index struct {
+	metric     Metric
+	dimensions int
+
+	mu      sync.RWMutex
+	ids     map[string]int
+	entries []entry
+}
+
+type entry struct {
+	id       string
+	vector   []float64
+	norm     float64
+	metadata map[string]any
+}
+
+// NewIndex constructs an index for vectors of the specified dimensions.
+func NewIndex(metric Metric, dimensions int) *Index {
+	return &Index{
+		metric:     metric,
+		dimensions: dimensions,
+		ids:        make(map[string]int),
+	}
+}
+
+// Len returns the number of vectors in the index.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.entries)
+}
+
+// Add adds the vector with its metadata to the index. The vector is copied.
+// It fails if the id already exists.
+func (idx *Index) Add(id string, vector []float64, metadata map[string]any) error {
+	if err := idx.check(vector); err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, exists := idx.ids[id]; exists {
+		return fmt.Errorf("add %q: %w", id, ErrDuplicateID)
+	}
+
+	idx.ids[id] = len(idx.entries)
+	idx.entries = append(idx.entries, newEntry(id, vector, metadata))
+
+	return nil
+}
+
+// Upsert adds the vector with its metadata to the index, replacing the
+// vector and metadata if the id already exists.
+func (idx *Index) Upsert(id string, vector []float64, metadata map[string]any) error {
+	if err := idx.check(vector); err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if i, exists := idx.ids[id]; exists {
+		idx.entries[i] = newEntry(id, vector, metadata)
+		return nil
+	}
+
+	idx.ids[id] = len(idx.entries)
+	idx.entries = append(idx.entries, newEntry(id, vector, metadata))
+
+	return nil
+}
+
+// Delete removes the vector from the index. It returns false if the id
+// doesn't exist.
+func (idx *Index) Delete(id string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	i, exists := idx.ids[id]
+	if !exists {
+		return false
+	}
+
+	last := len(idx.entries) - 1
+
+	idx.entries[i] = idx.entries[last]
+	idx.ids[idx.entries[i].id] = i
+
+	idx.entries[last] = entry{}
+	idx.entries = idx.entries[:last]
+	delete(idx.ids, id)
+
+	return true
+}
+
+// Get returns a copy of the vector and the metadata stored for the id.
+func (idx *Index) Get(id string) ([]float64, map[string]any, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	i, exists := idx.ids[id]
+	if !exists {
+		return nil, nil, false
+	}
+
+	e := idx.entries[i]
+
+	return slices.Clone(e.vector), e.metadata, true
+}
+
+// Search returns the k vectors most similar to the query, ordered from the
+// most similar.
+func (idx *Index) Search(query []float64, k int) ([]Result, error) {
+	return idx.SearchFunc(query, k, nil)
+}
+
+// SearchFunc returns the k vectors most similar to the query that are
+// accepted by the filter, ordered from the most similar. A nil filter
+// accepts every vector.
+func (idx *Index) SearchFunc(query []float64, k int, filter func(id string, metadata map[string]any) bool) ([]Result, error) {
+	if err := idx.check(query); err != nil {
+		return nil, err
+	}
+
+	if k <= 0 {
+		return nil, nil
+	}
+
+	qNorm := norm(query)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	top := make(topK, 0, min(k, len(idx.entries)))
+
+	for _, e := range idx.entries {
+		if filter != nil && !filter(e.id, e.metadata) {
+			continue
+		}
+
+		r := Result{
+			ID:       e.id,
+			Score:    score(idx.metric, query, qNorm, e.vector, e.norm),
+			Metadata: e.metadata,
+		}
+
+		switch {
+		case len(top) < k:
+			heap.Push(&top, r)
+
+		case top.less(top[0], r):
+			top[0] = r
+			heap.Fix(&top, 0)
+		}
+	}
+
+	results := make([]Result, len(top))
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = heap.Pop(&top).(Result)
+	}
+
+	return results, nil
+}
+
+// check verifies the vector has the dimensions of the index.
+func (idx *Index) check(vector []float64) error {
+	if len(vector) != idx.dimensions {
+		return fmt.Errorf("got %d dimensions, index has %d: %w", len(vector), idx.dimensions, ErrDimensionMismatch)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+func newEntry(id string, vector []float64, metadata map[string]any) entry {
+	return entry{
+		id:       id,
+		vector:   slices.Clone(vector),
+		norm:     norm(vector),
+		metadata: metadata,
+	}
+}
+
+// score compares the query to a vector using the metric. The norms are
+// computed once so cosine only needs the dot product per comparison.
+func score(metric Metric, query []float64, qNorm float64, vector []float64, vNorm float64) float64 {
+	switch metric {
+	case DotProduct:
+		return dot(query, vector)
+
+	case Euclidean:
+		return negate(euclidean(query, vector))
+
+	case Manhattan:
+		return negate(manhattan(query, vector))
+
+	default:
+		if qNorm == 0 || vNorm == 0 {
+			return 0
+		}
+		return dot(query, vector) / (qNorm * vNorm)
+	}
+}
+
+// negate turns a distance into a score, avoiding a negative zero.
+func negate(distance float64) float64 {
+	if distance == 0 {
+		return 0
+	}
+
+	return -distance
+}
+
+// =============================================================================
+
+// topK is a min heap of the best results found so far, so the worst of them
+// is at the root and can be replaced by a better result. Results with the
+// same score are ordered by id so searches are deterministic.
+type topK []Result
+
+func (h topK) less(a, b Result) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+
+	return a.ID > b.ID
+}
+
+func (h topK) Len() int           { return len(h) }
+func (h topK) Less(i, j int) bool { return h.less(h[i], h[j]) }
+func (h topK) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *topK) Push(x any)        { *h = append(*h, x.(Result)) }
+
+func (h *topK) Pop() any {
+	old := *h
+	n := len(old)
+	r := old[n-1]
+	*h = old[:n-1]
+
+	return r
+}
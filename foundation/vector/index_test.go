@@ -0,0 +1,291 @@
+package vector
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// metricVectors are ordered differently by every metric when searched with
+// the query [1 0].
+var metricVectors = map[string][]float64{
+	"a": {3, 0},
+	"b": {1, 0.5},
+	"c": {0.8, -0.1},
+	"d": {0, 1.2},
+}
+
+func newMetricIndex(t *testing.T, metric Metric) *Index {
+	t.Helper()
+
+	idx := NewIndex(metric, 2)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if err := idx.Add(id, metricVectors[id], map[string]any{"id": id}); err != nil {
+			t.Fatalf("add %s: %s", id, err)
+		}
+	}
+
+	return idx
+}
+
+func resultIDs(results []Result) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+
+	return ids
+}
+
+// checkIDs verifies every id maps to the position of its entry.
+func checkIDs(t *testing.T, idx *Index) {
+	t.Helper()
+
+	if len(idx.ids) != len(idx.entries) {
+		t.Fatalf("got %d ids for %d entries", len(idx.ids), len(idx.entries))
+	}
+
+	for i, e := range idx.entries {
+		if idx.ids[e.id] != i {
+			t.Errorf("id %s: got position %d, expected %d", e.id, idx.ids[e.id], i)
+		}
+	}
+}
+
+func TestIndexMetricOrder(t *testing.T) {
+	tests := []struct {
+		metric   Metric
+		expected []string
+	}{
+		{metric: Cosine, expected: []string{"a", "c", "b", "d"}},
+		{metric: DotProduct, expected: []string{"a", "b", "c", "d"}},
+		{metric: Euclidean, expected: []string{"c", "b", "d", "a"}},
+		{metric: Manhattan, expected: []string{"c", "b", "a", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metric.String(), func(t *testing.T) {
+			idx := newMetricIndex(t, tt.metric)
+
+			results, err := idx.Search([]float64{1, 0}, 4)
+			if err != nil {
+				t.Fatalf("search: %s", err)
+			}
+
+			if ids := resultIDs(results); !slices.Equal(ids, tt.expected) {
+				t.Errorf("got %v, expected %v", ids, tt.expected)
+			}
+
+			for i, r := range results {
+				if r.Metadata["id"] != r.ID {
+					t.Errorf("result %d: got metadata %v for %s", i, r.Metadata, r.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexK(t *testing.T) {
+	tests := []struct {
+		name     string
+		k        int
+		expected []string
+	}{
+		{name: "top 2", k: 2, expected: []string{"a", "c"}},
+		{name: "all", k: 4, expected: []string{"a", "c", "b", "d"}},
+		{name: "more than len", k: 10, expected: []string{"a", "c", "b", "d"}},
+		{name: "zero", k: 0, expected: nil},
+		{name: "negative", k: -1, expected: nil},
+	}
+
+	idx := newMetricIndex(t, Cosine)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := idx.Search([]float64{1, 0}, tt.k)
+			if err != nil {
+				t.Fatalf("search: %s", err)
+			}
+
+			if ids := resultIDs(results); !slices.Equal(ids, tt.expected) {
+				t.Errorf("got %v, expected %v", ids, tt.expected)
+			}
+		})
+	}
+
+	results, err := NewIndex(Cosine, 2).Search([]float64{1, 0}, 3)
+	if err != nil || len(results) != 0 {
+		t.Errorf("searching an empty index: got %v %v, expected no results", results, err)
+	}
+}
+
+func TestIndexDelete(t *testing.T) {
+	idx := newMetricIndex(t, Euclidean)
+
+	// Deleting b moves d, the last entry, into its position.
+
+	if !idx.Delete("b") {
+		t.Fatalf("delete b: not found")
+	}
+	checkIDs(t, idx)
+
+	if idx.ids["d"] != 1 {
+		t.Errorf("got d at %d, expected it moved to 1", idx.ids["d"])
+	}
+
+	if v, _, exists := idx.Get("d"); !exists || !slices.Equal(v, metricVectors["d"]) {
+		t.Errorf("get d: got %v %t, expected %v", v, exists, metricVectors["d"])
+	}
+
+	if _, _, exists := idx.Get("b"); exists {
+		t.Errorf("get b: expected the deleted vector to be missing")
+	}
+
+	if idx.Delete("b") {
+		t.Errorf("delete b twice: expected false")
+	}
+
+	// Deleting the last entry doesn't move anything.
+
+	if !idx.Delete("c") {
+		t.Fatalf("delete c: not found")
+	}
+	checkIDs(t, idx)
+
+	if err := idx.Add("b", metricVectors["b"], nil); err != nil {
+		t.Fatalf("adding b back: %s", err)
+	}
+	checkIDs(t, idx)
+
+	results, err := idx.Search([]float64{1, 0}, 10)
+	if err != nil {
+		t.Fatalf("search: %s", err)
+	}
+
+	if ids, expected := resultIDs(results), []string{"b", "d", "a"}; !slices.Equal(ids, expected) {
+		t.Errorf("got %v, expected %v", ids, expected)
+	}
+
+	if idx.Len() != 3 {
+		t.Errorf("got len %d, expected 3", idx.Len())
+	}
+}
+
+func TestIndexUpsert(t *testing.T) {
+	idx := newMetricIndex(t, Euclidean)
+
+	if err := idx.Upsert("a", []float64{1, 0}, map[string]any{"id": "a", "version": 2}); err != nil {
+		t.Fatalf("upsert a: %s", err)
+	}
+
+	if err := idx.Upsert("e", []float64{5, 5}, nil); err != nil {
+		t.Fatalf("upsert e: %s", err)
+	}
+
+	checkIDs(t, idx)
+
+	if idx.Len() != 5 {
+		t.Errorf("got len %d, expected 5", idx.Len())
+	}
+
+	results, err := idx.Search([]float64{1, 0}, 1)
+	if err != nil {
+		t.Fatalf("search: %s", err)
+	}
+
+	if results[0].ID != "a" || results[0].Score != 0 || results[0].Metadata["version"] != 2 {
+		t.Errorf("got %+v, expected the replaced a", results[0])
+	}
+}
+
+func TestIndexErrors(t *testing.T) {
+	idx := newMetricIndex(t, Cosine)
+
+	tests := []struct {
+		name     string
+		f        func() error
+		expected error
+	}{
+		{name: "add duplicate", f: func() error { return idx.Add("a", []float64{1, 1}, nil) }, expected: ErrDuplicateID},
+		{name: "add dimensions", f: func() error { return idx.Add("e", []float64{1, 1, 1}, nil) }, expected: ErrDimensionMismatch},
+		{name: "upsert dimensions", f: func() error { return idx.Upsert("a", []float64{1}, nil) }, expected: ErrDimensionMismatch},
+		{name: "search dimensions", f: func() error { _, err := idx.Search([]float64{1, 0, 0}, 1); return err }, expected: ErrDimensionMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.f(); !errors.Is(err, tt.expected) {
+				t.Errorf("got %v, expected %v", err, tt.expected)
+			}
+		})
+	}
+
+	// A failed call leaves the index unchanged.
+	if v, _, _ := idx.Get("a"); idx.Len() != 4 || !slices.Equal(v, metricVectors["a"]) {
+		t.Errorf("got len %d and a %v, expected the index unchanged", idx.Len(), v)
+	}
+}
+
+func TestIndexConcurrent(t *testing.T) {
+	const dimensions = 8
+
+	idx := NewIndex(Cosine, dimensions)
+	r := rand.New(rand.NewPCG(1, 1))
+
+	vector := func() []float64 {
+		v := make([]float64, dimensions)
+		for i := range v {
+			v[i] = r.Float64()
+		}
+		return v
+	}
+
+	vectors := make([][]float64, 200)
+	for i := range vectors {
+		vectors[i] = vector()
+	}
+	queries := vectors[:20]
+
+	var wg sync.WaitGroup
+
+	// The writer adds every vector and deletes every other one while the
+	// readers search.
+	wg.Go(func() {
+		for i, v := range vectors {
+			if err := idx.Add(fmt.Sprint(i), v, nil); err != nil {
+				t.Errorf("add %d: %s", i, err)
+			}
+			if i%2 == 1 {
+				idx.Delete(fmt.Sprint(i - 1))
+			}
+		}
+	})
+
+	for range 4 {
+		wg.Go(func() {
+			for _, q := range queries {
+				results, err := idx.Search(q, 5)
+				if err != nil {
+					t.Errorf("search: %s", err)
+					return
+				}
+
+				if len(results) > 5 || !slices.IsSortedFunc(results, func(a, b Result) int { return cmp.Compare(b.Score, a.Score) }) {
+					t.Errorf("got %d results out of order", len(results))
+				}
+			}
+		})
+	}
+
+	wg.Wait()
+
+	checkIDs(t, idx)
+
+	if idx.Len() != len(vectors)/2 {
+		t.Errorf("got len %d, expected %d", idx.Len(), len(vectors)/2)
+	}
+}
@@ -0,0 +1,140 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+)
+
+// MMR selects up to k candidates using Maximal Marginal Relevance, which
+// balances how relevant a candidate is to the query against how similar it
+// is to the candidates already selected. A lambda of 1 ranks by relevance
+// only and a lambda of 0 by diversity only, with 0.5 being a common
+// starting point. Relevance and similarity are measured with the cosine
+// similarity of the vectors returned by the vector function, so it works
+// on the results of any search, like a MongoDB $vectorSearch that projects
+// the embeddings. The candidates are returned in the order they were
+// selected.
+func MMR[E any](query []float64, candidates []E, vector func(E) []float64, k int, lambda float64) ([]E, error) {
+	if k <= 0 || len(candidates) == 0 {
+		return nil, nil
+	}
+
+	lambda = min(max(lambda, 0), 1)
+
+	vectors := make([][]float64, len(candidates))
+	relevance := make([]float64, len(candidates))
+
+	for i, c := range candidates {
+		vectors[i] = vector(c)
+
+		r, err := CosineSimilarity(query, vectors[i])
+		if err != nil {
+			return nil, fmt.Errorf("candidate[%d]: %w", i, err)
+		}
+
+		relevance[i] = r
+	}
+
+	// redundancy holds the highest similarity of every candidate to the
+	// candidates selected so far, updated after every selection.
+	redundancy := make([]float64, len(candidates))
+	selected := make([]bool, len(candidates))
+
+	results := make([]E, 0, min(k, len(candidates)))
+
+	for len(results) < cap(results) {
+		best := -1
+		bestScore := math.Inf(-1)
+
+		for i := range candidates {
+			if selected[i] {
+				continue
+			}
+
+			s := lambda*relevance[i] - (1-lambda)*redundancy[i]
+			if s > bestScore {
+				best, bestScore = i, s
+			}
+		}
+
+		selected[best] = true
+		results = append(results, candidates[best])
+
+		for i := range candidates {
+			if selected[i] {
+				continue
+			}
+
+			s := cosine(vectors[i], vectors[best])
+			redundancy[i] = max(redundancy[i], s)
+		}
+	}
+
+	return results, nil
+}
+
+// SuppressDuplicates removes the candidates whose cosine similarity to an
+// earlier candidate is at or above the threshold, keeping the order of the
+// remaining candidates. Pass the candidates ordered from the most relevant
+// so the most relevant of the near duplicates is kept. A threshold around
+// 0.95 removes chunks that overlap most of their text.
+func SuppressDuplicates[E any](candidates []E, vector func(E) []float64, threshold float64) ([]E, error) {
+	var kept []E
+	var keptVectors [][]float64
+
+next:
+	for i, c := range candidates {
+		v := vector(c)
+
+		for _, kv := range keptVectors {
+			s, err := CosineSimilarity(v, kv)
+			if err != nil {
+				return nil, fmt.Errorf("candidate[%d]: %w", i, err)
+			}
+
+			if s >= threshold {
+				continue next
+			}
+		}
+
+		kept = append(kept, c)
+		keptVectors = append(keptVectors, v)
+	}
+
+	return kept, nil
+}
+
+// SearchMMR searches the index for the fetch vectors most similar to the
+// query and selects k of them using MMR. Fetching 3 to 5 times as many
+// candidates as are selected gives MMR room to skip near duplicates.
+func SearchMMR(s Searcher, query []float64, k int, fetch int, lambda float64) ([]Result, error) {
+	results, err := s.Search(query, max(fetch, k))
+	if err != nil {
+		return nil, err
+	}
+
+	type fetched struct {
+		result Result
+		vector []float64
+	}
+
+	// Vectors deleted since the search are skipped.
+	candidates := make([]fetched, 0, len(results))
+	for _, r := range results {
+		if v, _, exists := s.Get(r.ID); exists {
+			candidates = append(candidates, fetched{result: r, vector: v})
+		}
+	}
+
+	selected, err := MMR(query, candidates, func(f fetched) []float64 { return f.vector }, k, lambda)
+	if err != nil {
+		return nil, err
+	}
+
+	final := make([]Result, len(selected))
+	for i, f := range selected {
+		final[i] = f.result
+	}
+
+	return final, nil
+}
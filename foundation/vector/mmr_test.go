@@ -0,0 +1,187 @@
+package vector
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+type point struct {
+	id     string
+	vector []float64
+}
+
+// points returns candidates ordered by their relevance to the query
+// [1 0 0], where a2 is a near duplicate of a and b is less relevant but
+// different from a.
+func points() []point {
+	return []point{
+		{id: "a", vector: []float64{0.95, 0.31, 0}},
+		{id: "a2", vector: []float64{0.94, 0.34, 0}},
+		{id: "b", vector: []float64{0.9, 0, 0.44}},
+		{id: "c", vector: []float64{0, 1, 0}},
+	}
+}
+
+func pointVector(p point) []float64 {
+	return p.vector
+}
+
+func pointIDs(ps []point) []string {
+	ids := make([]string, len(ps))
+	for i, p := range ps {
+		ids[i] = p.id
+	}
+
+	return ids
+}
+
+func TestMMR(t *testing.T) {
+	query := []float64{1, 0, 0}
+
+	tests := []struct {
+		name     string
+		k        int
+		lambda   float64
+		expected []string
+	}{
+		{name: "relevance only", k: 4, lambda: 1, expected: []string{"a", "a2", "b", "c"}},
+		{name: "diversity only", k: 4, lambda: 0, expected: []string{"a", "c", "b", "a2"}},
+		{name: "balanced skips the duplicate", k: 2, lambda: 0.7, expected: []string{"a", "b"}},
+		{name: "k above the candidates", k: 10, lambda: 1, expected: []string{"a", "a2", "b", "c"}},
+		{name: "k of zero", k: 0, lambda: 1, expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MMR(query, points(), pointVector, tt.k, tt.lambda)
+			if err != nil {
+				t.Fatalf("selecting: %s", err)
+			}
+
+			if ids := pointIDs(got); !slices.Equal(ids, tt.expected) {
+				t.Errorf("got %v, expected %v", ids, tt.expected)
+			}
+		})
+	}
+
+	candidates := append(points(), point{id: "bad", vector: []float64{1}})
+	if _, err := MMR(query, candidates, pointVector, 2, 0.5); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("got error %v, expected %v", err, ErrDimensionMismatch)
+	}
+}
+
+func TestSuppressDuplicates(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold float64
+		expected  []string
+	}{
+		{name: "near duplicates", threshold: 0.95, expected: []string{"a", "b", "c"}},
+		{name: "loose threshold", threshold: 0.8, expected: []string{"a", "c"}},
+		{name: "above every similarity", threshold: 1.1, expected: []string{"a", "a2", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SuppressDuplicates(points(), pointVector, tt.threshold)
+			if err != nil {
+				t.Fatalf("suppressing: %s", err)
+			}
+
+			if ids := pointIDs(got); !slices.Equal(ids, tt.expected) {
+				t.Errorf("got %v, expected %v", ids, tt.expected)
+			}
+		})
+	}
+}
+
+// =============================================================================
+
+// missingIndex is an index whose Get doesn't find the vector with the id,
+// like a vector deleted between the search and the lookup.
+type missingIndex struct {
+	*Index
+	id string
+}
+
+func (idx missingIndex) Get(id string) ([]float64, map[string]any, bool) {
+	if id == idx.id {
+		return nil, nil, false
+	}
+
+	return idx.Index.Get(id)
+}
+
+func TestSearchMMR(t *testing.T) {
+	idx := NewIndex(Cosine, 3)
+	for _, p := range points() {
+		if err := idx.Add(p.id, p.vector, nil); err != nil {
+			t.Fatalf("adding: %s", err)
+		}
+	}
+
+	query := []float64{1, 0, 0}
+
+	tests := []struct {
+		name     string
+		s        Searcher
+		k        int
+		fetch    int
+		expected []string
+	}{
+		{name: "diverse", s: idx, k: 2, fetch: 4, expected: []string{"a", "b"}},
+		{name: "fetch below k", s: idx, k: 3, fetch: 1, expected: []string{"a", "b", "a2"}},
+		{name: "missing id", s: missingIndex{Index: idx, id: "a"}, k: 2, fetch: 4, expected: []string{"a2", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := SearchMMR(tt.s, query, tt.k, tt.fetch, 0.7)
+			if err != nil {
+				t.Fatalf("searching: %s", err)
+			}
+
+			ids := make([]string, len(results))
+			for i, r := range results {
+				ids[i] = r.ID
+			}
+
+			if !slices.Equal(ids, tt.expected) {
+				t.Errorf("got %v, expected %v", ids, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	tests := []struct {
+		name string
+		s    Searcher
+	}{
+		{name: "Index", s: NewIndex(Cosine, 3)},
+		{name: "HNSW", s: NewHNSW(Cosine, 3)},
+		{name: "BinaryIndex", s: NewBinaryIndex(Cosine, NewBinaryQuantizer(3))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, p := range points() {
+				if err := tt.s.Add(p.id, p.vector, nil); err != nil {
+					t.Fatalf("adding: %s", err)
+				}
+			}
+			tt.s.Delete("b")
+
+			for _, id := range []string{"missing", "b"} {
+				if v, metadata, exists := tt.s.Get(id); exists || v != nil || metadata != nil {
+					t.Errorf("get %s: got %v %v %t, expected nothing", id, v, metadata, exists)
+				}
+			}
+
+			if v, _, exists := tt.s.Get("a"); !exists || len(v) != 3 {
+				t.Errorf("get a: got %v %t, expected the vector", v, exists)
+			}
+		})
+	}
+}
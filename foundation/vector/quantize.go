@@ -0,0 +1,214 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// ErrNoSamples is returned when a quantizer is calibrated without samples.
+var ErrNoSamples = errors.New("no samples to calibrate with")
+
+// ScalarQuantizer encodes vectors as int8 values, which take an eighth of
+// the memory of float64 values. Every dimension is mapped linearly from the
+// range seen in the calibration samples to the 256 int8 values, so values
+// outside the range are clipped.
+type ScalarQuantizer struct {
+	quantile float64
+	min      []float64
+	scale    []float64
+}
+
+// CalibrateScalar constructs a scalar quantizer using the range of every
+// dimension in the samples. The samples should be representative of the
+// vectors that will be encoded.
+func CalibrateScalar(samples [][]float64, options ...func(sq *ScalarQuantizer)) (*ScalarQuantizer, error) {
+	dimensions, err := checkSamples(samples)
+	if err != nil {
+		return nil, err
+	}
+
+	sq := ScalarQuantizer{
+		quantile: 1,
+		min:      make([]float64, dimensions),
+		scale:    make([]float64, dimensions),
+	}
+
+	for _, option := range options {
+		option(&sq)
+	}
+
+	values := make([]float64, len(samples))
+
+	for d := range dimensions {
+		for i, s := range samples {
+			values[i] = s[d]
+		}
+
+		slices.Sort(values)
+
+		lo := values[int(float64(len(values)-1)*(1-sq.quantile))]
+		hi := values[int(float64(len(values)-1)*sq.quantile)]
+
+		sq.min[d] = lo
+		sq.scale[d] = (hi - lo) / 255
+	}
+
+	return &sq, nil
+}
+
+// WithQuantile ignores the outliers of every dimension when calibrating, so
+// a quantile of 0.99 uses the range between the 1st and 99th percentile.
+// Narrowing the range gives the common values more precision. The default
+// of 1 uses the full range.
+func WithQuantile(quantile float64) func(sq *ScalarQuantizer) {
+	return func(sq *ScalarQuantizer) {
+		sq.quantile = min(max(quantile, 0.5), 1)
+	}
+}
+
+// Dimensions returns the number of dimensions of the vectors the quantizer
+// encodes.
+func (sq *ScalarQuantizer) Dimensions() int {
+	return len(sq.min)
+}
+
+// Encode returns the int8 code of the vector.
+func (sq *ScalarQuantizer) Encode(vector []float64) ([]int8, error) {
+	if len(vector) != len(sq.min) {
+		return nil, fmt.Errorf("got %d dimensions, quantizer has %d: %w", len(vector), len(sq.min), ErrDimensionMismatch)
+	}
+
+	code := make([]int8, len(vector))
+	for d, v := range vector {
+		if sq.scale[d] == 0 {
+			code[d] = math.MinInt8
+			continue
+		}
+
+		q := math.Round((v-sq.min[d])/sq.scale[d]) + math.MinInt8
+		code[d] = int8(min(max(q, math.MinInt8), math.MaxInt8))
+	}
+
+	return code, nil
+}
+
+// Decode returns the approximate vector of the int8 code.
+func (sq *ScalarQuantizer) Decode(code []int8) ([]float64, error) {
+	if len(code) != len(sq.min) {
+		return nil, fmt.Errorf("got %d dimensions, quantizer has %d: %w", len(code), len(sq.min), ErrDimensionMismatch)
+	}
+
+	vector := make([]float64, len(code))
+	sq.decode(vector, code)
+
+	return vector, nil
+}
+
+// decode writes the approximate vector of the code to the vector.
+func (sq *ScalarQuantizer) decode(vector []float64, code []int8) {
+	for d, q := range code {
+		vector[d] = (float64(q)-math.MinInt8)*sq.scale[d] + sq.min[d]
+	}
+}
+
+// =============================================================================
+
+// BinaryQuantizer encodes vectors as one bit per dimension, which takes a
+// 64th of the memory of float64 values. A bit is set when the value is
+// above the threshold of the dimension. Binary codes are compared with the
+// Hamming distance, which only approximates the similarity of the vectors,
+// so they are used to find candidates that are rescored with the vectors.
+type BinaryQuantizer struct {
+	thresholds []float64
+}
+
+// NewBinaryQuantizer constructs a binary quantizer that encodes the sign of
+// every value.
+func NewBinaryQuantizer(dimensions int) *BinaryQuantizer {
+	return &BinaryQuantizer{
+		thresholds: make([]float64, dimensions),
+	}
+}
+
+// CalibrateBinary constructs a binary quantizer using the mean of every
+// dimension in the samples as its threshold. This works better than the
+// sign for embeddings whose values aren't centered around zero.
+func CalibrateBinary(samples [][]float64) (*BinaryQuantizer, error) {
+	dimensions, err := checkSamples(samples)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholds := make([]float64, dimensions)
+	for _, s := range samples {
+		for d, v := range s {
+			thresholds[d] += v
+		}
+	}
+
+	for d := range thresholds {
+		thresholds[d] /= float64(len(samples))
+	}
+
+	bq := BinaryQuantizer{
+		thresholds: thresholds,
+	}
+
+	return &bq, nil
+}
+
+// Dimensions returns the number of dimensions of the vectors the quantizer
+// encodes.
+func (bq *BinaryQuantizer) Dimensions() int {
+	return len(bq.thresholds)
+}
+
+// Encode returns the binary code of the vector, packed 64 dimensions per
+// value.
+func (bq *BinaryQuantizer) Encode(vector []float64) ([]uint64, error) {
+	if len(vector) != len(bq.thresholds) {
+		return nil, fmt.Errorf("got %d dimensions, quantizer has %d: %w", len(vector), len(bq.thresholds), ErrDimensionMismatch)
+	}
+
+	code := make([]uint64, (len(vector)+63)/64)
+	for d, v := range vector {
+		if v > bq.thresholds[d] {
+			code[d/64] |= 1 << (d % 64)
+		}
+	}
+
+	return code, nil
+}
+
+// Hamming returns the number of bits that differ between two binary codes
+// of the same length.
+func Hamming(x, y []uint64) int {
+	var n int
+	for i := range x {
+		n += bits.OnesCount64(x[i] ^ y[i])
+	}
+
+	return n
+}
+
+// =============================================================================
+
+// checkSamples verifies there are samples and they all have the same
+// dimensions, which are returned.
+func checkSamples(samples [][]float64) (int, error) {
+	if len(samples) == 0 {
+		return 0, ErrNoSamples
+	}
+
+	dimensions := len(samples[0])
+	for i, s := range samples {
+		if len(s) != dimensions {
+			return 0, fmt.Errorf("sample[%d]: got %d dimensions, expected %d: %w", i, len(s), dimensions, ErrDimensionMismatch)
+		}
+	}
+
+	return dimensions, nil
+}
@@ -0,0 +1,185 @@
+package vector
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestScalarQuantizerErrorBound(t *testing.T) {
+	samples := randomVectors(200, 16, 1)
+
+	sq, err := CalibrateScalar(samples)
+	if err != nil {
+		t.Fatalf("calibrating: %s", err)
+	}
+
+	// Every value within the calibrated range is rounded to the closest of
+	// the 256 steps, so it's off by at most half a step.
+
+	for i, s := range samples {
+		code, err := sq.Encode(s)
+		if err != nil {
+			t.Fatalf("encoding: %s", err)
+		}
+
+		decoded, err := sq.Decode(code)
+		if err != nil {
+			t.Fatalf("decoding: %s", err)
+		}
+
+		for d := range s {
+			if diff := math.Abs(decoded[d] - s[d]); diff > sq.scale[d]/2+1e-12 {
+				t.Fatalf("sample %d dimension %d: got %v for %v, off by more than %v", i, d, decoded[d], s[d], sq.scale[d]/2)
+			}
+		}
+	}
+}
+
+func TestWithQuantile(t *testing.T) {
+	samples := make([][]float64, 101)
+	for i := range samples {
+		samples[i] = []float64{float64(i) / 100}
+	}
+	samples[100][0] = 100
+
+	tests := []struct {
+		name      string
+		quantile  float64
+		maxDecode float64
+	}{
+		{name: "full range", quantile: 1, maxDecode: 100},
+		{name: "clipped", quantile: 0.99, maxDecode: 0.99},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sq, err := CalibrateScalar(samples, WithQuantile(tt.quantile))
+			if err != nil {
+				t.Fatalf("calibrating: %s", err)
+			}
+
+			code, err := sq.Encode([]float64{100})
+			if err != nil {
+				t.Fatalf("encoding: %s", err)
+			}
+
+			if code[0] != math.MaxInt8 {
+				t.Errorf("got code %d for the outlier, expected %d", code[0], math.MaxInt8)
+			}
+
+			decoded, err := sq.Decode(code)
+			if err != nil {
+				t.Fatalf("decoding: %s", err)
+			}
+
+			if math.Abs(decoded[0]-tt.maxDecode) > 1e-9 {
+				t.Errorf("got %v for the outlier, expected it clipped to %v", decoded[0], tt.maxDecode)
+			}
+
+			// Values below the range are clipped to the smallest code.
+
+			code, err = sq.Encode([]float64{-5})
+			if err != nil {
+				t.Fatalf("encoding: %s", err)
+			}
+
+			if code[0] != math.MinInt8 {
+				t.Errorf("got code %d below the range, expected %d", code[0], math.MinInt8)
+			}
+		})
+	}
+
+	// Ignoring the outlier gives the common values more precision.
+
+	full, _ := CalibrateScalar(samples)
+	clipped, _ := CalibrateScalar(samples, WithQuantile(0.99))
+
+	if clipped.scale[0] >= full.scale[0] {
+		t.Errorf("got step %v with the quantile, expected less than %v", clipped.scale[0], full.scale[0])
+	}
+}
+
+func TestQuantizerErrors(t *testing.T) {
+	if _, err := CalibrateScalar(nil); !errors.Is(err, ErrNoSamples) {
+		t.Errorf("scalar without samples: got error %v, expected %v", err, ErrNoSamples)
+	}
+
+	if _, err := CalibrateBinary(nil); !errors.Is(err, ErrNoSamples) {
+		t.Errorf("binary without samples: got error %v, expected %v", err, ErrNoSamples)
+	}
+
+	if _, err := CalibrateScalar([][]float64{{1, 2}, {1}}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("samples of different dimensions: got error %v, expected %v", err, ErrDimensionMismatch)
+	}
+
+	sq, _ := CalibrateScalar([][]float64{{1, 2}, {3, 4}})
+
+	if _, err := sq.Encode([]float64{1}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("scalar encode: got error %v, expected %v", err, ErrDimensionMismatch)
+	}
+
+	if _, err := sq.Decode([]int8{1, 2, 3}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("scalar decode: got error %v, expected %v", err, ErrDimensionMismatch)
+	}
+
+	if _, err := NewBinaryQuantizer(2).Encode([]float64{1}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("binary encode: got error %v, expected %v", err, ErrDimensionMismatch)
+	}
+}
+
+func TestBinaryQuantizer(t *testing.T) {
+	vector := make([]float64, 70)
+	vector[0] = 1
+	vector[63] = 0.5
+	vector[64] = 2
+	vector[69] = -1
+
+	code, err := NewBinaryQuantizer(70).Encode(vector)
+	if err != nil {
+		t.Fatalf("encoding: %s", err)
+	}
+
+	expected := []uint64{1 | 1<<63, 1}
+	if len(code) != 2 || code[0] != expected[0] || code[1] != expected[1] {
+		t.Errorf("got code %#x, expected %#x", code, expected)
+	}
+
+	// Calibrating moves the threshold to the mean of every dimension.
+
+	bq, err := CalibrateBinary([][]float64{{10, -1}, {20, -3}})
+	if err != nil {
+		t.Fatalf("calibrating: %s", err)
+	}
+
+	code, err = bq.Encode([]float64{16, -1.5})
+	if err != nil {
+		t.Fatalf("encoding: %s", err)
+	}
+
+	if code[0] != 0b11 {
+		t.Errorf("got code %#b, expected 0b11", code[0])
+	}
+}
+
+func TestHamming(t *testing.T) {
+	tests := []struct {
+		name     string
+		x, y     []uint64
+		expected int
+	}{
+		{name: "equal", x: []uint64{0xff, 1}, y: []uint64{0xff, 1}, expected: 0},
+		{name: "one bit", x: []uint64{0b1000}, y: []uint64{0}, expected: 1},
+		{name: "all bits", x: []uint64{math.MaxUint64}, y: []uint64{0}, expected: 64},
+		{name: "across words", x: []uint64{0b101, 1 << 63}, y: []uint64{0b011, 0}, expected: 3},
+		{name: "empty", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hamming(tt.x, tt.y); got != tt.expected {
+				t.Errorf("got %d, expected %d", got, tt.expected)
+			}
+		})
+	}
+}
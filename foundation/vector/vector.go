@@ -6,7 +6,19 @@
 // https://github.com/gonum/gonum
 package vector
 
-import "math"
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrNoVectors is returned when a function needs at least one vector.
+var ErrNoVectors = errors.New("no vectors")
+
+// Float represents the types of values a vector can hold.
+type Float interface {
+	~float32 | ~float64
+}
 
 // Data represents data that can be vectorized.
 type Data interface {
@@ -25,13 +37,16 @@ type SimilarityResult struct {
 }
 
 // Similarity calculates the similarity between two vectors.
-func Similarity(target Data, dataPoints ...Data) []SimilarityResult {
+func Similarity(target Data, dataPoints ...Data) ([]SimilarityResult, error) {
 	results := make([]SimilarityResult, len(dataPoints))
 
 	te := target.Vector()
 
 	for i, dp := range dataPoints {
-		similarity := CosineSimilarity(te, dp.Vector())
+		similarity, err := CosineSimilarity(te, dp.Vector())
+		if err != nil {
+			return nil, fmt.Errorf("data point[%d]: %w", i, err)
+		}
 
 		results[i] = SimilarityResult{
 			Target:     target,
@@ -41,130 +56,257 @@ func Similarity(target Data, dataPoints ...Data) []SimilarityResult {
 		}
 	}
 
-	return results
+	return results, nil
 }
 
-// CosineSimilarity takes two vectors and computes the similarity between
-// them using a cosine algorithm.
-func CosineSimilarity(x, y []float64) float64 {
-	var sum, s1, s2 float64
+// =============================================================================
 
-	for i := range x {
-		sum += x[i] * y[i]
-		s1 += x[i] * x[i]
-		s2 += y[i] * y[i]
+// CosineSimilarity takes two vectors and computes the similarity between
+// them using a cosine algorithm. The similarity of a zero vector is 0.
+func CosineSimilarity[T Float](x, y []T) (T, error) {
+	if err := checkDimensions(x, y); err != nil {
+		return 0, err
 	}
 
-	if s1 == 0 || s2 == 0 {
-		return 0.0
+	return cosine(x, y), nil
+}
+
+// Dot calculates the dot product of two vectors.
+func Dot[T Float](x, y []T) (T, error) {
+	if err := checkDimensions(x, y); err != nil {
+		return 0, err
 	}
 
-	return sum / (math.Sqrt(s1) * math.Sqrt(s2))
+	return dot(x, y), nil
 }
 
-// CosineSimilarity32 takes two vectors and computes the similarity between
-// them using a cosine algorithm.
-func CosineSimilarity32(x, y []float32) float32 {
-	var sum, s1, s2 float32
+// Norm calculates the length of the vector.
+func Norm[T Float](x []T) T {
+	return norm(x)
+}
 
-	for i := range x {
-		sum += x[i] * y[i]
-		s1 += x[i] * x[i]
-		s2 += y[i] * y[i]
+// EuclideanDistance calculates the straight line distance between two
+// vectors.
+func EuclideanDistance[T Float](x, y []T) (T, error) {
+	if err := checkDimensions(x, y); err != nil {
+		return 0, err
 	}
 
-	if s1 == 0 || s2 == 0 {
-		return 0.0
+	return euclidean(x, y), nil
+}
+
+// ManhattanDistance calculates the sum of the absolute differences between
+// two vectors.
+func ManhattanDistance[T Float](x, y []T) (T, error) {
+	if err := checkDimensions(x, y); err != nil {
+		return 0, err
 	}
 
-	return sum / float32((math.Sqrt(float64(s1)) * math.Sqrt(float64(s2))))
+	return manhattan(x, y), nil
 }
 
 // =============================================================================
 
-const (
-	x = iota
-	y
-	z
-)
+// Add returns a new vector with the sum of two vectors.
+func Add[T Float](a, b []T) ([]T, error) {
+	if err := checkDimensions(a, b); err != nil {
+		return nil, err
+	}
 
-// Add calculates the addition of two vectors.
-func Add(a, b []float64) []float64 {
-	dimA, dimB := len(a), len(b)
+	v := make([]T, len(a))
+	axpy(v, 1, b, a)
 
-	if (dimA == 1 || dimA == 2 || dimA == 3) && dimB == 1 {
-		a[x] += b[x]
-		return a
+	return v, nil
+}
+
+// Sub returns a new vector with the difference of two vectors.
+func Sub[T Float](a, b []T) ([]T, error) {
+	if err := checkDimensions(a, b); err != nil {
+		return nil, err
 	}
 
-	if dimA == 2 && dimB == 2 {
-		a[x], a[y] = a[x]+b[x], a[y]+b[y]
-		return a
+	v := make([]T, len(a))
+	axpy(v, -1, b, a)
+
+	return v, nil
+}
+
+// Scale returns a new vector with every value of the vector multiplied by
+// the scalar.
+func Scale[T Float](a []T, s T) []T {
+	v := make([]T, len(a))
+	for i := range a {
+		v[i] = a[i] * s
 	}
 
-	if dimA == 3 && dimB == 2 {
-		a[x], a[y] = a[x]+b[x], a[y]+b[y]
-		return a
+	return v
+}
+
+// Normalize returns a new vector in the direction of the vector with a
+// length of 1. A zero vector can't be normalized and is returned as a new
+// zero vector.
+func Normalize[T Float](a []T) []T {
+	n := norm(a)
+	if n == 0 {
+		return make([]T, len(a))
 	}
 
-	if dimA == 3 && dimB == 3 {
-		a[x], a[y], a[z] = a[x]+b[x], a[y]+b[y], a[z]+b[z]
-		return a
+	return Scale(a, 1/n)
+}
+
+// Mean returns a new vector with the average of the vectors, which is the
+// centroid of the vectors.
+func Mean[T Float](vectors ...[]T) ([]T, error) {
+	if len(vectors) == 0 {
+		return nil, ErrNoVectors
 	}
 
-	if dimB > dimA {
-		axpyUnitaryTo(a, 1, a, b[:dimA])
-	} else {
-		axpyUnitaryTo(a, 1, a, b)
+	v := make([]T, len(vectors[0]))
+
+	for i, vec := range vectors {
+		if err := checkDimensions(v, vec); err != nil {
+			return nil, fmt.Errorf("vector[%d]: %w", i, err)
+		}
+
+		axpy(v, 1, vec, v)
 	}
 
-	return a
+	n := T(len(vectors))
+	for i := range v {
+		v[i] /= n
+	}
+
+	return v, nil
 }
 
-// Sub calculates the subtraction of two vectors.
-func Sub(a, b []float64) []float64 {
-	dimA, dimB := len(a), len(b)
+// =============================================================================
 
-	if (dimA == 1 || dimA == 2 || dimA == 3) && dimB == 1 {
-		a[x] -= b[x]
-		return a
+// checkDimensions verifies two vectors have the same dimensions.
+func checkDimensions[T Float](x, y []T) error {
+	if len(x) != len(y) {
+		return fmt.Errorf("got %d and %d dimensions: %w", len(x), len(y), ErrDimensionMismatch)
 	}
 
-	if dimA == 2 && dimB == 2 {
-		a[x], a[y] = a[x]-b[x], a[y]-b[y]
-		return a
+	return nil
+}
+
+// The functions below expect vectors of the same dimensions. The loops are
+// unrolled with independent sums, which lets the CPU run the additions in
+// parallel, and reslice the second vector so the compiler can remove the
+// bounds checks.
+
+func dot[T Float](x, y []T) T {
+	y = y[:len(x)]
+
+	var s0, s1, s2, s3 T
+
+	i := 0
+	for ; i <= len(x)-4; i += 4 {
+		s0 += x[i] * y[i]
+		s1 += x[i+1] * y[i+1]
+		s2 += x[i+2] * y[i+2]
+		s3 += x[i+3] * y[i+3]
 	}
 
-	if dimA == 3 && dimB == 1 {
-		a[x] -= b[x]
-		return a
+	for ; i < len(x); i++ {
+		s0 += x[i] * y[i]
 	}
 
-	if dimA == 3 && dimB == 2 {
-		a[x], a[y] = a[x]-b[x], a[y]-b[y]
-		return a
+	return s0 + s1 + s2 + s3
+}
+
+func cosine[T Float](x, y []T) T {
+	y = y[:len(x)]
+
+	var d0, d1, x0, x1, y0, y1 T
+
+	i := 0
+	for ; i <= len(x)-2; i += 2 {
+		d0 += x[i] * y[i]
+		d1 += x[i+1] * y[i+1]
+		x0 += x[i] * x[i]
+		x1 += x[i+1] * x[i+1]
+		y0 += y[i] * y[i]
+		y1 += y[i+1] * y[i+1]
 	}
 
-	if dimA == 3 && dimB == 3 {
-		a[x], a[y], a[z] = a[x]-b[x], a[y]-b[y], a[z]-b[z]
-		return a
+	for ; i < len(x); i++ {
+		d0 += x[i] * y[i]
+		x0 += x[i] * x[i]
+		y0 += y[i] * y[i]
 	}
 
-	if dimB > dimA {
-		axpyUnitaryTo(a, -1, b[:dimA], a)
-	} else {
-		axpyUnitaryTo(a, -1, b, a)
+	sx, sy := x0+x1, y0+y1
+	if sx == 0 || sy == 0 {
+		return 0
 	}
 
-	return a
+	return (d0 + d1) / T(math.Sqrt(float64(sx))*math.Sqrt(float64(sy)))
 }
 
-func axpyUnitaryTo(dst []float64, alpha float64, x, y []float64) {
-	dim := len(y)
-	for i, v := range x {
-		if i == dim {
-			return
-		}
-		dst[i] = alpha*v + y[i]
+func norm[T Float](x []T) T {
+	return T(math.Sqrt(float64(dot(x, x))))
+}
+
+func euclidean[T Float](x, y []T) T {
+	y = y[:len(x)]
+
+	var s0, s1, s2, s3 T
+
+	i := 0
+	for ; i <= len(x)-4; i += 4 {
+		d0 := x[i] - y[i]
+		d1 := x[i+1] - y[i+1]
+		d2 := x[i+2] - y[i+2]
+		d3 := x[i+3] - y[i+3]
+		s0 += d0 * d0
+		s1 += d1 * d1
+		s2 += d2 * d2
+		s3 += d3 * d3
+	}
+
+	for ; i < len(x); i++ {
+		d := x[i] - y[i]
+		s0 += d * d
+	}
+
+	return T(math.Sqrt(float64(s0 + s1 + s2 + s3)))
+}
+
+func manhattan[T Float](x, y []T) T {
+	y = y[:len(x)]
+
+	var s0, s1, s2, s3 T
+
+	i := 0
+	for ; i <= len(x)-4; i += 4 {
+		s0 += abs(x[i] - y[i])
+		s1 += abs(x[i+1] - y[i+1])
+		s2 += abs(x[i+2] - y[i+2])
+		s3 += abs(x[i+3] - y[i+3])
+	}
+
+	for ; i < len(x); i++ {
+		s0 += abs(x[i] - y[i])
+	}
+
+	return s0 + s1 + s2 + s3
+}
+
+func abs[T Float](x T) T {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
+
+// axpy sets dst to alpha*x + y.
+func axpy[T Float](dst []T, alpha T, x, y []T) {
+	x = x[:len(dst)]
+	y = y[:len(dst)]
+
+	for i := range dst {
+		dst[i] = alpha*x[i] + y[i]
 	}
 }
@@ -0,0 +1,208 @@
+package vector
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestUnrolledLoops(t *testing.T) {
+	tests := []struct {
+		name     string
+		unrolled func(x, y []float64) float64
+		simple   func(x, y []float64) float64
+	}{
+		{name: "dot", unrolled: dot[float64], simple: simpleDot[float64]},
+		{name: "cosine", unrolled: cosine[float64], simple: simpleCosine[float64]},
+		{name: "euclidean", unrolled: euclidean[float64], simple: simpleEuclidean[float64]},
+		{name: "manhattan", unrolled: manhattan[float64], simple: simpleManhattan[float64]},
+	}
+
+	// The lengths cover every remainder of the unrolled loops.
+	lengths := []int{0, 1, 2, 3, 4, 5, 6, 7, 9, 768}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, n := range lengths {
+				v := randomVectors(2, n, uint64(n))
+
+				got, expected := tt.unrolled(v[0], v[1]), tt.simple(v[0], v[1])
+				if math.Abs(got-expected) > 1e-9 {
+					t.Errorf("%d dimensions: got %v, expected %v", n, got, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestCosineSimilarityZeroVector(t *testing.T) {
+	s, err := CosineSimilarity([]float32{0, 0, 0}, []float32{1, 2, 3})
+	if err != nil {
+		t.Fatalf("computing: %s", err)
+	}
+
+	if s != 0 {
+		t.Errorf("got %v, expected 0", s)
+	}
+}
+
+func TestDimensionMismatch(t *testing.T) {
+	x := []float64{1, 2, 3}
+	y := []float64{1, 2}
+
+	tests := []struct {
+		name string
+		f    func() error
+	}{
+		{name: "CosineSimilarity", f: func() error { _, err := CosineSimilarity(x, y); return err }},
+		{name: "Dot", f: func() error { _, err := Dot(x, y); return err }},
+		{name: "EuclideanDistance", f: func() error { _, err := EuclideanDistance(x, y); return err }},
+		{name: "ManhattanDistance", f: func() error { _, err := ManhattanDistance(x, y); return err }},
+		{name: "Add", f: func() error { _, err := Add(x, y); return err }},
+		{name: "Sub", f: func() error { _, err := Sub(x, y); return err }},
+		{name: "Mean", f: func() error { _, err := Mean(x, x, y); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.f(); !errors.Is(err, ErrDimensionMismatch) {
+				t.Errorf("got error %v, expected %v", err, ErrDimensionMismatch)
+			}
+		})
+	}
+
+	if _, err := Mean[float64](); !errors.Is(err, ErrNoVectors) {
+		t.Errorf("mean of no vectors: got error %v, expected %v", err, ErrNoVectors)
+	}
+}
+
+func TestInputsUnchanged(t *testing.T) {
+	a := []float64{3, 4, 0}
+	b := []float64{1, -2, 5}
+
+	tests := []struct {
+		name     string
+		f        func() ([]float64, error)
+		expected []float64
+	}{
+		{name: "Add", f: func() ([]float64, error) { return Add(a, b) }, expected: []float64{4, 2, 5}},
+		{name: "Sub", f: func() ([]float64, error) { return Sub(a, b) }, expected: []float64{2, 6, -5}},
+		{name: "Scale", f: func() ([]float64, error) { return Scale(a, 2), nil }, expected: []float64{6, 8, 0}},
+		{name: "Normalize", f: func() ([]float64, error) { return Normalize(a), nil }, expected: []float64{0.6, 0.8, 0}},
+		{name: "Mean", f: func() ([]float64, error) { return Mean(a, b) }, expected: []float64{2, 1, 2.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origA, origB := slices.Clone(a), slices.Clone(b)
+
+			got, err := tt.f()
+			if err != nil {
+				t.Fatalf("computing: %s", err)
+			}
+
+			if !slices.EqualFunc(got, tt.expected, func(x, y float64) bool { return math.Abs(x-y) < 1e-12 }) {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+
+			if !slices.Equal(a, origA) || !slices.Equal(b, origB) {
+				t.Errorf("inputs changed to %v and %v", a, b)
+			}
+		})
+	}
+}
+
+// =============================================================================
+
+func BenchmarkDot(b *testing.B) {
+	benchmarkLoops(b, dot[float32], simpleDot[float32])
+	benchmarkLoops(b, dot[float64], simpleDot[float64])
+}
+
+func BenchmarkCosine(b *testing.B) {
+	benchmarkLoops(b, cosine[float32], simpleCosine[float32])
+	benchmarkLoops(b, cosine[float64], simpleCosine[float64])
+}
+
+func BenchmarkEuclidean(b *testing.B) {
+	benchmarkLoops(b, euclidean[float32], simpleEuclidean[float32])
+	benchmarkLoops(b, euclidean[float64], simpleEuclidean[float64])
+}
+
+// benchmarkLoops compares the unrolled loop with the simple loop on vectors
+// with the dimensions of common embedding models.
+func benchmarkLoops[T Float](b *testing.B, unrolled func(x, y []T) T, simple func(x, y []T) T) {
+	v := randomVectors(2, 768, 1)
+
+	x := make([]T, len(v[0]))
+	y := make([]T, len(v[1]))
+	for i := range x {
+		x[i], y[i] = T(v[0][i]), T(v[1][i])
+	}
+
+	var sink T
+
+	typ := "float64"
+	if _, ok := any(sink).(float32); ok {
+		typ = "float32"
+	}
+
+	b.Run(typ+"/unrolled", func(b *testing.B) {
+		for b.Loop() {
+			sink = unrolled(x, y)
+		}
+	})
+
+	b.Run(typ+"/simple", func(b *testing.B) {
+		for b.Loop() {
+			sink = simple(x, y)
+		}
+	})
+}
+
+// The simple loops are the straightforward versions of the unrolled loops,
+// kept to check the results and compare the speed.
+
+func simpleDot[T Float](x, y []T) T {
+	var s T
+	for i := range x {
+		s += x[i] * y[i]
+	}
+
+	return s
+}
+
+func simpleCosine[T Float](x, y []T) T {
+	var d, sx, sy T
+	for i := range x {
+		d += x[i] * y[i]
+		sx += x[i] * x[i]
+		sy += y[i] * y[i]
+	}
+
+	if sx == 0 || sy == 0 {
+		return 0
+	}
+
+	return d / T(math.Sqrt(float64(sx))*math.Sqrt(float64(sy)))
+}
+
+func simpleEuclidean[T Float](x, y []T) T {
+	var s T
+	for i := range x {
+		d := x[i] - y[i]
+		s += d * d
+	}
+
+	return T(math.Sqrt(float64(s)))
+}
+
+func simpleManhattan[T Float](x, y []T) T {
+	var s T
+	for i := range x {
+		s += abs(x[i] - y[i])
+	}
+
+	return s
+}